@@ -5,23 +5,34 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/services/apiserver"
+	"openpitrix.io/scheduler/pkg/version"
 )
 
-func exitHandler() {
-	c := make(chan os.Signal)
+// exitHandler cancels the context on the first signal, the service then
+// shuts down gracefully, and exits at once on the second one.
+func exitHandler(cancel context.CancelFunc) {
+	c := make(chan os.Signal, 1)
 
 	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
 	go func() {
+		stopping := false
 		for s := range c {
 			switch s {
 			case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-				ExitFunc()
+				if stopping {
+					ExitFunc()
+				}
+				logger.Info(nil, "Got signal [%s], shutting down", s)
+				stopping = true
+				cancel()
 			case syscall.SIGUSR1:
 			case syscall.SIGUSR2:
 			default:
@@ -31,13 +42,15 @@ func exitHandler() {
 }
 
 func ExitFunc() {
-	os.Exit(0)
+	os.Exit(1)
 }
 
 func main() {
-	exitHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	exitHandler(cancel)
 
 	config.GetInstance().LoadConf()
+	logger.Info(nil, "Start apiserver %s", version.Get())
 
-	apiserver.Run()
+	apiserver.Run(ctx)
 }
@@ -5,25 +5,34 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/services/nodeagent"
+	"openpitrix.io/scheduler/pkg/version"
 )
 
-func exitHandler() {
-	c := make(chan os.Signal)
+// exitHandler cancels the context on the first signal, the service then
+// shuts down gracefully, and exits at once on the second one.
+func exitHandler(cancel context.CancelFunc) {
+	c := make(chan os.Signal, 1)
 
 	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
 	go func() {
+		stopping := false
 		for s := range c {
 			switch s {
 			case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-				ExitFunc()
+				if stopping {
+					ExitFunc()
+				}
+				logger.Info(nil, "Got signal [%s], shutting down", s)
+				stopping = true
+				cancel()
 			case syscall.SIGUSR1:
 			case syscall.SIGUSR2:
 			default:
@@ -33,24 +42,21 @@ func exitHandler() {
 }
 
 func ExitFunc() {
-	os.Exit(0)
+	os.Exit(1)
 }
 
-func mainFuncNodeAgent() {
+func mainFuncNodeAgent(ctx context.Context) {
 	na := nodeagent.Init()
 
-	na.Run()
+	na.Run(ctx)
 }
 
 func main() {
-	exitHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	exitHandler(cancel)
 
 	config.GetInstance().LoadConf()
+	logger.Info(nil, "Start nodeagent %s", version.Get())
 
-	mainFuncNodeAgent()
-
-	for {
-		logger.Debug(nil, "running...")
-		time.Sleep(time.Second)
-	}
+	mainFuncNodeAgent(ctx)
 }
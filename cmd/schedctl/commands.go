@@ -0,0 +1,449 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/util/idutil"
+)
+
+func runGet(ctx context.Context, opts *options, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	r, err := findResource(args[0])
+	if err != nil {
+		return err
+	}
+
+	var objs []interface{}
+	if len(args) == 2 {
+		obj, err := r.get(ctx, opts.client, opts.namespace, args[1])
+		if err != nil {
+			return err
+		}
+		objs = append(objs, obj)
+	} else {
+		objs, err = r.list(ctx, opts.client, opts.listNamespace(), clientset.ListOptions{Filter: opts.filter, LabelSelector: opts.selector, SortBy: opts.sortBy})
+		if err != nil {
+			return err
+		}
+		if opts.allNamespaces {
+			r = withNamespace(r)
+		}
+	}
+
+	return printObjects(os.Stdout, opts.output, r, objs)
+}
+
+func runDescribe(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	r, err := findResource(args[0])
+	if err != nil {
+		return err
+	}
+
+	obj, err := r.get(ctx, opts.client, opts.namespace, args[1])
+	if err != nil {
+		return err
+	}
+	if err := printYAML(os.Stdout, obj); err != nil {
+		return err
+	}
+
+	// Crons own jobs and jobs own tasks, show them below the object.
+	var owned *resource
+	switch r.name {
+	case "crons":
+		owned, _ = findResource("jobs")
+	case "jobs":
+		owned, _ = findResource("tasks")
+	default:
+		return nil
+	}
+
+	objs, err := owned.list(ctx, opts.client, opts.namespace, clientset.ListOptions{Filter: "Owner=" + args[1], SortBy: "createRevision"})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s:\n", strings.Title(owned.name))
+	return printObjects(os.Stdout, "table", owned, objs)
+}
+
+func runCreate(ctx context.Context, opts *options, args []string) error {
+	return applyManifest(ctx, opts, false)
+}
+
+func runApply(ctx context.Context, opts *options, args []string) error {
+	return applyManifest(ctx, opts, true)
+}
+
+func applyManifest(ctx context.Context, opts *options, update bool) error {
+	if opts.file == "" {
+		return fmt.Errorf("a manifest is required, use -f")
+	}
+
+	objs, err := readManifest(opts.file)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		kind, name, err := writeObject(ctx, opts.client, opts.namespace, obj, update)
+		if err != nil {
+			return fmt.Errorf("%s %q: %v", kind, name, err)
+		}
+	}
+	return nil
+}
+
+// writeObject creates obj, or updates it when update is set and it exists.
+// Objects without a namespace go to namespace.
+func writeObject(ctx context.Context, cs clientset.Interface, namespace string, obj interface{}, update bool) (string, string, error) {
+	var kind, name string
+	var create, replace func() error
+
+	switch o := obj.(type) {
+	case *models.CronInfo:
+		if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		kind, name = "cron", o.Name
+		create = func() error { return cs.Crons(o.Namespace).Create(ctx, o) }
+		replace = func() error { return cs.Crons(o.Namespace).Update(ctx, o) }
+	case *models.JobInfo:
+		if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		kind, name = "job", o.Name
+		create = func() error { return cs.Jobs(o.Namespace).Create(ctx, o) }
+		replace = func() error { return cs.Jobs(o.Namespace).Update(ctx, o) }
+	case *models.TaskInfo:
+		if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		kind, name = "task", o.Name
+		create = func() error { return cs.Tasks(o.Namespace).Create(ctx, o) }
+		replace = func() error { return cs.Tasks(o.Namespace).Update(ctx, o) }
+	case *models.NamespaceInfo:
+		kind, name = "namespace", o.Name
+		create = func() error { return cs.Namespaces().Create(ctx, o) }
+		replace = func() error { return cs.Namespaces().Update(ctx, o) }
+	}
+
+	err := create()
+	if err == nil {
+		fmt.Printf("%s/%s created\n", kind, name)
+		return kind, name, nil
+	}
+	if !update || !clientset.IsConflict(err) {
+		return kind, name, err
+	}
+
+	if err := replace(); err != nil {
+		return kind, name, err
+	}
+	fmt.Printf("%s/%s configured\n", kind, name)
+	return kind, name, nil
+}
+
+func runDelete(ctx context.Context, opts *options, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	r, err := findResource(args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args[1:] {
+		if err := r.delete(ctx, opts.client, opts.namespace, name); err != nil {
+			return err
+		}
+		fmt.Printf("%s/%s deleted\n", strings.TrimSuffix(r.name, "s"), name)
+	}
+	return nil
+}
+
+func runWatch(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	r, err := findResource(args[0])
+	if err != nil {
+		return err
+	}
+
+	watcher, err := r.watch(ctx, opts.client, opts.listNamespace(), clientset.ListOptions{Filter: opts.filter, LabelSelector: opts.selector})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	if opts.allNamespaces {
+		r = withNamespace(r)
+	}
+
+	for event := range watcher.ResultChan() {
+		if event.Type == "ERROR" {
+			return event.Object.(error)
+		}
+		if opts.output != "table" {
+			if err := printObjects(os.Stdout, opts.output, r, []interface{}{event.Object}); err != nil {
+				return err
+			}
+			continue
+		}
+		if event.Object == nil {
+			fmt.Printf("%s\t%s\n", event.Type, event.Info.Key)
+			continue
+		}
+		fmt.Printf("%s\t%s\n", event.Type, strings.Join(r.row(event.Object), "\t"))
+	}
+	return ctx.Err()
+}
+
+func runRun(ctx context.Context, opts *options, args []string) error {
+	// A container runs the command of its image without one.
+	if len(args) == 0 && opts.image == "" {
+		return errUsage
+	}
+
+	name := opts.name
+	if name == "" {
+		name = idutil.GetUuid(constants.JobIdPrefix)
+	}
+
+	job := &models.JobInfo{
+		Name:      name,
+		Namespace: opts.namespace,
+		Owner:     "schedctl",
+		Cmd:       args,
+		Status:    "Created",
+	}
+	if opts.image != "" {
+		job.Container = &models.ContainerSpec{Image: opts.image}
+	}
+	if err := opts.client.Jobs(opts.namespace).Create(ctx, job); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "job/%s created\n", name)
+
+	if opts.noWait {
+		return nil
+	}
+
+	job, err := waitJob(ctx, opts.client, opts.namespace, name)
+	if err != nil {
+		return err
+	}
+
+	list, err := opts.client.Tasks(opts.namespace).List(ctx, clientset.ListOptions{Filter: "Owner=" + name})
+	if err != nil {
+		return err
+	}
+	exitCode := 0
+	for _, task := range list.Items {
+		fmt.Print(task.Output)
+		if task.ExitCode != 0 {
+			exitCode = task.ExitCode
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "job/%s %s\n", name, jobStatus(job))
+	if job.Status == "Failed" && exitCode == 0 {
+		exitCode = 1
+	}
+	if exitCode != 0 {
+		return exitError(exitCode)
+	}
+	return nil
+}
+
+// waitJob waits for the job to be Completed or Failed.
+func waitJob(ctx context.Context, cs clientset.Interface, namespace string, name string) (*models.JobInfo, error) {
+	watcher, err := cs.Jobs(namespace).Watch(ctx, clientset.ListOptions{Filter: "Name=" + name})
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Stop()
+
+	// The watch starts with the current state of the job, no event is lost
+	// between the creation and the watch.
+	for event := range watcher.ResultChan() {
+		if event.Type == "ERROR" {
+			return nil, event.Object.(error)
+		}
+		job, ok := event.Object.(*models.JobInfo)
+		if !ok {
+			continue
+		}
+		if event.Type == "DELETE" {
+			return nil, fmt.Errorf("job %s has been deleted", name)
+		}
+		if job.Status == "Completed" || job.Status == "Failed" {
+			return job, nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, fmt.Errorf("watch of job %s closed", name)
+}
+
+func runLogs(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	name := args[0]
+
+	task, err := opts.client.Tasks(opts.namespace).Get(ctx, name)
+	if err == nil {
+		fmt.Print(task.Output)
+		return nil
+	}
+	if !clientset.IsNotFound(err) {
+		return err
+	}
+
+	if _, err := opts.client.Jobs(opts.namespace).Get(ctx, name); err != nil {
+		if clientset.IsNotFound(err) {
+			return fmt.Errorf("no task or job named %q", name)
+		}
+		return err
+	}
+
+	list, err := opts.client.Tasks(opts.namespace).List(ctx, clientset.ListOptions{Filter: "Owner=" + name})
+	if err != nil {
+		return err
+	}
+	for _, task := range list.Items {
+		if len(list.Items) > 1 {
+			fmt.Printf("==> task/%s <==\n", task.Name)
+		}
+		fmt.Print(task.Output)
+	}
+	return nil
+}
+
+func runSuspend(ctx context.Context, opts *options, args []string) error {
+	return setSuspend(ctx, opts, args, true)
+}
+
+func runResume(ctx context.Context, opts *options, args []string) error {
+	return setSuspend(ctx, opts, args, false)
+}
+
+func setSuspend(ctx context.Context, opts *options, args []string, suspend bool) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	cron, err := opts.client.Crons(opts.namespace).Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if cron.Suspend != suspend {
+		cron.Suspend = suspend
+		if err := opts.client.Crons(opts.namespace).Update(ctx, cron); err != nil {
+			return err
+		}
+	}
+
+	if suspend {
+		fmt.Printf("cron/%s suspended\n", cron.Name)
+	} else {
+		fmt.Printf("cron/%s resumed\n", cron.Name)
+	}
+	return nil
+}
+
+func runTrigger(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	trigger, err := opts.client.Crons(opts.namespace).Trigger(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("cron/%s triggered (%s)\n", args[0], trigger)
+	return nil
+}
+
+func runCordon(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := opts.client.Nodes().Cordon(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("node/%s cordoned\n", args[0])
+	return nil
+}
+
+func runUncordon(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := opts.client.Nodes().Uncordon(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("node/%s uncordoned\n", args[0])
+	return nil
+}
+
+// drainPollPeriod is how often drain checks the tasks left on the node.
+const drainPollPeriod = 2 * time.Second
+
+func runDrain(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	name := args[0]
+
+	if err := opts.client.Nodes().Drain(ctx, name, opts.gracePeriod); err != nil {
+		return err
+	}
+	fmt.Printf("node/%s cordoned, draining\n", name)
+	if opts.noWait {
+		return nil
+	}
+
+	// The node agent hands back the tasks not started and those still
+	// running after the grace period.
+	filter := fmt.Sprintf("Node=%s,Status in (Scheduled,Running)", name)
+	ticker := time.NewTicker(drainPollPeriod)
+	defer ticker.Stop()
+	for {
+		list, err := opts.client.Tasks("").List(ctx, clientset.ListOptions{Filter: filter})
+		if err != nil {
+			return err
+		}
+		if len(list.Items) == 0 {
+			fmt.Printf("node/%s drained\n", name)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+// schedctl operates the scheduler through the apiserver.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/constants"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, opts *options, args []string) error
+}
+
+var commands = map[string]command{
+	"get":      {"get <resource> [name] [-n namespace|-A] [--filter expr] [-l selector] [--sort-by field]", "List resources or show one", runGet},
+	"describe": {"describe <resource> <name>", "Show a resource and its related resources", runDescribe},
+	"create":   {"create -f manifest.yaml", "Create resources from a YAML manifest", runCreate},
+	"apply":    {"apply -f manifest.yaml", "Create or update resources from a YAML manifest", runApply},
+	"delete":   {"delete <resource> <name>", "Delete a resource", runDelete},
+	"watch":    {"watch <resource> [-n namespace|-A] [--filter expr] [-l selector]", "Print the changes of resources", runWatch},
+	"run":      {"run [--name name] [--image image] [--no-wait] -- cmd [args...]", "Submit an ad-hoc job and wait for it to finish", runRun},
+	"logs":     {"logs <task|job name>", "Print the output of a task or of the tasks of a job", runLogs},
+	"suspend":  {"suspend <cron>", "Stop scheduling a cron", runSuspend},
+	"resume":   {"resume <cron>", "Resume scheduling a cron", runResume},
+	"trigger":  {"trigger <cron>", "Run a cron now", runTrigger},
+	"cordon":   {"cordon <node>", "Stop scheduling tasks to a node", runCordon},
+	"uncordon": {"uncordon <node>", "Schedule tasks to a node again", runUncordon},
+	"drain":    {"drain <node> [--grace-period 5m] [--no-wait]", "Cordon a node and wait until its tasks are finished or handed back", runDrain},
+}
+
+type options struct {
+	server        string
+	token         string
+	caFile        string
+	certFile      string
+	keyFile       string
+	timeout       time.Duration
+	namespace     string
+	allNamespaces bool
+	output        string
+	filter        string
+	selector      string
+	sortBy        string
+	file          string
+	name          string
+	image         string
+	noWait        bool
+	gracePeriod   time.Duration
+
+	client clientset.Interface
+}
+
+func (o *options) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&o.server, "server", envOr("SCHEDCTL_SERVER", "http://127.0.0.1:8080"), "apiserver address")
+	fs.StringVar(&o.token, "token", os.Getenv("SCHEDCTL_TOKEN"), "bearer token")
+	fs.StringVar(&o.caFile, "ca-file", os.Getenv("SCHEDCTL_CA_FILE"), "CA verifying an https apiserver")
+	fs.StringVar(&o.certFile, "cert", os.Getenv("SCHEDCTL_CERT"), "client certificate")
+	fs.StringVar(&o.keyFile, "key", os.Getenv("SCHEDCTL_KEY"), "key of the client certificate")
+	fs.DurationVar(&o.timeout, "timeout", 30*time.Second, "request timeout")
+	fs.StringVar(&o.namespace, "n", envOr("SCHEDCTL_NAMESPACE", constants.DefaultNamespace), "namespace")
+	fs.BoolVar(&o.allNamespaces, "A", false, "get or watch the resources of every namespace")
+	fs.StringVar(&o.output, "o", "table", "output format: table, json or yaml")
+	fs.StringVar(&o.filter, "filter", "", "filter expression, eg. Status=Failed")
+	fs.StringVar(&o.selector, "l", "", "label selector, eg. team=data")
+	fs.StringVar(&o.sortBy, "sort-by", "", "sort of get: name, createRevision or status")
+	fs.StringVar(&o.file, "f", "", "YAML manifest, - reads stdin")
+	fs.StringVar(&o.name, "name", "", "job name of run")
+	fs.StringVar(&o.image, "image", "", "container image the job of run runs in, cmd may then be omitted")
+	fs.BoolVar(&o.noWait, "no-wait", false, "do not wait for the job of run or the tasks of drain")
+	fs.DurationVar(&o.gracePeriod, "grace-period", 5*time.Minute, "how long the running tasks of a drained node may finish")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: schedctl %s\n\nFlags:\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// listNamespace is the namespace to list and watch, empty for every one.
+func (o *options) listNamespace() string {
+	if o.allNamespaces {
+		return ""
+	}
+	return o.namespace
+}
+
+func envOr(name string, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
+
+// parseArgs parses the flags wherever they are placed among the arguments,
+// everything after "--" is kept as is.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return append(positional, rest...)
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "schedctl operates the scheduler.\n\nUsage:\n  schedctl <command> [flags]\n\nCommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].help)
+	}
+	fmt.Fprintf(os.Stderr, "\nResources: tasks, jobs, crons, nodes, namespaces\nRun 'schedctl <command> -h' for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		if name != "-h" && name != "--help" && name != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	opts := &options{}
+	args := parseArgs(opts.flagSet(name), os.Args[2:])
+
+	client, err := clientset.NewForConfig(&clientset.Config{
+		Host:         opts.server,
+		BearerToken:  opts.token,
+		CAFile:       opts.caFile,
+		CertFile:     opts.certFile,
+		KeyFile:      opts.keyFile,
+		Timeout:      opts.timeout,
+		MaxRetries:   2,
+		RetryBackoff: 500 * time.Millisecond,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.client = client
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	if err := cmd.run(ctx, opts, args); err != nil {
+		if err == errUsage {
+			fmt.Fprintf(os.Stderr, "Usage: schedctl %s\n", cmd.usage)
+			os.Exit(2)
+		}
+		if exit, ok := err.(exitError); ok {
+			os.Exit(int(exit))
+		}
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned by the commands called with wrong arguments.
+var errUsage = errors.New("usage")
+
+// exitError makes schedctl exit with the code without printing anything.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// readManifest reads the objects of a multi-document YAML manifest. Every
+// document has a kind, cron, job, task or namespace, and the fields of the
+// model:
+//
+//	kind: cron
+//	name: backup
+//	namespace: team-a
+//	script: "0 3 * * *"
+//	cmd: ["sh", "-c", "backup.sh"]
+func readManifest(file string) ([]interface{}, error) {
+	var reader io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	var objs []interface{}
+	decoder := yaml.NewDecoder(reader)
+	for n := 1; ; n++ {
+		var doc map[interface{}]interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %v", file, n, err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		obj, err := decodeDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %v", file, n, err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+func decodeDocument(doc map[interface{}]interface{}) (interface{}, error) {
+	fields := make(map[string]interface{}, len(doc))
+	for key, value := range doc {
+		fields[fmt.Sprint(key)] = jsonValue(value)
+	}
+
+	kind, _ := fields["kind"].(string)
+	delete(fields, "kind")
+
+	var obj interface{}
+	switch strings.ToLower(kind) {
+	case "cron":
+		obj = &models.CronInfo{}
+	case "job":
+		obj = &models.JobInfo{}
+	case "task":
+		obj = &models.TaskInfo{}
+	case "namespace":
+		obj = &models.NamespaceInfo{}
+	case "":
+		return nil, fmt.Errorf("kind is missing")
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+
+	// The models only have JSON tags, which match the YAML keys case
+	// insensitively.
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+
+	switch o := obj.(type) {
+	case *models.JobInfo:
+		if o.Status == "" {
+			o.Status = "Created"
+		}
+	case *models.TaskInfo:
+		if o.Status == "" {
+			o.Status = "Pending"
+		}
+	}
+	return obj, nil
+}
+
+// jsonValue converts the YAML maps, which may have any key, to JSON objects.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+func printObjects(w io.Writer, format string, r *resource, objs []interface{}) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(objs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case "yaml":
+		for i, obj := range objs {
+			if i > 0 {
+				fmt.Fprintln(w, "---")
+			}
+			if err := printYAML(w, obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(r.columns, "\t"))
+		for _, obj := range objs {
+			fmt.Fprintln(tw, strings.Join(r.row(obj), "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, json or yaml", format)
+	}
+}
+
+// printYAML prints obj with the same field names as its JSON form.
+func printYAML(w io.Writer, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var value yaml.MapSlice
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	data, err = yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,306 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// resource adapts the typed clients to the generic commands. The namespace
+// ns is ignored by the resources that are not namespaced, an empty one lists
+// and watches every namespace.
+type resource struct {
+	name       string
+	namespaced bool
+	columns    []string
+	row        func(obj interface{}) []string
+	list       func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) ([]interface{}, error)
+	get        func(ctx context.Context, cs clientset.Interface, ns string, name string) (interface{}, error)
+	delete     func(ctx context.Context, cs clientset.Interface, ns string, name string) error
+	watch      func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) (clientset.Watcher, error)
+}
+
+var resources = []*resource{
+	{
+		name:       "tasks",
+		namespaced: true,
+		columns:    []string{"NAME", "OWNER", "NODE", "STATUS", "EXIT", "STARTED", "DURATION"},
+		row: func(obj interface{}) []string {
+			t := obj.(*models.TaskInfo)
+			return []string{t.Name, t.Owner, t.Node, taskStatus(t), exitCode(t), age(t.StartTime), duration(t.StartTime, t.CompleteTime)}
+		},
+		list: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) ([]interface{}, error) {
+			list, err := cs.Tasks(ns).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			var objs []interface{}
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+		get: func(ctx context.Context, cs clientset.Interface, ns string, name string) (interface{}, error) {
+			return cs.Tasks(ns).Get(ctx, name)
+		},
+		delete: func(ctx context.Context, cs clientset.Interface, ns string, name string) error {
+			return cs.Tasks(ns).Delete(ctx, name)
+		},
+		watch: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) (clientset.Watcher, error) {
+			return cs.Tasks(ns).Watch(ctx, opts)
+		},
+	},
+	{
+		name:       "jobs",
+		namespaced: true,
+		columns:    []string{"NAME", "OWNER", "TRIGGER", "STATUS", "STARTED", "DURATION"},
+		row: func(obj interface{}) []string {
+			j := obj.(*models.JobInfo)
+			return []string{j.Name, j.Owner, j.Trigger, jobStatus(j), age(j.StartTime), duration(j.StartTime, j.CompleteTime)}
+		},
+		list: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) ([]interface{}, error) {
+			list, err := cs.Jobs(ns).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			var objs []interface{}
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+		get: func(ctx context.Context, cs clientset.Interface, ns string, name string) (interface{}, error) {
+			return cs.Jobs(ns).Get(ctx, name)
+		},
+		delete: func(ctx context.Context, cs clientset.Interface, ns string, name string) error {
+			return cs.Jobs(ns).Delete(ctx, name)
+		},
+		watch: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) (clientset.Watcher, error) {
+			return cs.Jobs(ns).Watch(ctx, opts)
+		},
+	},
+	{
+		name:       "crons",
+		namespaced: true,
+		columns:    []string{"NAME", "SCHEDULE", "SUSPEND", "STATUS", "LAST SCHEDULE"},
+		row: func(obj interface{}) []string {
+			c := obj.(*models.CronInfo)
+			return []string{c.Name, c.Script, strconv.FormatBool(c.Suspend), c.Status, age(c.LastScheduleTime)}
+		},
+		list: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) ([]interface{}, error) {
+			list, err := cs.Crons(ns).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			var objs []interface{}
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+		get: func(ctx context.Context, cs clientset.Interface, ns string, name string) (interface{}, error) {
+			return cs.Crons(ns).Get(ctx, name)
+		},
+		delete: func(ctx context.Context, cs clientset.Interface, ns string, name string) error {
+			return cs.Crons(ns).Delete(ctx, name)
+		},
+		watch: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) (clientset.Watcher, error) {
+			return cs.Crons(ns).Watch(ctx, opts)
+		},
+	},
+	{
+		name:    "nodes",
+		columns: []string{"NAME", "STATUS", "HOSTNAME", "IP", "VERSION", "POOL", "LABELS"},
+		row: func(obj interface{}) []string {
+			n := obj.(*models.NodeInfo)
+			return []string{n.Name, nodeStatus(n), n.Hostname, strings.Join(n.IPs, ","), n.AgentVersion, n.Pool, formatLabels(n.Labels)}
+		},
+		list: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) ([]interface{}, error) {
+			list, err := cs.Nodes().List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			var objs []interface{}
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+		get: func(ctx context.Context, cs clientset.Interface, ns string, name string) (interface{}, error) {
+			return cs.Nodes().Get(ctx, name)
+		},
+		delete: func(ctx context.Context, cs clientset.Interface, ns string, name string) error {
+			return cs.Nodes().Delete(ctx, name)
+		},
+		watch: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) (clientset.Watcher, error) {
+			return cs.Nodes().Watch(ctx, opts)
+		},
+	},
+	{
+		name:    "namespaces",
+		columns: []string{"NAME", "POOLS", "QUOTA", "LABELS"},
+		row: func(obj interface{}) []string {
+			n := obj.(*models.NamespaceInfo)
+			return []string{n.Name, strings.Join(n.NodePools, ","), formatQuota(n.Quota), formatLabels(n.Labels)}
+		},
+		list: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) ([]interface{}, error) {
+			list, err := cs.Namespaces().List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			var objs []interface{}
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+			return objs, nil
+		},
+		get: func(ctx context.Context, cs clientset.Interface, ns string, name string) (interface{}, error) {
+			return cs.Namespaces().Get(ctx, name)
+		},
+		delete: func(ctx context.Context, cs clientset.Interface, ns string, name string) error {
+			return cs.Namespaces().Delete(ctx, name)
+		},
+		watch: func(ctx context.Context, cs clientset.Interface, ns string, opts clientset.ListOptions) (clientset.Watcher, error) {
+			return cs.Namespaces().Watch(ctx, opts)
+		},
+	},
+}
+
+func findResource(name string) (*resource, error) {
+	name = strings.ToLower(name)
+	for _, r := range resources {
+		if name == r.name || name == strings.TrimSuffix(r.name, "s") || name == r.name[:1] {
+			return r, nil
+		}
+	}
+	if name == "ns" {
+		return findResource("namespaces")
+	}
+	return nil, fmt.Errorf("unknown resource %q, expected tasks, jobs, crons, nodes or namespaces", name)
+}
+
+// withNamespace adds a NAMESPACE column to a namespaced resource, for the
+// objects of every namespace.
+func withNamespace(r *resource) *resource {
+	if !r.namespaced {
+		return r
+	}
+	wide := *r
+	wide.columns = append([]string{"NAMESPACE"}, r.columns...)
+	wide.row = func(obj interface{}) []string {
+		var ns string
+		switch o := obj.(type) {
+		case *models.TaskInfo:
+			ns = o.Namespace
+		case *models.JobInfo:
+			ns = o.Namespace
+		case *models.CronInfo:
+			ns = o.Namespace
+		}
+		return append([]string{ns}, r.row(obj)...)
+	}
+	return &wide
+}
+
+func formatQuota(quota models.NamespaceQuota) string {
+	var limits []string
+	if quota.MaxCrons > 0 {
+		limits = append(limits, fmt.Sprintf("crons=%d", quota.MaxCrons))
+	}
+	if quota.MaxConcurrentJobs > 0 {
+		limits = append(limits, fmt.Sprintf("jobs=%d", quota.MaxConcurrentJobs))
+	}
+	if quota.MaxTasksPerMinute > 0 {
+		limits = append(limits, fmt.Sprintf("tasks/min=%d", quota.MaxTasksPerMinute))
+	}
+	return strings.Join(limits, ",")
+}
+
+// nodeStatus tells if the node takes tasks, like Ready,SchedulingDisabled.
+func nodeStatus(n *models.NodeInfo) string {
+	status := "Ready"
+	if !n.IsReady() {
+		status = "NotReady"
+	}
+	switch {
+	case n.Drain != nil:
+		return status + ",Draining"
+	case n.Unschedulable:
+		return status + ",SchedulingDisabled"
+	}
+	return status
+}
+
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// taskStatus tells why the task failed besides its exit code, like
+// Failed,OOMKilled.
+func taskStatus(t *models.TaskInfo) string {
+	if t.Reason != "" {
+		return t.Status + "," + t.Reason
+	}
+	return t.Status
+}
+
+// jobStatus tells why the job failed without a task, like
+// Failed,QuotaExceeded.
+func jobStatus(j *models.JobInfo) string {
+	if j.Reason != "" {
+		return j.Status + "," + j.Reason
+	}
+	return j.Status
+}
+
+func exitCode(t *models.TaskInfo) string {
+	if t.Status != "Completed" && t.Status != "Failed" {
+		return ""
+	}
+	return strconv.Itoa(t.ExitCode)
+}
+
+func age(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return shortDuration(time.Since(t)) + " ago"
+}
+
+func duration(start time.Time, end time.Time) string {
+	if start.IsZero() {
+		return ""
+	}
+	if end.IsZero() || end.Before(start) {
+		end = time.Now()
+	}
+	return shortDuration(end.Sub(start))
+}
+
+func shortDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -11,10 +11,12 @@ require (
 	github.com/google/gops v0.3.6
 	github.com/jinzhu/gorm v1.9.10
 	github.com/koding/multiconfig v0.0.0-20171124222453-69c27309b2d7
+	github.com/prometheus/client_golang v1.0.0
 	github.com/robfig/cron v1.2.0
 	github.com/robfig/cron/v3 v3.0.0
 	github.com/sony/sonyflake v1.0.0
 	github.com/speps/go-hashids v2.0.0+incompatible
 	golang.org/x/net v0.0.0-20190724013045-ca1201d0de80 // indirect
 	google.golang.org/grpc v1.22.1 // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
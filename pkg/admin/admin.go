@@ -0,0 +1,101 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+// Package admin serves the endpoints every component exposes on its admin
+// port: /metrics, /healthz, /readyz and /version.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/version"
+)
+
+// Check returns an error while the component is not ready.
+type Check func() error
+
+type Server struct {
+	port string
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+func NewServer(port string) *Server {
+	return &Server{port: port, checks: make(map[string]Check)}
+}
+
+// AddReadyCheck adds a check to /readyz.
+func (s *Server) AddReadyCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.HandleFunc("/version", s.version)
+	return mux
+}
+
+// Start serves the admin endpoints in the background.
+func (s *Server) Start() {
+	handler := s.Handler()
+	go func() {
+		logger.Info(nil, "Serve admin endpoints on port [%s]", s.port)
+		if err := http.ListenAndServe(":"+s.port, handler); err != nil {
+			logger.Error(nil, "Serve admin endpoints error [%v]", err)
+		}
+	}()
+}
+
+// healthz answers as long as the process is alive.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz runs the checks in the order of their names and lists the failed
+// ones with a 503.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.checks))
+	checks := make(map[string]Check, len(s.checks))
+	for name, check := range s.checks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	var failed []string
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, f := range failed {
+			fmt.Fprintln(w, f)
+		}
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
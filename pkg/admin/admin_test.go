@@ -0,0 +1,47 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/version"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer("0")
+	ready := errors.New("informers not synced yet")
+	s.AddReadyCheck("informers", func() error { return ready })
+	s.AddReadyCheck("etcd", func() error { return nil })
+	handler := s.Handler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, get("/healthz").Code)
+
+	recorder := get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "informers: informers not synced yet", strings.TrimSpace(recorder.Body.String()))
+
+	ready = nil
+	assert.Equal(t, http.StatusOK, get("/readyz").Code)
+
+	var info version.Info
+	assert.NoError(t, json.Unmarshal(get("/version").Body.Bytes(), &info))
+	assert.Equal(t, version.Version, info.Version)
+
+	assert.Equal(t, http.StatusOK, get("/metrics").Code)
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package artifact
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localStore keeps the artifacts in a directory, which the nodes share, eg.
+// over NFS, or a single node uses.
+type localStore struct {
+	dir string
+}
+
+// path is the file of key, a key leading out of the directory is refused.
+func (s *localStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *localStore) Put(ctx context.Context, key string, filePath string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeFile(dst, file)
+}
+
+func (s *localStore) Get(ctx context.Context, key string, filePath string) error {
+	src, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeFile(filePath, file)
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package artifact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/config"
+)
+
+// emptyPayloadHash is the SHA-256 of the empty body of the downloads.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Store keeps the artifacts in a bucket of an S3 compatible service, such
+// as MinIO, addressed in path style and signed with AWS signature version 4.
+type s3Store struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func newS3Store(cfg *config.Config) (*s3Store, error) {
+	endpoint, err := url.Parse(cfg.Artifact.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid artifact endpoint [%s]", cfg.Artifact.Endpoint)
+	}
+
+	bucket := strings.TrimPrefix(cfg.Artifact.Store, "s3://")
+	var prefix string
+	if i := strings.Index(bucket, "/"); i >= 0 {
+		bucket, prefix = bucket[:i], strings.Trim(bucket[i+1:], "/")
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid artifact store [%s]", cfg.Artifact.Store)
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &s3Store{
+		client:    &http.Client{},
+		endpoint:  endpoint,
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    cfg.Artifact.Region,
+		accessKey: cfg.Artifact.AccessKey,
+		secretKey: cfg.Artifact.SecretKey,
+	}, nil
+}
+
+// uriEncode encodes s as S3 expects in the canonical requests, everything
+// but the unreserved characters and, unless encodeSlash, the slashes.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *s3Store) url(key string) *url.URL {
+	objectPath := "/" + s.bucket + "/" + s.prefix + key
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = uriEncode(u.Path, false)
+	return &u
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// sign signs the request with AWS signature version 4, the host and every
+// header of the request are signed.
+func sign(request *http.Request, payloadHash string, region string, accessKey string, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	var query []string
+	for name, values := range request.URL.Query() {
+		for _, value := range values {
+			query = append(query, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(query)
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		strings.Join(query, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		",SignedHeaders="+signedHeaders+",Signature="+signature)
+}
+
+// s3Error decodes the error of an answer.
+func s3Error(response *http.Response) error {
+	data, _ := ioutil.ReadAll(response.Body)
+	body := struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	if xml.Unmarshal(data, &body) != nil || body.Code == "" {
+		return fmt.Errorf("artifact store returned %d", response.StatusCode)
+	}
+	return fmt.Errorf("artifact store returned %d %s: %s", response.StatusCode, body.Code, body.Message)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, filePath string) error {
+	size, payloadHash, err := Digest(filePath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	request, err := http.NewRequest("PUT", s.url(key).String(), file)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	sign(request, payloadHash, s.region, s.accessKey, s.secretKey, time.Now())
+
+	response, err := s.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return s3Error(response)
+	}
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string, filePath string) error {
+	request, err := http.NewRequest("GET", s.url(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	sign(request, emptyPayloadHash, s.region, s.accessKey, s.secretKey, time.Now())
+
+	response, err := s.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return s3Error(response)
+	}
+	return writeFile(filePath, response.Body)
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+// Package artifact keeps the files the tasks leave for the tasks of other
+// jobs, in a local directory or an S3 compatible bucket.
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"openpitrix.io/scheduler/pkg/config"
+)
+
+var (
+	ErrNotFound   = errors.New("artifact not found")
+	ErrInvalidKey = errors.New("invalid artifact key")
+)
+
+// Store uploads and downloads the artifacts, their keys are slash separated.
+type Store interface {
+	// Put uploads the file at filePath as key.
+	Put(ctx context.Context, key string, filePath string) error
+	// Get downloads key to the file at filePath, it returns ErrNotFound
+	// when there is no such key.
+	Get(ctx context.Context, key string, filePath string) error
+}
+
+// Key is the key of the artifact name of the job.
+func Key(namespace string, job string, name string) string {
+	return path.Join(namespace, job, name)
+}
+
+// NewStore opens the store of cfg.Artifact.Store, a directory or
+// s3://bucket/prefix.
+func NewStore(cfg *config.Config) (Store, error) {
+	store := cfg.Artifact.Store
+	switch {
+	case strings.HasPrefix(store, "s3://"):
+		return newS3Store(cfg)
+	case strings.HasPrefix(store, "file://"):
+		return &localStore{dir: strings.TrimPrefix(store, "file://")}, nil
+	case filepath.IsAbs(store):
+		return &localStore{dir: store}, nil
+	}
+	return nil, fmt.Errorf("unsupported artifact store [%s]", store)
+}
+
+// Digest returns the size and the hex SHA-256 of the file.
+func Digest(filePath string) (int64, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeFile writes r to filePath through a temporary file, so that a
+// download cut short leaves no file.
+func writeFile(filePath string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	tmp := filePath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filePath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
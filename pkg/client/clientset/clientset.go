@@ -0,0 +1,73 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+// Package clientset is the Go client of the scheduler apiserver.
+//
+//	cs, err := clientset.NewForConfig(&clientset.Config{Host: "http://127.0.0.1:8080"})
+//	task, err := cs.Tasks("default").Get(ctx, "t-1234abcd")
+//
+// Tasks, jobs, crons and triggers are namespaced, their clients for the
+// namespace "" list and watch every namespace.
+package clientset
+
+type Interface interface {
+	Tasks(namespace string) TaskInterface
+	Jobs(namespace string) JobInterface
+	Crons(namespace string) CronInterface
+	Triggers(namespace string) TriggerInterface
+	Nodes() NodeInterface
+	Namespaces() NamespaceInterface
+}
+
+type Clientset struct {
+	rest       *restClient
+	nodes      *nodes
+	namespaces *namespaces
+}
+
+func NewForConfig(cfg *Config) (*Clientset, error) {
+	rest, err := newRESTClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clientset{
+		rest:       rest,
+		nodes:      newNodes(rest),
+		namespaces: newNamespaces(rest),
+	}, nil
+}
+
+// NewForConfigOrDie panics when the config is invalid.
+func NewForConfigOrDie(cfg *Config) *Clientset {
+	cs, err := NewForConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cs
+}
+
+func (cs *Clientset) Tasks(namespace string) TaskInterface {
+	return newTasks(cs.rest, namespace)
+}
+
+func (cs *Clientset) Jobs(namespace string) JobInterface {
+	return newJobs(cs.rest, namespace)
+}
+
+func (cs *Clientset) Crons(namespace string) CronInterface {
+	return newCrons(cs.rest, namespace)
+}
+
+func (cs *Clientset) Triggers(namespace string) TriggerInterface {
+	return newTriggers(cs.rest, namespace)
+}
+
+func (cs *Clientset) Nodes() NodeInterface {
+	return cs.nodes
+}
+
+func (cs *Clientset) Namespaces() NamespaceInterface {
+	return cs.namespaces
+}
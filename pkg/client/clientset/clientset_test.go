@@ -0,0 +1,193 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestClientset(t *testing.T) {
+	failures := 2
+	posts := 0
+	var created, updated models.APIInfo
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v1alpha1/namespaces/default/tasks/t-1":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusOK)
+		case r.Method == "POST" && r.URL.Path == "/api/v1alpha1/namespaces/default/tasks/t-4":
+			// The create may have been committed, it is not sent again.
+			posts++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.Method == "POST" && r.URL.Path == "/api/v1alpha1/namespaces/default/tasks/t-2":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message": "resource already exists"}`))
+		case r.Method == "PUT" && r.URL.Path == "/api/v1alpha1/namespaces/default/tasks/t-1":
+			updated = models.APIInfo{}
+			json.NewDecoder(r.Body).Decode(&updated)
+			if updated.ResourceVersion != 0 && updated.ResourceVersion != 7 {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"message": "resource has been modified"}`))
+				return
+			}
+			w.Header().Set(constants.HeaderResourceVersion, "8")
+			w.WriteHeader(http.StatusOK)
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha1/namespaces/default/tasks/t-1":
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			json.NewEncoder(w).Encode(models.Info{Key: "tasks/default/t-1", Value: []byte(created.Info), ModRevision: 7})
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha1/tasks/":
+			assert.Equal(t, "Status=Pending", r.URL.Query().Get("filter"))
+			w.Header().Set(constants.HeaderResourceVersion, "9")
+			if r.URL.Query().Get("continue") == "" {
+				json.NewEncoder(w).Encode(models.InfoList{
+					ResourceVersion: 9,
+					Count:           1,
+					Continue:        "page-2",
+					Items:           []models.Info{{Key: "tasks/default/t-1", Value: []byte(created.Info)}},
+				})
+				return
+			}
+			assert.Equal(t, "page-2", r.URL.Query().Get("continue"))
+			json.NewEncoder(w).Encode(models.InfoList{
+				ResourceVersion: 9,
+				Count:           1,
+				Items:           []models.Info{{Key: "tasks/team-a/t-3"}},
+			})
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha1/namespaces/team-a/tasks/":
+			json.NewEncoder(w).Encode(models.InfoList{Items: []models.Info{{Key: "tasks/team-a/t-3"}}})
+		case r.Method == "POST" && r.URL.Path == "/api/v1alpha1/namespaces/team-a/crons/c-1/trigger":
+			json.NewEncoder(w).Encode("triggers/team-a/tr-1")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "resource not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	cs, err := NewForConfig(&Config{
+		Host:         server.URL,
+		BearerToken:  "secret",
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	err = cs.Tasks("").Create(ctx, &models.TaskInfo{Name: "t-1", Status: "Pending"})
+	assert.NoError(t, err)
+
+	err = cs.Tasks("default").Create(ctx, &models.TaskInfo{Name: "t-2"})
+	assert.True(t, IsConflict(err))
+	assert.Contains(t, err.Error(), "resource already exists")
+
+	task, err := cs.Tasks("default").Get(ctx, "t-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Pending", task.Status)
+	assert.Equal(t, int64(7), task.ResourceVersion)
+
+	task.Status = "Running"
+	assert.NoError(t, cs.Tasks("default").UpdateIfUnchanged(ctx, task))
+	assert.Equal(t, int64(7), updated.ResourceVersion)
+	assert.NotContains(t, updated.Info, "ResourceVersion")
+	// The task may be updated again at the revision of the update.
+	assert.Equal(t, int64(8), task.ResourceVersion)
+
+	task.ResourceVersion = 6
+	err = cs.Tasks("default").UpdateIfUnchanged(ctx, task)
+	assert.True(t, IsConflict(err))
+
+	assert.NoError(t, cs.Tasks("default").Update(ctx, task))
+	assert.Equal(t, int64(0), updated.ResourceVersion)
+
+	_, err = cs.Jobs("default").Get(ctx, "j-1")
+	assert.True(t, IsNotFound(err))
+
+	list, err := cs.Tasks("").List(ctx, ListOptions{Filter: "Status=Pending"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), list.ResourceVersion)
+	assert.Len(t, list.Items, 2)
+	assert.Equal(t, "t-3", list.Items[1].Name)
+	assert.Equal(t, "team-a", list.Items[1].Namespace)
+	assert.Empty(t, list.Continue)
+
+	list, err = cs.Tasks("team-a").List(ctx, ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+
+	list, err = cs.Tasks("").List(ctx, ListOptions{Filter: "Status=Pending", Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+	assert.Equal(t, "page-2", list.Continue)
+
+	trigger, err := cs.Crons("team-a").Trigger(ctx, "c-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "tr-1", trigger)
+
+	_, err = cs.Crons("team-a").Trigger(ctx, "c-2")
+	assert.True(t, IsNotFound(err))
+
+	err = cs.Tasks("default").Create(ctx, &models.TaskInfo{Name: "t-4"})
+	assert.True(t, IsServerError(err))
+	assert.Equal(t, 1, posts)
+}
+
+func TestRetryable(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "http://apiserver", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	readErr := &url.Error{Op: "Post", URL: "http://apiserver", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	unavailable := &StatusError{Code: http.StatusServiceUnavailable}
+	notFound := &StatusError{Code: http.StatusNotFound}
+
+	assert.True(t, retryable(true, readErr))
+	assert.True(t, retryable(true, unavailable))
+	assert.False(t, retryable(true, notFound))
+	assert.True(t, retryable(false, dialErr))
+	assert.False(t, retryable(false, readErr))
+	assert.False(t, retryable(false, unavailable))
+}
+
+func TestConditionalUpdateNotRetried(t *testing.T) {
+	puts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		puts++
+		// The update is committed but the answer is lost.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	cs, err := NewForConfig(&Config{Host: server.URL, MaxRetries: 3, RetryBackoff: time.Millisecond})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	task := &models.TaskInfo{Name: "t-1", Status: "Running", ResourceVersion: 7}
+	assert.Error(t, cs.Tasks("default").UpdateIfUnchanged(ctx, task))
+	assert.Equal(t, 1, puts)
+
+	// An unconditional update is sent again.
+	assert.Error(t, cs.Tasks("default").Update(ctx, task))
+	assert.Equal(t, 5, puts)
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/config"
+)
+
+type Config struct {
+	// Host is the apiserver address, eg. http://127.0.0.1:8080.
+	Host string
+	// BearerToken is sent in the Authorization header of every request.
+	BearerToken string
+	// TLSClientConfig is used for https hosts.
+	TLSClientConfig *tls.Config
+	// CAFile, CertFile and KeyFile build the TLSClientConfig when it is nil,
+	// the client certificate is reloaded when its files change.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// Timeout bounds non watch requests, zero means no timeout.
+	Timeout time.Duration
+	// MaxRetries is the number of retries on network errors and 5xx answers.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled on each retry.
+	RetryBackoff time.Duration
+}
+
+// LoadConfig builds the client config the scheduler components use to reach
+// the apiserver.
+func LoadConfig(cfg *config.Config) *Config {
+	return &Config{
+		Host:         fmt.Sprintf("%s://%s:%s", cfg.ApiServer.ApiScheme, cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort),
+		BearerToken:  cfg.ApiServer.Token,
+		CAFile:       cfg.ApiServer.CAFile,
+		CertFile:     cfg.ApiServer.ClientCertFile,
+		KeyFile:      cfg.ApiServer.ClientKeyFile,
+		Timeout:      30 * time.Second,
+		MaxRetries:   3,
+		RetryBackoff: 500 * time.Millisecond,
+	}
+}
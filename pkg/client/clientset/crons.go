@@ -0,0 +1,114 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type CronList struct {
+	ResourceVersion int64
+	// Continue is set when ListOptions.Limit cut the list.
+	Continue string
+	Items    []models.CronInfo
+}
+
+type CronInterface interface {
+	Create(ctx context.Context, cron *models.CronInfo) error
+	Update(ctx context.Context, cron *models.CronInfo) error
+	Get(ctx context.Context, name string) (*models.CronInfo, error)
+	List(ctx context.Context, opts ListOptions) (*CronList, error)
+	Delete(ctx context.Context, name string) error
+	Watch(ctx context.Context, opts ListOptions) (Watcher, error)
+	Informer(filter string, resyncPeriod time.Duration) *informer.Informer
+	// Trigger asks the controller to run the cron now and returns the name
+	// of the trigger.
+	Trigger(ctx context.Context, name string) (string, error)
+}
+
+type crons struct {
+	client *resourceClient
+}
+
+func newCrons(rest *restClient, namespace string) *crons {
+	return &crons{client: &resourceClient{rest: rest, resource: "crons", decode: decodeCron, namespaced: true, namespace: namespace}}
+}
+
+func decodeCron(info models.Info) (interface{}, error) {
+	cron := &models.CronInfo{}
+	if len(info.Value) == 0 {
+		cron.Namespace, cron.Name = models.SplitKey(info.Key)
+		return cron, nil
+	}
+	err := json.Unmarshal(info.Value, cron)
+	return cron, err
+}
+
+func (c *crons) Create(ctx context.Context, cron *models.CronInfo) error {
+	return c.client.create(ctx, cron.Name, cron)
+}
+
+func (c *crons) Update(ctx context.Context, cron *models.CronInfo) error {
+	return c.client.update(ctx, cron.Name, cron)
+}
+
+func (c *crons) Get(ctx context.Context, name string) (*models.CronInfo, error) {
+	info, err := c.client.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeCron(info)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*models.CronInfo), nil
+}
+
+func (c *crons) List(ctx context.Context, opts ListOptions) (*CronList, error) {
+	page, err := c.client.list(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &CronList{ResourceVersion: page.ResourceVersion, Continue: page.Continue}
+	for _, info := range page.Items {
+		obj, err := decodeCron(info)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *obj.(*models.CronInfo))
+	}
+	return list, nil
+}
+
+func (c *crons) Delete(ctx context.Context, name string) error {
+	return c.client.delete(ctx, name)
+}
+
+func (c *crons) Watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	return c.client.watch(ctx, opts)
+}
+
+func (c *crons) Informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return c.client.informer(filter, resyncPeriod)
+}
+
+func (c *crons) Trigger(ctx context.Context, name string) (string, error) {
+	data, _, err := c.client.rest.do(ctx, "POST", c.client.path(name)+"/trigger", nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var key string
+	if err := json.Unmarshal(data, &key); err != nil {
+		return "", err
+	}
+	return nameFromKey(key), nil
+}
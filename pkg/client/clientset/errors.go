@@ -0,0 +1,70 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StatusError is returned when the apiserver answers with a non 2xx code.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("apiserver returned %d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+func newStatusError(code int, body []byte) *StatusError {
+	e := &StatusError{Code: code}
+
+	var message struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &message); err == nil && message.Message != "" {
+		e.Message = message.Message
+	} else {
+		e.Message = string(body)
+	}
+	return e
+}
+
+func statusCode(err error) int {
+	if e, ok := err.(*StatusError); ok {
+		return e.Code
+	}
+	return 0
+}
+
+// IsNotFound tells whether err means the resource does not exist.
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsConflict tells whether err means the resource already exists or has been
+// modified concurrently.
+func IsConflict(err error) bool {
+	return statusCode(err) == http.StatusConflict
+}
+
+// IsForbidden tells whether err means the request is not allowed, by the
+// role of the caller or the quota of the namespace.
+func IsForbidden(err error) bool {
+	return statusCode(err) == http.StatusForbidden
+}
+
+// IsGone tells whether err means the requested resource version has been
+// compacted.
+func IsGone(err error) bool {
+	return statusCode(err) == http.StatusGone
+}
+
+// IsServerError tells whether err is a 5xx answer of the apiserver.
+func IsServerError(err error) bool {
+	return statusCode(err) >= http.StatusInternalServerError
+}
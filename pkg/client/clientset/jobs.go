@@ -0,0 +1,98 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type JobList struct {
+	ResourceVersion int64
+	// Continue is set when ListOptions.Limit cut the list.
+	Continue string
+	Items    []models.JobInfo
+}
+
+type JobInterface interface {
+	Create(ctx context.Context, job *models.JobInfo) error
+	Update(ctx context.Context, job *models.JobInfo) error
+	Get(ctx context.Context, name string) (*models.JobInfo, error)
+	List(ctx context.Context, opts ListOptions) (*JobList, error)
+	Delete(ctx context.Context, name string) error
+	Watch(ctx context.Context, opts ListOptions) (Watcher, error)
+	Informer(filter string, resyncPeriod time.Duration) *informer.Informer
+}
+
+type jobs struct {
+	client *resourceClient
+}
+
+func newJobs(rest *restClient, namespace string) *jobs {
+	return &jobs{client: &resourceClient{rest: rest, resource: "jobs", decode: decodeJob, namespaced: true, namespace: namespace}}
+}
+
+func decodeJob(info models.Info) (interface{}, error) {
+	job := &models.JobInfo{}
+	if len(info.Value) == 0 {
+		job.Namespace, job.Name = models.SplitKey(info.Key)
+		return job, nil
+	}
+	err := json.Unmarshal(info.Value, job)
+	return job, err
+}
+
+func (c *jobs) Create(ctx context.Context, job *models.JobInfo) error {
+	return c.client.create(ctx, job.Name, job)
+}
+
+func (c *jobs) Update(ctx context.Context, job *models.JobInfo) error {
+	return c.client.update(ctx, job.Name, job)
+}
+
+func (c *jobs) Get(ctx context.Context, name string) (*models.JobInfo, error) {
+	info, err := c.client.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeJob(info)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*models.JobInfo), nil
+}
+
+func (c *jobs) List(ctx context.Context, opts ListOptions) (*JobList, error) {
+	page, err := c.client.list(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &JobList{ResourceVersion: page.ResourceVersion, Continue: page.Continue}
+	for _, info := range page.Items {
+		obj, err := decodeJob(info)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *obj.(*models.JobInfo))
+	}
+	return list, nil
+}
+
+func (c *jobs) Delete(ctx context.Context, name string) error {
+	return c.client.delete(ctx, name)
+}
+
+func (c *jobs) Watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	return c.client.watch(ctx, opts)
+}
+
+func (c *jobs) Informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return c.client.informer(filter, resyncPeriod)
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type NamespaceList struct {
+	ResourceVersion int64
+	// Continue is set when ListOptions.Limit cut the list.
+	Continue string
+	Items    []models.NamespaceInfo
+}
+
+type NamespaceInterface interface {
+	Create(ctx context.Context, namespace *models.NamespaceInfo) error
+	Update(ctx context.Context, namespace *models.NamespaceInfo) error
+	Get(ctx context.Context, name string) (*models.NamespaceInfo, error)
+	List(ctx context.Context, opts ListOptions) (*NamespaceList, error)
+	// Delete deletes an empty namespace.
+	Delete(ctx context.Context, name string) error
+	Watch(ctx context.Context, opts ListOptions) (Watcher, error)
+	Informer(filter string, resyncPeriod time.Duration) *informer.Informer
+}
+
+type namespaces struct {
+	client *resourceClient
+}
+
+func newNamespaces(rest *restClient) *namespaces {
+	return &namespaces{client: &resourceClient{rest: rest, resource: "namespaces", decode: decodeNamespace}}
+}
+
+func decodeNamespace(info models.Info) (interface{}, error) {
+	namespace := &models.NamespaceInfo{}
+	if len(info.Value) == 0 {
+		namespace.Name = nameFromKey(info.Key)
+		return namespace, nil
+	}
+	err := json.Unmarshal(info.Value, namespace)
+	return namespace, err
+}
+
+func (c *namespaces) Create(ctx context.Context, namespace *models.NamespaceInfo) error {
+	return c.client.create(ctx, namespace.Name, namespace)
+}
+
+func (c *namespaces) Update(ctx context.Context, namespace *models.NamespaceInfo) error {
+	return c.client.update(ctx, namespace.Name, namespace)
+}
+
+func (c *namespaces) Get(ctx context.Context, name string) (*models.NamespaceInfo, error) {
+	info, err := c.client.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeNamespace(info)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*models.NamespaceInfo), nil
+}
+
+func (c *namespaces) List(ctx context.Context, opts ListOptions) (*NamespaceList, error) {
+	page, err := c.client.list(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	list := &NamespaceList{ResourceVersion: page.ResourceVersion, Continue: page.Continue}
+	for _, info := range page.Items {
+		obj, err := decodeNamespace(info)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *obj.(*models.NamespaceInfo))
+	}
+	return list, nil
+}
+
+func (c *namespaces) Delete(ctx context.Context, name string) error {
+	return c.client.delete(ctx, name)
+}
+
+func (c *namespaces) Watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	return c.client.watch(ctx, opts)
+}
+
+func (c *namespaces) Informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return c.client.informer(filter, resyncPeriod)
+}
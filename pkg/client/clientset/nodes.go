@@ -0,0 +1,134 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type NodeList struct {
+	ResourceVersion int64
+	// Continue is set when ListOptions.Limit cut the list.
+	Continue string
+	Items    []models.NodeInfo
+}
+
+type NodeInterface interface {
+	// Create registers the node or renews its registration for ttl seconds.
+	Create(ctx context.Context, node *models.NodeInfo, ttl int64) error
+	Get(ctx context.Context, name string) (*models.NodeInfo, error)
+	List(ctx context.Context, opts ListOptions) (*NodeList, error)
+	Delete(ctx context.Context, name string) error
+	Watch(ctx context.Context, opts ListOptions) (Watcher, error)
+	Informer(filter string, resyncPeriod time.Duration) *informer.Informer
+	// Cordon stops scheduling tasks to the node, Uncordon resumes it and
+	// ends a drain.
+	Cordon(ctx context.Context, name string) error
+	Uncordon(ctx context.Context, name string) error
+	// Drain cordons the node, its agent hands back the tasks not started
+	// and those still running after gracePeriod.
+	Drain(ctx context.Context, name string, gracePeriod time.Duration) error
+	// UpdateStatus sets the conditions of the node, it fails with a conflict
+	// when a heartbeat newer than status.LastHeartbeatTime was accepted.
+	UpdateStatus(ctx context.Context, name string, status *models.NodeStatus) error
+}
+
+type nodes struct {
+	client *resourceClient
+}
+
+func newNodes(rest *restClient) *nodes {
+	return &nodes{client: &resourceClient{rest: rest, resource: "nodes", decode: decodeNode}}
+}
+
+func decodeNode(info models.Info) (interface{}, error) {
+	node := &models.NodeInfo{}
+	if len(info.Value) == 0 {
+		// Nodes registered before they had a value.
+		node.Name = nameFromKey(info.Key)
+		return node, nil
+	}
+	err := json.Unmarshal(info.Value, node)
+	return node, err
+}
+
+func (c *nodes) Create(ctx context.Context, node *models.NodeInfo, ttl int64) error {
+	_, err := c.client.write(ctx, "POST", node.Name, node, ttl, 0)
+	return err
+}
+
+func (c *nodes) Get(ctx context.Context, name string) (*models.NodeInfo, error) {
+	info, err := c.client.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeNode(info)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*models.NodeInfo), nil
+}
+
+func (c *nodes) List(ctx context.Context, opts ListOptions) (*NodeList, error) {
+	page, err := c.client.list(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	list := &NodeList{ResourceVersion: page.ResourceVersion, Continue: page.Continue}
+	for _, info := range page.Items {
+		obj, err := decodeNode(info)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *obj.(*models.NodeInfo))
+	}
+	return list, nil
+}
+
+func (c *nodes) Delete(ctx context.Context, name string) error {
+	return c.client.delete(ctx, name)
+}
+
+func (c *nodes) Watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	return c.client.watch(ctx, opts)
+}
+
+func (c *nodes) Informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return c.client.informer(filter, resyncPeriod)
+}
+
+func (c *nodes) action(ctx context.Context, name string, action string, query url.Values, body []byte) error {
+	_, _, err := c.client.rest.do(ctx, "POST", c.client.path(name)+"/"+action, query, body)
+	return err
+}
+
+func (c *nodes) Cordon(ctx context.Context, name string) error {
+	return c.action(ctx, name, "cordon", nil, nil)
+}
+
+func (c *nodes) Uncordon(ctx context.Context, name string) error {
+	return c.action(ctx, name, "uncordon", nil, nil)
+}
+
+func (c *nodes) Drain(ctx context.Context, name string, gracePeriod time.Duration) error {
+	query := url.Values{}
+	query.Set("gracePeriod", strconv.FormatInt(int64(gracePeriod/time.Second), 10))
+	return c.action(ctx, name, "drain", query, nil)
+}
+
+func (c *nodes) UpdateStatus(ctx context.Context, name string, status *models.NodeStatus) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return c.action(ctx, name, "status", nil, body)
+}
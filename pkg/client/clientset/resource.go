@@ -0,0 +1,219 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type ListOptions struct {
+	// Filter is the filter expression of the apiserver, eg. Status=Pending.
+	Filter string
+	// LabelSelector selects on the labels, eg. team=data.
+	LabelSelector string
+	// ResourceVersion resumes a watch right after this revision.
+	ResourceVersion int64
+	// Limit returns one page of at most Limit objects, the list then has a
+	// Continue token for the next page. Zero lists every object.
+	Limit int64
+	// Continue is the token of the previous page.
+	Continue string
+	// SortBy is name, createRevision or status.
+	SortBy string
+}
+
+func (o ListOptions) query(watch bool) url.Values {
+	query := url.Values{}
+	if o.Filter != "" {
+		query.Set("filter", o.Filter)
+	}
+	if o.LabelSelector != "" {
+		query.Set("labelSelector", o.LabelSelector)
+	}
+	if !watch {
+		if o.Limit > 0 {
+			query.Set("limit", strconv.FormatInt(o.Limit, 10))
+		}
+		if o.Continue != "" {
+			query.Set("continue", o.Continue)
+		}
+		if o.SortBy != "" {
+			query.Set("sortBy", o.SortBy)
+		}
+	}
+	if watch {
+		query.Set("watch", "true")
+		if o.ResourceVersion > 0 {
+			query.Set("resourceVersion", strconv.FormatInt(o.ResourceVersion, 10))
+		}
+	}
+	return query
+}
+
+// decodeFunc turns a stored value into a typed object.
+type decodeFunc func(info models.Info) (interface{}, error)
+
+// resourceClient talks to the endpoints of one resource, the typed clients
+// wrap it.
+type resourceClient struct {
+	rest     *restClient
+	resource string
+	decode   decodeFunc
+	// namespaced resources live in namespace, an empty namespace lists and
+	// watches every namespace and reads and writes the default one.
+	namespaced bool
+	namespace  string
+}
+
+func nameFromKey(key string) string {
+	return key[strings.LastIndex(key, "/")+1:]
+}
+
+func (r *resourceClient) path(name string) string {
+	if !r.namespaced {
+		return r.resource + "/" + name
+	}
+	namespace := r.namespace
+	if namespace == "" {
+		namespace = constants.DefaultNamespace
+	}
+	return "namespaces/" + namespace + "/" + r.resource + "/" + name
+}
+
+func (r *resourceClient) collection() string {
+	if !r.namespaced || r.namespace == "" {
+		return r.resource + "/"
+	}
+	return "namespaces/" + r.namespace + "/" + r.resource + "/"
+}
+
+// write sends the resource, it returns the revision the server wrote it at,
+// 0 when the server did not tell.
+func (r *resourceClient) write(ctx context.Context, method string, name string, obj interface{}, ttl int64, resourceVersion int64) (int64, error) {
+	value := ""
+	if obj != nil {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return 0, err
+		}
+		value = string(data)
+	}
+
+	body, err := json.Marshal(models.APIInfo{Info: value, TTL: ttl, ResourceVersion: resourceVersion})
+	if err != nil {
+		return 0, err
+	}
+
+	send := r.rest.do
+	if resourceVersion > 0 {
+		send = r.rest.doConditional
+	}
+	_, header, err := send(ctx, method, r.path(name), nil, body)
+	if err != nil {
+		return 0, err
+	}
+	revision, _ := strconv.ParseInt(header.Get(constants.HeaderResourceVersion), 10, 64)
+	return revision, nil
+}
+
+func (r *resourceClient) create(ctx context.Context, name string, obj interface{}) error {
+	_, err := r.write(ctx, "POST", name, obj, 0, 0)
+	return err
+}
+
+func (r *resourceClient) update(ctx context.Context, name string, obj interface{}) error {
+	_, err := r.write(ctx, "PUT", name, obj, 0, 0)
+	return err
+}
+
+// updateAt updates the resource only if it is still at resourceVersion, it
+// returns the revision of the update.
+func (r *resourceClient) updateAt(ctx context.Context, name string, obj interface{}, resourceVersion int64) (int64, error) {
+	return r.write(ctx, "PUT", name, obj, 0, resourceVersion)
+}
+
+func (r *resourceClient) delete(ctx context.Context, name string) error {
+	_, _, err := r.rest.do(ctx, "DELETE", r.path(name), nil, nil)
+	return err
+}
+
+func (r *resourceClient) get(ctx context.Context, name string) (models.Info, error) {
+	var info models.Info
+
+	data, _, err := r.rest.do(ctx, "GET", r.path(name), nil, nil)
+	if err != nil {
+		return info, err
+	}
+
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+func (r *resourceClient) listPage(ctx context.Context, opts ListOptions) (*models.InfoList, error) {
+	data, _, err := r.rest.do(ctx, "GET", r.collection(), opts.query(false), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.InfoList{}
+	err = json.Unmarshal(data, list)
+	return list, err
+}
+
+// list returns one page when opts.Limit is set, and follows the continue
+// tokens to return every object otherwise.
+func (r *resourceClient) list(ctx context.Context, opts ListOptions) (*models.InfoList, error) {
+	if opts.Limit > 0 {
+		return r.listPage(ctx, opts)
+	}
+
+	var all *models.InfoList
+	for {
+		list, err := r.listPage(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		if all == nil {
+			all = list
+		} else {
+			all.Items = append(all.Items, list.Items...)
+		}
+
+		if list.Continue == "" {
+			break
+		}
+		opts.Continue = list.Continue
+	}
+
+	all.Continue = ""
+	all.RemainingCount = nil
+	all.Count = len(all.Items)
+	return all, nil
+}
+
+func (r *resourceClient) watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	response, err := r.rest.stream(ctx, r.collection(), opts.query(true))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return newStreamWatcher(response.Body, r.decode, cancel), nil
+}
+
+func (r *resourceClient) informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return informer.NewInformerForClient(r.rest.client, r.rest.url(r.collection(), ListOptions{Filter: filter}.query(false)), resyncPeriod)
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/util/tlsutil"
+)
+
+const apiPath = "/api/v1alpha1"
+
+// bearerRoundTripper adds the bearer token to every request.
+type bearerRoundTripper struct {
+	token string
+	rt    http.RoundTripper
+}
+
+func (b *bearerRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	request.Header.Set("Authorization", "Bearer "+b.token)
+	return b.rt.RoundTrip(request)
+}
+
+type restClient struct {
+	base         *url.URL
+	client       *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func newRESTClient(cfg *Config) (*restClient, error) {
+	host := cfg.Host
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	base, err := url.Parse(strings.TrimSuffix(host, "/") + apiPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := cfg.TLSClientConfig
+	if tlsConfig == nil && (cfg.CAFile != "" || cfg.CertFile != "") {
+		reloader, err := tlsutil.NewReloader(cfg.CertFile, cfg.KeyFile, cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = reloader.ClientConfig()
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConnsPerHost:   100,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if cfg.BearerToken != "" {
+		transport = &bearerRoundTripper{token: cfg.BearerToken, rt: transport}
+	}
+
+	retryBackoff := cfg.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = 500 * time.Millisecond
+	}
+
+	return &restClient{
+		base:         base,
+		client:       &http.Client{Transport: transport},
+		timeout:      cfg.Timeout,
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: retryBackoff,
+	}, nil
+}
+
+func (c *restClient) url(path string, query url.Values) string {
+	u := *c.base
+	u.Path = u.Path + "/" + strings.TrimPrefix(path, "/")
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func (c *restClient) doOnce(ctx context.Context, method string, path string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	request, err := http.NewRequest(method, c.url(path, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	response, err := c.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, response.Header, newStatusError(response.StatusCode, data)
+	}
+	return data, response.Header, nil
+}
+
+// isDialError tells whether err is a failure to connect, the request never
+// reached the apiserver.
+func isDialError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// retryable tells whether the failed request may be sent again: the
+// idempotent ones on network errors and 5xx answers, the others, like a
+// create or a conditional update the apiserver may have committed, only when
+// they were not sent.
+func retryable(idempotent bool, err error) bool {
+	if !idempotent {
+		return isDialError(err)
+	}
+	if _, ok := err.(*StatusError); ok {
+		return IsServerError(err)
+	}
+	return true
+}
+
+// do sends the request, GET, PUT and DELETE are retried as idempotent.
+func (c *restClient) do(ctx context.Context, method string, path string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	switch method {
+	case "GET", "PUT", "DELETE":
+		return c.send(ctx, true, method, path, query, body)
+	default:
+		return c.send(ctx, false, method, path, query, body)
+	}
+}
+
+// doConditional sends a request which only succeeds once, like a PUT at a
+// resource version: sent again after it was committed, it would conflict
+// with itself.
+func (c *restClient) doConditional(ctx context.Context, method string, path string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	return c.send(ctx, false, method, path, query, body)
+}
+
+// send sends the request, retrying as retryable tells.
+func (c *restClient) send(ctx context.Context, idempotent bool, method string, path string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	backoff := c.retryBackoff
+	for retry := 0; ; retry++ {
+		data, header, err := c.doOnce(ctx, method, path, query, body)
+		if err == nil || retry >= c.maxRetries || !retryable(idempotent, err) {
+			return data, header, err
+		}
+
+		logger.Warn(ctx, "%s %s failed, retrying in %s: %v", method, path, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// stream opens a long running request whose body is read by the caller.
+func (c *restClient) stream(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	request, err := http.NewRequest("GET", c.url(path, query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		data, _ := ioutil.ReadAll(response.Body)
+		return nil, newStatusError(response.StatusCode, data)
+	}
+	return response, nil
+}
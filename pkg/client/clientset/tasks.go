@@ -0,0 +1,111 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type TaskList struct {
+	ResourceVersion int64
+	// Continue is set when ListOptions.Limit cut the list.
+	Continue string
+	Items    []models.TaskInfo
+}
+
+type TaskInterface interface {
+	Create(ctx context.Context, task *models.TaskInfo) error
+	Update(ctx context.Context, task *models.TaskInfo) error
+	// UpdateIfUnchanged updates the task only if it was not modified since
+	// it was read at task.ResourceVersion, it fails with a conflict
+	// otherwise. task.ResourceVersion is set to the revision of the update.
+	UpdateIfUnchanged(ctx context.Context, task *models.TaskInfo) error
+	Get(ctx context.Context, name string) (*models.TaskInfo, error)
+	List(ctx context.Context, opts ListOptions) (*TaskList, error)
+	Delete(ctx context.Context, name string) error
+	Watch(ctx context.Context, opts ListOptions) (Watcher, error)
+	Informer(filter string, resyncPeriod time.Duration) *informer.Informer
+}
+
+type tasks struct {
+	client *resourceClient
+}
+
+func newTasks(rest *restClient, namespace string) *tasks {
+	return &tasks{client: &resourceClient{rest: rest, resource: "tasks", decode: decodeTask, namespaced: true, namespace: namespace}}
+}
+
+func decodeTask(info models.Info) (interface{}, error) {
+	task := &models.TaskInfo{}
+	if len(info.Value) == 0 {
+		task.Namespace, task.Name = models.SplitKey(info.Key)
+		return task, nil
+	}
+	err := json.Unmarshal(info.Value, task)
+	task.ResourceVersion = info.ModRevision
+	return task, err
+}
+
+func (c *tasks) Create(ctx context.Context, task *models.TaskInfo) error {
+	return c.client.create(ctx, task.Name, task)
+}
+
+func (c *tasks) Update(ctx context.Context, task *models.TaskInfo) error {
+	return c.client.update(ctx, task.Name, task)
+}
+
+func (c *tasks) UpdateIfUnchanged(ctx context.Context, task *models.TaskInfo) error {
+	revision, err := c.client.updateAt(ctx, task.Name, task, task.ResourceVersion)
+	if err == nil {
+		task.ResourceVersion = revision
+	}
+	return err
+}
+
+func (c *tasks) Get(ctx context.Context, name string) (*models.TaskInfo, error) {
+	info, err := c.client.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeTask(info)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*models.TaskInfo), nil
+}
+
+func (c *tasks) List(ctx context.Context, opts ListOptions) (*TaskList, error) {
+	page, err := c.client.list(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &TaskList{ResourceVersion: page.ResourceVersion, Continue: page.Continue}
+	for _, info := range page.Items {
+		obj, err := decodeTask(info)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *obj.(*models.TaskInfo))
+	}
+	return list, nil
+}
+
+func (c *tasks) Delete(ctx context.Context, name string) error {
+	return c.client.delete(ctx, name)
+}
+
+func (c *tasks) Watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	return c.client.watch(ctx, opts)
+}
+
+func (c *tasks) Informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return c.client.informer(filter, resyncPeriod)
+}
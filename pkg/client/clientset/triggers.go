@@ -0,0 +1,52 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// TriggerInterface is used by the controller to consume the triggers
+// created through CronInterface.Trigger.
+type TriggerInterface interface {
+	Delete(ctx context.Context, name string) error
+	Watch(ctx context.Context, opts ListOptions) (Watcher, error)
+	Informer(filter string, resyncPeriod time.Duration) *informer.Informer
+}
+
+type triggers struct {
+	client *resourceClient
+}
+
+func newTriggers(rest *restClient, namespace string) *triggers {
+	return &triggers{client: &resourceClient{rest: rest, resource: "triggers", decode: decodeTrigger, namespaced: true, namespace: namespace}}
+}
+
+func decodeTrigger(info models.Info) (interface{}, error) {
+	trigger := &models.TriggerInfo{}
+	if len(info.Value) == 0 {
+		trigger.Namespace, trigger.Name = models.SplitKey(info.Key)
+		return trigger, nil
+	}
+	err := json.Unmarshal(info.Value, trigger)
+	return trigger, err
+}
+
+func (c *triggers) Delete(ctx context.Context, name string) error {
+	return c.client.delete(ctx, name)
+}
+
+func (c *triggers) Watch(ctx context.Context, opts ListOptions) (Watcher, error) {
+	return c.client.watch(ctx, opts)
+}
+
+func (c *triggers) Informer(filter string, resyncPeriod time.Duration) *informer.Informer {
+	return c.client.informer(filter, resyncPeriod)
+}
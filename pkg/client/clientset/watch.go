@@ -0,0 +1,98 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package clientset
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// Event is a watch event. Object is the typed resource, eg. *models.TaskInfo,
+// or a *StatusError for ERROR events.
+type Event struct {
+	Type   string
+	Object interface{}
+	Info   models.Info
+}
+
+type Watcher interface {
+	// ResultChan is closed when the stream ends or Stop is called.
+	ResultChan() <-chan Event
+	Stop()
+}
+
+type streamWatcher struct {
+	body   io.ReadCloser
+	decode decodeFunc
+	cancel context.CancelFunc
+	result chan Event
+}
+
+func newStreamWatcher(body io.ReadCloser, decode decodeFunc, cancel context.CancelFunc) *streamWatcher {
+	sw := &streamWatcher{
+		body:   body,
+		decode: decode,
+		cancel: cancel,
+		result: make(chan Event),
+	}
+	go sw.receive()
+	return sw
+}
+
+func (sw *streamWatcher) receive() {
+	defer close(sw.result)
+	defer sw.body.Close()
+
+	reader := bufio.NewReader(sw.body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug(nil, "watch read error: %v", err)
+			}
+			return
+		}
+
+		var event models.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			logger.Error(nil, "watch unmarshal error [%v]", err)
+			continue
+		}
+
+		out := Event{Type: event.Event, Info: event.Data}
+		if event.Event == "ERROR" {
+			out.Object = &StatusError{Code: event.Code, Message: event.Message}
+		} else {
+			out.Object, err = sw.decode(event.Data)
+			if err != nil {
+				logger.Error(nil, "watch decode [%s] error [%v]", event.Data.Key, err)
+				continue
+			}
+		}
+
+		sw.result <- out
+		if event.Event == "ERROR" {
+			return
+		}
+	}
+}
+
+func (sw *streamWatcher) ResultChan() <-chan Event {
+	return sw.result
+}
+
+// Stop ends the stream, events not yet received are dropped.
+func (sw *streamWatcher) Stop() {
+	sw.cancel()
+	go func() {
+		for range sw.result {
+		}
+	}()
+}
@@ -4,8 +4,13 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"openpitrix.io/scheduler/pkg/logger"
@@ -48,10 +53,36 @@ func (r ResourceEventHandlerFuncs) OnDelete(obj interface{}) {
 	}
 }
 
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// errGone is returned by watch when the resource version we resume from has
+// been compacted, the informer has to list again.
+var errGone = fmt.Errorf("resource version gone")
+
+// Informer keeps a local store in sync with a resource of the apiserver. It
+// works as a reflector: list, then watch from the listed revision, reconnect
+// with exponential backoff when the stream breaks and list again when the
+// revision has been compacted.
 type Informer struct {
-	url      string
-	handler  ResourceEventHandler
-	stopChan chan string
+	url          string
+	client       *http.Client
+	handler      ResourceEventHandler
+	store        *Indexer
+	resyncPeriod time.Duration
+	revision     int64
+
+	syncedLock sync.RWMutex
+	synced     bool
+
+	startLock sync.Mutex
+	started   bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 var client = &http.Client{
@@ -69,90 +100,370 @@ var client = &http.Client{
 	},
 }
 
-func (i *Informer) watch() {
-	defer close(i.stopChan)
+// resourceURL returns the informer url with watch and resourceVersion set,
+// or the continue token of a list, whatever query the caller passed in.
+func (i *Informer) resourceURL(watch bool, revision int64, continueToken string) (string, error) {
+	u, err := url.Parse(i.url)
+	if err != nil {
+		return "", err
+	}
+
+	params := u.Query()
+	params.Del("watch")
+	params.Del("resourceVersion")
+	params.Del("continue")
+	if watch {
+		params.Set("watch", "true")
+		params.Set("resourceVersion", strconv.FormatInt(revision, 10))
+	}
+	if continueToken != "" {
+		params.Set("continue", continueToken)
+	}
+	u.RawQuery = params.Encode()
+
+	return u.String(), nil
+}
 
-	request, err := http.NewRequest("GET", i.url, nil)
+func (i *Informer) listPage(ctx context.Context, continueToken string) (*models.InfoList, error) {
+	listURL, err := i.resourceURL(false, 0, continueToken)
 	if err != nil {
-		logger.Error(nil, "Informer watch NewRequest error:", err)
-		return
+		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	cancelRoutine := make(chan struct{})
-	defer close(cancelRoutine)
+	request, err := http.NewRequest("GET", listURL, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	request = request.WithContext(ctx)
+	response, err := i.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list %s failed with status %d", listURL, response.StatusCode)
+	}
+
+	list := &models.InfoList{}
+	if err := json.NewDecoder(response.Body).Decode(list); err != nil {
+		return nil, fmt.Errorf("list %s returned invalid data: %v", listURL, err)
+	}
+	return list, nil
+}
+
+// list reads every page, they all come from the snapshot of the first one.
+func (i *Informer) list() error {
+	ctx, cancel := context.WithTimeout(i.ctx, time.Minute)
+	defer cancel()
+
+	var infos []models.Info
+	var revision int64
+	continueToken := ""
+	for {
+		list, err := i.listPage(ctx, continueToken)
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			revision = list.ResourceVersion
+		}
+		infos = append(infos, list.Items...)
+
+		continueToken = list.Continue
+		if continueToken == "" {
+			break
+		}
+	}
+
+	i.replace(infos)
+	i.revision = revision
+
+	return nil
+}
+
+// replace swaps the store content with the listed objects and notifies the
+// handler about the difference.
+func (i *Informer) replace(infos []models.Info) {
+	listed := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		listed[info.Key] = struct{}{}
+	}
+
+	for _, old := range i.store.List() {
+		if _, ok := listed[old.Key]; !ok {
+			i.store.Delete(old.Key)
+			i.handler.OnDelete(old)
+		}
+	}
+
+	for _, info := range infos {
+		old, ok := i.store.Get(info.Key)
+		i.store.Add(info)
+		if !ok {
+			i.handler.OnAdd(info)
+		} else if old.ModRevision != info.ModRevision {
+			i.handler.OnUpdate(old, info)
+		}
+	}
+}
+
+func (i *Informer) handleEvent(event models.Event) error {
+	if event.Event == "ERROR" {
+		if event.Code == http.StatusGone {
+			return errGone
+		}
+		return fmt.Errorf("watch error %d: %s", event.Code, event.Message)
+	}
+
+	info := event.Data
+	if info.ModRevision > i.revision {
+		i.revision = info.ModRevision
+	}
+
+	old, exists := i.store.Get(info.Key)
+
+	switch event.Event {
+	case "ADD", "MODIFY":
+		i.store.Add(info)
+		if exists {
+			i.handler.OnUpdate(old, info)
+		} else {
+			i.handler.OnAdd(info)
+		}
+	case "DELETE":
+		i.store.Delete(info.Key)
+		if len(info.Value) == 0 && exists {
+			info = old
+		}
+		i.handler.OnDelete(info)
+	}
+
+	return nil
+}
+
+func (i *Informer) resync() {
+	for _, info := range i.store.List() {
+		i.handler.OnUpdate(info, info)
+	}
+}
+
+// watch streams events from the current revision until the stream breaks,
+// the informer is stopped or the revision is gone.
+func (i *Informer) watch() error {
+	watchURL, err := i.resourceURL(true, i.revision, "")
+	if err != nil {
+		return err
+	}
 
+	request, err := http.NewRequest("GET", watchURL, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(i.ctx)
+	defer cancel()
+
+	logger.Debug(nil, "watch %s", watchURL)
+
+	response, err := i.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return errGone
+	default:
+		return fmt.Errorf("watch %s failed with status %d", watchURL, response.StatusCode)
+	}
+
+	events := make(chan models.Event)
+	readErr := make(chan error, 1)
 	go func() {
+		reader := bufio.NewReader(response.Body)
 		for {
-			select {
-			case <-i.stopChan:
-				cancel()
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				readErr <- err
 				return
-			case <-cancelRoutine:
+			}
+			var event models.Event
+			if err := json.Unmarshal(line, &event); err != nil {
+				logger.Error(nil, "watch unmarshal error [%v]", err)
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
-	params := request.URL.Query()
-	params.Add("watch", "true")
-	request.URL.RawQuery = params.Encode()
+	var resync <-chan time.Time
+	if i.resyncPeriod > 0 {
+		ticker := time.NewTicker(i.resyncPeriod)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
 
-	logger.Debug(nil, "watch %s", request.URL.RawQuery)
+	for {
+		select {
+		case event := <-events:
+			if err := i.handleEvent(event); err != nil {
+				return err
+			}
+		case <-resync:
+			i.resync()
+		case err := <-readErr:
+			return err
+		case <-i.ctx.Done():
+			return nil
+		}
+	}
+}
 
-	response, err := client.Do(request)
-	if err != nil {
-		logger.Error(nil, "watch get error:", err)
-	} else {
-		defer response.Body.Close()
+func (i *Informer) stopped() bool {
+	select {
+	case <-i.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
 
-		reader := bufio.NewReader(response.Body)
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				logger.Error(nil, "watch read error:", err.Error())
-				break
-			} else {
-				var event models.Event
-				err := json.Unmarshal(line, &event)
-				if err != nil {
-					logger.Error(nil, "watch unmarshal error [%v]", err)
-					continue
-				}
-				switch event.Event {
-				case "ADD":
-					i.handler.OnAdd(event.Data)
-				case "MODIFY":
-					i.handler.OnUpdate(event.Data, event.Data)
-				case "DELETE":
-					i.handler.OnDelete(event.Data)
+// sleep waits for the backoff and returns the next one, or false when the
+// informer has been stopped meanwhile.
+func (i *Informer) sleep(backoff time.Duration) (time.Duration, bool) {
+	jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
+	select {
+	case <-time.After(backoff + jitter):
+	case <-i.ctx.Done():
+		return backoff, false
+	}
+
+	backoff *= 2
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff, true
+}
+
+func (i *Informer) run() {
+	defer close(i.done)
+
+	backoff := minBackoff
+	relist := true
+
+	for !i.stopped() {
+		if relist {
+			if err := i.list(); err != nil {
+				logger.Error(nil, "Informer list [%s] error: %v", i.url, err)
+				var ok bool
+				if backoff, ok = i.sleep(backoff); !ok {
+					return
 				}
+				continue
 			}
+			i.setSynced()
+			relist = false
 		}
 
-		if err != nil {
-			logger.Error(nil, "watch read error:", err.Error())
+		connected := time.Now()
+		err := i.watch()
+		if i.stopped() {
+			return
+		}
+
+		if err == errGone {
+			logger.Info(nil, "Informer [%s] resource version %d gone, relisting", i.url, i.revision)
+			relist = true
+			continue
+		}
+		logger.Error(nil, "Informer watch [%s] error: %v", i.url, err)
+
+		// A stream that has been up for a while resets the backoff.
+		if time.Since(connected) > maxBackoff {
+			backoff = minBackoff
+		}
+		var ok bool
+		if backoff, ok = i.sleep(backoff); !ok {
+			return
 		}
 	}
 }
 
+func (i *Informer) setSynced() {
+	i.syncedLock.Lock()
+	i.synced = true
+	i.syncedLock.Unlock()
+}
+
+// HasSynced tells whether the initial list has been delivered to the handler.
+func (i *Informer) HasSynced() bool {
+	i.syncedLock.RLock()
+	defer i.syncedLock.RUnlock()
+	return i.synced
+}
+
+func (i *Informer) GetStore() *Indexer {
+	return i.store
+}
+
 func (i *Informer) AddEventHandler(handler ResourceEventHandler) {
 	i.handler = handler
 }
 
 func (i *Informer) Start() {
-	go i.watch()
+	if i.handler == nil {
+		i.handler = ResourceEventHandlerFuncs{}
+	}
+
+	i.startLock.Lock()
+	i.started = true
+	i.startLock.Unlock()
+
+	go i.run()
 }
 
+// Stop stops the informer and waits for the running watch to end, an
+// informer stopped before it started never starts.
 func (i *Informer) Stop() {
-	i.stopChan <- "close"
+	i.cancel()
+
+	i.startLock.Lock()
+	started := i.started
+	i.startLock.Unlock()
+
+	if started {
+		<-i.done
+	}
 }
 
 func NewInformer(url string) *Informer {
+	return NewInformerWithResync(url, 0)
+}
+
+// NewInformerWithResync creates an informer that periodically calls OnUpdate
+// for every object of its store, a zero period disables the resync.
+func NewInformerWithResync(url string, resyncPeriod time.Duration) *Informer {
+	return NewInformerForClient(client, url, resyncPeriod)
+}
+
+// NewInformerForClient creates an informer sending its requests through
+// httpClient, which carries the transport security and credentials.
+func NewInformerForClient(httpClient *http.Client, url string, resyncPeriod time.Duration) *Informer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Informer{
-		url:      url,
-		stopChan: make(chan string, 1),
+		url:          url,
+		client:       httpClient,
+		store:        NewIndexer(),
+		resyncPeriod: resyncPeriod,
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
 	}
 }
@@ -0,0 +1,140 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package informer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type recorder struct {
+	sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(format string, a ...interface{}) {
+	r.Lock()
+	r.events = append(r.events, fmt.Sprintf(format, a...))
+	r.Unlock()
+}
+
+func (r *recorder) get() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func TestInformerRelistAndResume(t *testing.T) {
+	var lock sync.Mutex
+	var watches []string
+	lists := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+
+		if r.URL.Query().Get("watch") != "true" {
+			// Two pages, the first one ends with a continue token.
+			if r.URL.Query().Get("continue") == "" {
+				lists++
+				lock.Unlock()
+				json.NewEncoder(w).Encode(models.InfoList{
+					ResourceVersion: 10,
+					Continue:        "next",
+					Items:           []models.Info{{Key: "tasks/a", Value: []byte("v1"), ModRevision: 5}},
+				})
+				return
+			}
+			lock.Unlock()
+			json.NewEncoder(w).Encode(models.InfoList{
+				ResourceVersion: 10,
+				Items:           []models.Info{{Key: "tasks/b", Value: []byte("b1"), ModRevision: 6}},
+			})
+			return
+		}
+
+		rv := r.URL.Query().Get("resourceVersion")
+		watches = append(watches, rv)
+		n := len(watches)
+		lock.Unlock()
+
+		switch n {
+		case 1:
+			// The stream breaks after one event.
+			json.NewEncoder(w).Encode(models.Event{Event: "MODIFY", Data: models.Info{Key: "tasks/a", Value: []byte("v2"), ModRevision: 11}})
+		case 2:
+			json.NewEncoder(w).Encode(models.Event{Event: "ERROR", Code: http.StatusGone})
+		default:
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	rec := &recorder{}
+	i := NewInformer(server.URL + "/api/v1alpha1/tasks/?filter=Status=Pending")
+	i.AddEventHandler(ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			rec.add("add %s", obj.(models.Info).Value)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			rec.add("update %s->%s", oldObj.(models.Info).Value, newObj.(models.Info).Value)
+		},
+		DeleteFunc: func(obj interface{}) {
+			rec.add("delete %s", obj.(models.Info).Value)
+		},
+	})
+	i.Start()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		lock.Lock()
+		n := len(watches)
+		lock.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	i.Stop()
+
+	assert.True(t, i.HasSynced())
+	assert.Equal(t, 2, lists)
+	assert.Equal(t, []string{"10", "11", "10"}, watches)
+	assert.Equal(t, []string{"add v1", "add b1", "update v1->v2", "update v2->v1"}, rec.get())
+}
+
+func TestInformerStopBeforeStart(t *testing.T) {
+	i := NewInformer("http://127.0.0.1:0/api/v1alpha1/tasks/")
+
+	stopped := make(chan struct{})
+	go func() {
+		i.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop of an informer never started blocked")
+	}
+
+	// Started after Stop, it ends at once.
+	i.Start()
+	select {
+	case <-i.done:
+	case <-time.After(time.Second):
+		t.Fatal("informer started after Stop kept running")
+	}
+	assert.False(t, i.HasSynced())
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package informer
+
+import (
+	"sync"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// IndexFunc computes the index values of an object.
+type IndexFunc func(info models.Info) []string
+
+// Indexer is a thread-safe local store of the objects known to an informer,
+// keyed by their etcd key and optionally indexed by custom index functions.
+type Indexer struct {
+	sync.RWMutex
+	items    map[string]models.Info
+	indexers map[string]IndexFunc
+	indices  map[string]map[string]map[string]struct{}
+}
+
+func NewIndexer() *Indexer {
+	return &Indexer{
+		items:    make(map[string]models.Info),
+		indexers: make(map[string]IndexFunc),
+		indices:  make(map[string]map[string]map[string]struct{}),
+	}
+}
+
+// AddIndexer registers an index, existing objects are indexed right away.
+func (s *Indexer) AddIndexer(name string, indexFunc IndexFunc) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.indexers[name] = indexFunc
+	s.indices[name] = make(map[string]map[string]struct{})
+	for key, info := range s.items {
+		s.addToIndex(name, key, info)
+	}
+}
+
+func (s *Indexer) addToIndex(name string, key string, info models.Info) {
+	index := s.indices[name]
+	for _, value := range s.indexers[name](info) {
+		set, ok := index[value]
+		if !ok {
+			set = make(map[string]struct{})
+			index[value] = set
+		}
+		set[key] = struct{}{}
+	}
+}
+
+func (s *Indexer) deleteFromIndices(key string, info models.Info) {
+	for name, indexFunc := range s.indexers {
+		index := s.indices[name]
+		for _, value := range indexFunc(info) {
+			if set, ok := index[value]; ok {
+				delete(set, key)
+				if len(set) == 0 {
+					delete(index, value)
+				}
+			}
+		}
+	}
+}
+
+func (s *Indexer) put(info models.Info) {
+	if old, ok := s.items[info.Key]; ok {
+		s.deleteFromIndices(info.Key, old)
+	}
+	s.items[info.Key] = info
+	for name := range s.indexers {
+		s.addToIndex(name, info.Key, info)
+	}
+}
+
+func (s *Indexer) remove(key string) {
+	if old, ok := s.items[key]; ok {
+		s.deleteFromIndices(key, old)
+		delete(s.items, key)
+	}
+}
+
+// Add inserts or replaces the object stored under its key.
+func (s *Indexer) Add(info models.Info) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.put(info)
+}
+
+// Update is an alias of Add.
+func (s *Indexer) Update(info models.Info) {
+	s.Add(info)
+}
+
+// Delete removes the object stored under key.
+func (s *Indexer) Delete(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.remove(key)
+}
+
+// Replace swaps the whole content of the store.
+func (s *Indexer) Replace(infos []models.Info) {
+	s.Lock()
+	defer s.Unlock()
+
+	for key := range s.items {
+		s.remove(key)
+	}
+	for _, info := range infos {
+		s.put(info)
+	}
+}
+
+func (s *Indexer) Get(key string) (models.Info, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	info, ok := s.items[key]
+	return info, ok
+}
+
+func (s *Indexer) List() []models.Info {
+	s.RLock()
+	defer s.RUnlock()
+
+	list := make([]models.Info, 0, len(s.items))
+	for _, info := range s.items {
+		list = append(list, info)
+	}
+	return list
+}
+
+func (s *Indexer) ListKeys() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	keys := make([]string, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// ByIndex returns the objects whose index name contains value.
+func (s *Indexer) ByIndex(name string, value string) []models.Info {
+	s.RLock()
+	defer s.RUnlock()
+
+	var list []models.Info
+	for key := range s.indices[name][value] {
+		list = append(list, s.items[key])
+	}
+	return list
+}
+
+func (s *Indexer) Len() int {
+	s.RLock()
+	defer s.RUnlock()
+
+	return len(s.items)
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package informer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestIndexer(t *testing.T) {
+	s := NewIndexer()
+	s.AddIndexer("value", func(info models.Info) []string {
+		return []string{string(info.Value)}
+	})
+
+	s.Add(models.Info{Key: "tasks/a", Value: []byte("node1")})
+	s.Add(models.Info{Key: "tasks/b", Value: []byte("node1")})
+	s.Add(models.Info{Key: "tasks/c", Value: []byte("node2")})
+	assert.Equal(t, 3, s.Len())
+	assert.Len(t, s.ByIndex("value", "node1"), 2)
+
+	s.Update(models.Info{Key: "tasks/b", Value: []byte("node2")})
+	assert.Len(t, s.ByIndex("value", "node1"), 1)
+	assert.Len(t, s.ByIndex("value", "node2"), 2)
+
+	s.Delete("tasks/c")
+	_, ok := s.Get("tasks/c")
+	assert.False(t, ok)
+	assert.Len(t, s.ByIndex("value", "node2"), 1)
+
+	s.Replace([]models.Info{{Key: "tasks/d", Value: []byte("node3")}})
+	assert.Equal(t, []string{"tasks/d"}, s.ListKeys())
+	assert.Len(t, s.ByIndex("value", "node1"), 0)
+	assert.Len(t, s.ByIndex("value", "node3"), 1)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/koding/multiconfig"
 
@@ -21,6 +22,11 @@ type Config struct {
 
 	Etcd struct {
 		Endpoints string `default:"127.0.0.1:2379"`
+
+		// CertFile, KeyFile and CAFile turn on TLS to etcd.
+		CertFile string `default:""`
+		KeyFile  string `default:""`
+		CAFile   string `default:""`
 	}
 
 	ApiServer struct {
@@ -29,6 +35,120 @@ type Config struct {
 
 		ApiHost string `default:"localhost"`
 		ApiPort string `default:"8080"`
+		// ApiScheme is the scheme the scheduler components use, http or https.
+		ApiScheme string `default:"http"`
+
+		// TLSCertFile and TLSKeyFile turn on https, ClientCAFile verifies the
+		// client certificates.
+		TLSCertFile  string `default:""`
+		TLSKeyFile   string `default:""`
+		ClientCAFile string `default:""`
+
+		// CAFile verifies the apiserver for the scheduler components, which
+		// present ClientCertFile and ClientKeyFile when they are set.
+		CAFile         string `default:""`
+		ClientCertFile string `default:""`
+		ClientKeyFile  string `default:""`
+
+		// Token is the bearer token the scheduler components send to the
+		// apiserver.
+		Token string `default:""`
+		// TokenFile lists static tokens, one "token,user,group,group..." a line.
+		TokenFile string `default:""`
+		// PolicyFile adds roles and role bindings to the built in ones.
+		PolicyFile string `default:""`
+		// AllowAnonymous lets requests without credentials in as
+		// system:anonymous, which the built in roles grant nothing.
+		AllowAnonymous bool `default:"false"`
+		// TokenTTL and RefreshTokenTTL bound the tokens issued by the apiserver.
+		TokenTTL        time.Duration `default:"1h"`
+		RefreshTokenTTL time.Duration `default:"720h"`
+		// AllowedOrigins are the comma separated CORS origins, none by default.
+		AllowedOrigins string `default:""`
+
+		// AuditLevel is None, Metadata or Request, which also records the
+		// request bodies.
+		AuditLevel string `default:"Metadata"`
+		// AuditLogPath is the JSON lines file of the audit events, rotated
+		// at AuditLogMaxSize megabytes keeping AuditLogMaxBackups old files.
+		AuditLogPath       string `default:""`
+		AuditLogMaxSize    int    `default:"100"`
+		AuditLogMaxBackups int    `default:"5"`
+		// AuditWebhookURL receives every audit event as a JSON POST.
+		AuditWebhookURL string `default:""`
+
+		// ShutdownTimeout bounds the requests still served on shutdown.
+		ShutdownTimeout time.Duration `default:"10s"`
+	}
+
+	Admin struct {
+		// Port serves /metrics, /healthz, /readyz and /version of every
+		// component.
+		Port string `default:"8090"`
+	}
+
+	Informer struct {
+		ResyncPeriod time.Duration `default:"60s"`
+	}
+
+	NodeAgent struct {
+		// NodeID overrides the node ID kept in NodeIDFile, the ID is
+		// generated into the file on the first start.
+		NodeID     string `default:""`
+		NodeIDFile string `default:"/var/lib/scheduler/node-id"`
+		// StateDir keeps the journal of the tasks started by the agent, a
+		// restarted agent adopts the ones still running.
+		StateDir string `default:"/var/lib/scheduler"`
+		// DockerHost enables the docker executor running the tasks with a
+		// container, eg. "unix:///var/run/docker.sock".
+		DockerHost string `default:""`
+		// CgroupParent is the cgroup v2 group holding a group per task run
+		// on the host, the tasks are not limited when it cannot be set up.
+		CgroupParent string `default:"/sys/fs/cgroup/scheduler"`
+		// Capabilities the node reports besides its executors, eg. "gpu".
+		Capabilities string `default:""`
+		// Labels of the node, eg. "zone=a,disk=ssd".
+		Labels string `default:""`
+		// Pool of the node, namespaces may be restricted to some pools.
+		Pool string `default:"default"`
+		// DrainTimeout is how long the running tasks may finish on shutdown,
+		// the ones still running are then killed and handed back.
+		DrainTimeout time.Duration `default:"60s"`
+	}
+
+	Artifact struct {
+		// Store keeps the artifacts of the tasks: a directory the nodes
+		// share, or "s3://bucket/prefix" on Endpoint. Empty disables the
+		// artifacts.
+		Store string `default:""`
+		// Endpoint is the S3 compatible service, eg. "http://minio:9000",
+		// the bucket is in the path of the requests.
+		Endpoint  string `default:"https://s3.amazonaws.com"`
+		Region    string `default:"us-east-1"`
+		AccessKey string `default:""`
+		SecretKey string `default:""`
+	}
+
+	Notification struct {
+		// Attempts bounds the sends of a notification, the first retry
+		// waits Backoff and every next one twice as long.
+		Attempts int           `default:"5"`
+		Backoff  time.Duration `default:"2s"`
+		// SMTPAddr is the host:port of the mail server of the Email
+		// notifications, which are sent from SMTPFrom.
+		SMTPAddr     string `default:""`
+		SMTPFrom     string `default:"scheduler@localhost"`
+		SMTPUsername string `default:""`
+		SMTPPassword string `default:""`
+	}
+
+	Controller struct {
+		GCPeriod time.Duration `default:"60s"`
+		// A ready node whose last heartbeat is older than
+		// NodeMonitorGracePeriod is marked NotReady, the nodes report every
+		// 20s and their entries expire 60s after the last heartbeat.
+		NodeMonitorPeriod      time.Duration `default:"10s"`
+		NodeMonitorGracePeriod time.Duration `default:"40s"`
 	}
 }
 
@@ -79,7 +199,23 @@ func (c *Config) LoadConf() *Config {
 		panic(err)
 	}
 
-	logger.Info(nil, "LoadConf: %+v", config)
+	logger.Info(nil, "LoadConf: %+v", config.redacted())
 
 	return config
 }
+
+// redacted returns a copy of the config whose secrets are hidden, for the
+// logs.
+func (c *Config) redacted() Config {
+	redacted := *c
+	for _, secret := range []*string{
+		&redacted.ApiServer.Token,
+		&redacted.Artifact.SecretKey,
+		&redacted.Notification.SMTPPassword,
+	} {
+		if *secret != "" {
+			*secret = "REDACTED"
+		}
+	}
+	return redacted
+}
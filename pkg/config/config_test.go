@@ -0,0 +1,28 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedacted(t *testing.T) {
+	c := &Config{}
+	c.ApiServer.Token = "bearer-secret"
+	c.Artifact.AccessKey = "minio"
+	c.Artifact.SecretKey = "s3-secret"
+	c.Notification.SMTPPassword = "smtp-secret"
+
+	logged := fmt.Sprintf("%+v", c.redacted())
+	for _, secret := range []string{"bearer-secret", "s3-secret", "smtp-secret"} {
+		assert.NotContains(t, logged, secret)
+	}
+	assert.Contains(t, logged, "AccessKey:minio")
+	// The config itself is left as it was.
+	assert.Equal(t, "bearer-secret", c.ApiServer.Token)
+}
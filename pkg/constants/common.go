@@ -12,6 +12,11 @@ const (
 	EtcdPrefix = "scheduler/"
 )
 
+const (
+	DefaultNamespace = "default"
+	DefaultNodePool  = "default"
+)
+
 const (
 	ServiceName = "Scheduler"
 )
@@ -29,4 +34,29 @@ const (
 	JobIdPrefix = "j-"
 )
 
+const (
+	TriggerIdPrefix = "tr-"
+)
+
+const (
+	TokenIdPrefix = "tk-"
+)
+
+const (
+	NodeIdPrefix = "n-"
+)
+
+const (
+	DefaultSuccessfulJobsHistoryLimit = 3
+	DefaultFailedJobsHistoryLimit     = 1
+)
+
+// OwnerKindCron marks the jobs owned by a cron, which are deleted with it.
+const OwnerKindCron = "Cron"
+
+// TriggerManual marks the jobs created by a trigger rather than the schedule.
+const TriggerManual = "Manual"
+
 const MIME_MERGEPATCH = "application/merge-patch+json"
+
+const HeaderResourceVersion = "X-Resource-Version"
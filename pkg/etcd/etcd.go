@@ -5,6 +5,7 @@
 package etcd
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/coreos/etcd/clientv3"
@@ -15,10 +16,12 @@ type Etcd struct {
 	*clientv3.Client
 }
 
-func Connect(endpoints []string, prefix string) (*Etcd, error) {
+// Connect connects to etcd with TLS when tlsConfig is not nil.
+func Connect(endpoints []string, prefix string, tlsConfig *tls.Config) (*Etcd, error) {
 	cli, err := clientv3.New(clientv3.Config{
 		Endpoints:   endpoints,
 		DialTimeout: 5 * time.Second,
+		TLS:         tlsConfig,
 	})
 	if err != nil {
 		return nil, err
@@ -21,7 +21,7 @@ func TestConnect(t *testing.T) {
 	endpoints := []string{"192.168.0.7:2379"}
 	//endpoints:=[]string{"192.168.0.3:2379"}
 	prefix := "test"
-	e, err := Connect(endpoints, prefix)
+	e, err := Connect(endpoints, prefix, nil)
 	log.Println(e)
 	if err != nil {
 		t.Fatal(err)
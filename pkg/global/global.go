@@ -5,6 +5,7 @@
 package global
 
 import (
+	"crypto/tls"
 	"strings"
 	"sync"
 
@@ -14,6 +15,7 @@ import (
 	"openpitrix.io/scheduler/pkg/constants"
 	"openpitrix.io/scheduler/pkg/etcd"
 	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/util/tlsutil"
 )
 
 type GlobalCfg struct {
@@ -48,7 +50,18 @@ func newGlobalCfg() *GlobalCfg {
 
 func (g *GlobalCfg) openEtcd() *GlobalCfg {
 	endpoints := strings.Split(g.cfg.Etcd.Endpoints, ",")
-	e, err := etcd.Connect(endpoints, constants.EtcdPrefix)
+
+	var tlsConfig *tls.Config
+	if g.cfg.Etcd.CAFile != "" || g.cfg.Etcd.CertFile != "" {
+		reloader, err := tlsutil.NewReloader(g.cfg.Etcd.CertFile, g.cfg.Etcd.KeyFile, g.cfg.Etcd.CAFile)
+		if err != nil {
+			logger.Critical(nil, "Failed to load etcd certificates: %+v", err)
+			panic(err)
+		}
+		tlsConfig = reloader.ClientConfig()
+	}
+
+	e, err := etcd.Connect(endpoints, constants.EtcdPrefix, tlsConfig)
 	if err != nil {
 		logger.Critical(nil, "%+s", "Failed to connect etcd...")
 		panic(err)
@@ -0,0 +1,16 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+// Package metrics holds what the Prometheus metrics of the scheduler
+// components share, they are served by the admin package.
+package metrics
+
+import (
+	"strings"
+
+	"openpitrix.io/scheduler/pkg/constants"
+)
+
+// Namespace prefixes the names of the metrics, eg. scheduler_apiserver_requests_total.
+var Namespace = strings.ToLower(constants.ServiceName)
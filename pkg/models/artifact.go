@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Artifact is a file a task leaves in its working directory, uploaded to
+// the artifact store once the task exited.
+type Artifact struct {
+	Name string `json:"Name"`
+	// Path is relative to the working directory of the task.
+	Path string `json:"Path"`
+}
+
+// InputArtifact is the artifact Name of the job Job in the same namespace,
+// downloaded to Path in the working directory before the task starts.
+type InputArtifact struct {
+	Name string `json:"Name"`
+	Job  string `json:"Job"`
+	Path string `json:"Path"`
+}
+
+// ArtifactInfo is an artifact uploaded to the store.
+type ArtifactInfo struct {
+	Name       string    `json:"Name"`
+	Key        string    `json:"Key"`
+	Size       int64     `json:"Size"`
+	SHA256     string    `json:"SHA256"`
+	UploadTime time.Time `json:"UploadTime"`
+}
+
+// TaskReasonArtifactFailed is the reason of the tasks whose input artifacts
+// could not be downloaded or whose output artifacts could not be uploaded.
+const TaskReasonArtifactFailed = "ArtifactFailed"
@@ -0,0 +1,34 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditLevelNone     = "None"
+	AuditLevelMetadata = "Metadata"
+	AuditLevelRequest  = "Request"
+)
+
+// AuditEvent records a mutating call of the apiserver, a line of the audit
+// log or the body of a POST to the audit webhook.
+type AuditEvent struct {
+	Timestamp time.Time `json:"Timestamp"`
+	Level     string    `json:"Level"`
+	User      string    `json:"User"`
+	Groups    []string  `json:"Groups,omitempty"`
+	SourceIP  string    `json:"SourceIP"`
+	Verb      string    `json:"Verb"`
+	Namespace string    `json:"Namespace,omitempty"`
+	Resource  string    `json:"Resource"`
+	Name      string    `json:"Name,omitempty"`
+	// BodyDigest is the sha256 of the request body, Body is only recorded
+	// at the Request level.
+	BodyDigest string `json:"BodyDigest,omitempty"`
+	Body       string `json:"Body,omitempty"`
+	Code       int    `json:"Code"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// The executors a node agent runs tasks with, reported in the capabilities
+// of the node.
+const (
+	ExecutorProcess = "exec"
+	ExecutorDocker  = "docker"
+)
+
+// ContainerSpec runs the command of a task in a container of Image rather
+// than on the host. An empty Cmd runs the command of the image.
+type ContainerSpec struct {
+	Image  string  `json:"Image"`
+	Mounts []Mount `json:"Mounts,omitempty"`
+	// CPUs and MemoryBytes limit the container, zero means no limit.
+	CPUs        float64 `json:"CPUs,omitempty"`
+	MemoryBytes int64   `json:"MemoryBytes,omitempty"`
+}
+
+// Mount binds the host path Source to Target in the container.
+type Mount struct {
+	Source   string `json:"Source"`
+	Target   string `json:"Target"`
+	ReadOnly bool   `json:"ReadOnly,omitempty"`
+}
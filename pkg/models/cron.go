@@ -5,15 +5,44 @@ import (
 )
 
 type CronInfo struct {
-	Name             string    `json:"Name"`
-	Owner            string    `json:"Owner"`
-	Script           string    `json:"Script"`
-	Cmd              []string  `json:"Cmd"`
-	Status           string    `json:"Status"`
-	LastScheduleTime time.Time `json:"LastScheduleTime"`
+	Name             string            `json:"Name"`
+	Namespace        string            `json:"Namespace,omitempty"`
+	Owner            string            `json:"Owner"`
+	Labels           map[string]string `json:"Labels,omitempty"`
+	Annotations      map[string]string `json:"Annotations,omitempty"`
+	Script           string            `json:"Script"`
+	Cmd              []string          `json:"Cmd"`
+	Container        *ContainerSpec    `json:"Container,omitempty"` // nil runs Cmd on the host
+	Resources        *ResourceLimits   `json:"Resources,omitempty"`
+	Inputs           []InputArtifact   `json:"Inputs,omitempty"`
+	Outputs          []Artifact        `json:"Outputs,omitempty"`
+	Status           string            `json:"Status"`
+	Suspend          bool              `json:"Suspend"`
+	LastScheduleTime time.Time         `json:"LastScheduleTime"`
+	// SuccessfulJobsHistoryLimit and FailedJobsHistoryLimit are the numbers
+	// of finished jobs kept, nil means the defaults of the controller.
+	SuccessfulJobsHistoryLimit *int `json:"SuccessfulJobsHistoryLimit,omitempty"`
+	FailedJobsHistoryLimit     *int `json:"FailedJobsHistoryLimit,omitempty"`
+	// Notifications are passed to the jobs, the cron keeps the deliveries
+	// of the MissedSchedule ones.
+	Notifications []NotificationRule     `json:"Notifications,omitempty"`
+	Deliveries    []NotificationDelivery `json:"Deliveries,omitempty"`
+}
+
+// TriggerInfo asks the controller to run the job of a cron right away.
+type TriggerInfo struct {
+	Name       string    `json:"Name"`
+	Namespace  string    `json:"Namespace,omitempty"`
+	Cron       string    `json:"Cron"`
+	CreateTime time.Time `json:"CreateTime"`
 }
 
 type CronEvent struct {
 	Event    string   `json:"Event"`
 	CronInfo CronInfo `json:"CronInfo"`
 }
+
+type TriggerEvent struct {
+	Event       string      `json:"Event"`
+	TriggerInfo TriggerInfo `json:"TriggerInfo"`
+}
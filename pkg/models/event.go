@@ -11,11 +11,26 @@ type Info struct {
 }
 
 type Event struct {
-	Event string `json:"Event"`
-	Data  Info   `json:"Data"`
+	Event   string `json:"Event"`
+	Data    Info   `json:"Data"`
+	Code    int    `json:"Code,omitempty"`
+	Message string `json:"Message,omitempty"`
+}
+
+// InfoList is a page of a list. Continue is set when there are more objects,
+// it is passed back with the continue parameter to get the next page.
+type InfoList struct {
+	ResourceVersion int64  `json:"ResourceVersion"`
+	Count           int    `json:"Count"`
+	RemainingCount  *int64 `json:"RemainingCount,omitempty"`
+	Continue        string `json:"Continue,omitempty"`
+	Items           []Info `json:"Items"`
 }
 
 type APIInfo struct {
 	Info string `json:"Info"`
 	TTL  int64  `json:"TTL"`
+	// ResourceVersion, when set, makes an update fail with 409 unless the
+	// resource is still at this ModRevision.
+	ResourceVersion int64 `json:"ResourceVersion,omitempty"`
 }
@@ -5,15 +5,46 @@ import (
 )
 
 type JobInfo struct {
-	Name         string    `json:"Name"`
-	Owner        string    `json:"Owner"`
-	Cmd          []string  `json:"Cmd"`
-	Status       string    `json:"Status"`
-	StartTime    time.Time `json:"StartTime"`
-	CompleteTime time.Time `json:"CompleteTime"`
+	Name         string            `json:"Name"`
+	Namespace    string            `json:"Namespace,omitempty"`
+	Owner        string            `json:"Owner"`
+	OwnerKind    string            `json:"OwnerKind,omitempty"` // Cron when Owner names a cron
+	Labels       map[string]string `json:"Labels,omitempty"`
+	Annotations  map[string]string `json:"Annotations,omitempty"`
+	Cmd          []string          `json:"Cmd"`
+	Container    *ContainerSpec    `json:"Container,omitempty"` // nil runs Cmd on the host
+	Resources    *ResourceLimits   `json:"Resources,omitempty"`
+	Inputs       []InputArtifact   `json:"Inputs,omitempty"`
+	Outputs      []Artifact        `json:"Outputs,omitempty"`
+	Artifacts    []ArtifactInfo    `json:"Artifacts,omitempty"` // the Outputs uploaded by the task
+	Status       string            `json:"Status"`
+	Reason       string            `json:"Reason,omitempty"` // why a failed job has no task, eg. QuotaExceeded
+	Trigger      string            `json:"Trigger,omitempty"`
+	StartTime    time.Time         `json:"StartTime"`
+	CompleteTime time.Time         `json:"CompleteTime"`
+	// TTLSecondsAfterFinished deletes the job and its tasks this long after
+	// it finished, nil keeps them.
+	TTLSecondsAfterFinished *int64 `json:"TTLSecondsAfterFinished,omitempty"`
+	// Notifications are sent when the job ends, Deliveries keeps the last
+	// outcomes of sending them.
+	Notifications []NotificationRule     `json:"Notifications,omitempty"`
+	Deliveries    []NotificationDelivery `json:"Deliveries,omitempty"`
+	// ConsecutiveFailures counts the failed jobs of the cron in a row up to
+	// this one, zero for a completed job.
+	ConsecutiveFailures int `json:"ConsecutiveFailures,omitempty"`
 }
 
+// The reasons of the jobs which failed because their task could not be
+// created, QuotaExceeded when the quota of the namespace refused it.
+const (
+	JobReasonQuotaExceeded    = "QuotaExceeded"
+	JobReasonTaskCreateFailed = "TaskCreateFailed"
+)
+
 type JobEvent struct {
 	Event   string  `json:"Event"`
 	JobInfo JobInfo `json:"JobInfo"`
+	// OldStatus is the status of the job before a MODIFY event, which
+	// repeats it when the job did not change status.
+	OldStatus string `json:"OldStatus,omitempty"`
 }
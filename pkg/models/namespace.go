@@ -0,0 +1,49 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package models
+
+import (
+	"strings"
+)
+
+type NamespaceInfo struct {
+	Name        string            `json:"Name"`
+	Labels      map[string]string `json:"Labels,omitempty"`
+	Annotations map[string]string `json:"Annotations,omitempty"`
+	Quota       NamespaceQuota    `json:"Quota"`
+	// NodePools are the pools the tasks of the namespace may run in, empty
+	// means any pool.
+	NodePools []string `json:"NodePools,omitempty"`
+}
+
+// NamespaceQuota limits a namespace, zero means no limit.
+type NamespaceQuota struct {
+	MaxCrons          int `json:"MaxCrons,omitempty"`
+	MaxConcurrentJobs int `json:"MaxConcurrentJobs,omitempty"`
+	MaxTasksPerMinute int `json:"MaxTasksPerMinute,omitempty"`
+}
+
+// AllowsPool tells if the tasks of the namespace may run in pool.
+func (ns *NamespaceInfo) AllowsPool(pool string) bool {
+	if len(ns.NodePools) == 0 {
+		return true
+	}
+	for _, p := range ns.NodePools {
+		if p == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitKey returns the namespace and the name of a namespaced key, eg.
+// crons/default/c-1.
+func SplitKey(key string) (string, string) {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 {
+		return "", parts[len(parts)-1]
+	}
+	return parts[1], parts[2]
+}
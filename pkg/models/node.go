@@ -1,3 +1,86 @@
 package models
 
-import ()
+import "time"
+
+// NodeDrain asks the node agent to hand its tasks back, the ones still
+// running at Deadline are killed.
+type NodeDrain struct {
+	Deadline time.Time `json:"Deadline"`
+}
+
+// The conditions a node reports, each is "True", "False" or "Unknown".
+const (
+	NodeReady          = "Ready"
+	NodeMemoryPressure = "MemoryPressure"
+	NodeDiskPressure   = "DiskPressure"
+)
+
+const (
+	ConditionTrue    = "True"
+	ConditionFalse   = "False"
+	ConditionUnknown = "Unknown"
+)
+
+type NodeCondition struct {
+	Type   string `json:"Type"`
+	Status string `json:"Status"`
+	// LastTransitionTime is when Status last changed.
+	LastTransitionTime time.Time `json:"LastTransitionTime"`
+	Reason             string    `json:"Reason,omitempty"`
+	Message            string    `json:"Message,omitempty"`
+}
+
+// NodeStatus is reported by the agent in every heartbeat, the controller
+// sets Ready to Unknown once the heartbeats stopped.
+type NodeStatus struct {
+	// LastHeartbeatTime is set by the apiserver when it accepts a heartbeat.
+	LastHeartbeatTime time.Time       `json:"LastHeartbeatTime"`
+	Conditions        []NodeCondition `json:"Conditions,omitempty"`
+}
+
+// Condition returns the condition of type conditionType, or nil.
+func (s *NodeStatus) Condition(conditionType string) *NodeCondition {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+type NodeInfo struct {
+	Name        string            `json:"Name"`
+	Labels      map[string]string `json:"Labels,omitempty"`
+	Annotations map[string]string `json:"Annotations,omitempty"`
+	// Pool groups nodes, namespaces are restricted to some pools.
+	Pool string `json:"Pool,omitempty"`
+
+	// The name of a node is its persistent ID, the agent reports the
+	// following facts in every heartbeat.
+	Hostname     string   `json:"Hostname,omitempty"`
+	IPs          []string `json:"IPs,omitempty"`
+	AgentVersion string   `json:"AgentVersion,omitempty"`
+	OS           string   `json:"OS,omitempty"`
+	Kernel       string   `json:"Kernel,omitempty"`
+	Capabilities []string `json:"Capabilities,omitempty"`
+	// Instance identifies the running agent, no other agent may register
+	// the node while its entry is alive.
+	Instance string `json:"Instance,omitempty"`
+
+	// Unschedulable and Drain are set by the operator, the heartbeats of the
+	// node keep them.
+	Unschedulable bool       `json:"Unschedulable,omitempty"`
+	Drain         *NodeDrain `json:"Drain,omitempty"`
+
+	Status *NodeStatus `json:"Status,omitempty"`
+}
+
+// IsReady tells if the node takes tasks as far as its health goes, nodes of
+// agents reporting no conditions are ready while registered.
+func (n *NodeInfo) IsReady() bool {
+	ready := n.Status.Condition(NodeReady)
+	return ready == nil || ready.Status == ConditionTrue
+}
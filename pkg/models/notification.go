@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// The outcomes a notification rule is sent on.
+const (
+	NotifyOnFailure             = "Failure"
+	NotifyOnSuccess             = "Success"
+	NotifyOnConsecutiveFailures = "ConsecutiveFailures"
+	NotifyOnMissedSchedule      = "MissedSchedule"
+)
+
+// NotificationRule sends a notification to Webhook or Email when a job ends
+// as On tells, or when a tick of a cron did not create its job.
+type NotificationRule struct {
+	On string `json:"On"`
+	// Threshold is the number of failures in a row ConsecutiveFailures is
+	// sent on.
+	Threshold int            `json:"Threshold,omitempty"`
+	Webhook   *WebhookTarget `json:"Webhook,omitempty"`
+	Email     *EmailTarget   `json:"Email,omitempty"`
+}
+
+// WebhookTarget posts the notification to URL. Template renders the JSON
+// body with text/template, by default a Slack compatible {"text": ...}.
+type WebhookTarget struct {
+	URL      string            `json:"URL"`
+	Headers  map[string]string `json:"Headers,omitempty"`
+	Template string            `json:"Template,omitempty"`
+}
+
+// EmailTarget mails the notification through the SMTP server of the
+// controller, Subject and Body are text/template.
+type EmailTarget struct {
+	To      []string `json:"To"`
+	Subject string   `json:"Subject,omitempty"`
+	Body    string   `json:"Body,omitempty"`
+}
+
+// NotificationDelivery is the outcome of sending a notification, Error is
+// empty once it was delivered.
+type NotificationDelivery struct {
+	On       string    `json:"On"`
+	Target   string    `json:"Target"`
+	Time     time.Time `json:"Time"`
+	Attempts int       `json:"Attempts"`
+	Error    string    `json:"Error,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// ResourceLimits caps what the processes of a task on the host use, zero
+// means no limit.
+type ResourceLimits struct {
+	CPUs        float64 `json:"CPUs,omitempty"`
+	MemoryBytes int64   `json:"MemoryBytes,omitempty"`
+	Pids        int64   `json:"Pids,omitempty"`
+}
+
+// TaskUsage is what the processes of a finished task used.
+type TaskUsage struct {
+	PeakMemoryBytes int64   `json:"PeakMemoryBytes,omitempty"`
+	CPUSeconds      float64 `json:"CPUSeconds"`
+}
+
+// TaskReasonOOMKilled is the reason of the tasks killed for exceeding their
+// memory limit.
+const TaskReasonOOMKilled = "OOMKilled"
@@ -5,11 +5,36 @@ import (
 )
 
 type TaskInfo struct {
-	Name         string    `json:"Name"`
-	Owner        string    `json:"Owner"`
-	Node         string    `json:"Node"`
-	Cmd          []string  `json:"Cmd"`
-	Status       string    `json:"Status"`
-	StartTime    time.Time `json:"StartTime"`
-	CompleteTime time.Time `json:"CompleteTime"`
+	Name         string            `json:"Name"`
+	Namespace    string            `json:"Namespace,omitempty"`
+	Owner        string            `json:"Owner"`
+	Labels       map[string]string `json:"Labels,omitempty"`
+	Annotations  map[string]string `json:"Annotations,omitempty"`
+	Node         string            `json:"Node"`
+	Cmd          []string          `json:"Cmd"`
+	Container    *ContainerSpec    `json:"Container,omitempty"` // nil runs Cmd on the host
+	Resources    *ResourceLimits   `json:"Resources,omitempty"`
+	Inputs       []InputArtifact   `json:"Inputs,omitempty"`
+	Outputs      []Artifact        `json:"Outputs,omitempty"`
+	Status       string            `json:"Status"`
+	CreateTime   time.Time         `json:"CreateTime"`
+	StartTime    time.Time         `json:"StartTime"`
+	CompleteTime time.Time         `json:"CompleteTime"`
+	ExitCode     int               `json:"ExitCode"`
+	Output       string            `json:"Output,omitempty"`
+	Reason       string            `json:"Reason,omitempty"` // why the task failed besides its exit code
+	Usage        *TaskUsage        `json:"Usage,omitempty"`
+	Artifacts    []ArtifactInfo    `json:"Artifacts,omitempty"` // the Outputs uploaded
+
+	// ResourceVersion is the ModRevision the task was read at, it is not
+	// stored.
+	ResourceVersion int64 `json:"-"`
+}
+
+// Executor returns the executor the node runs the task with.
+func (t *TaskInfo) Executor() string {
+	if t.Container != nil {
+		return ExecutorDocker
+	}
+	return ExecutorProcess
 }
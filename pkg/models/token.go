@@ -0,0 +1,43 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package models
+
+import (
+	"time"
+)
+
+// TokenRequest asks the apiserver to issue a token for User.
+type TokenRequest struct {
+	User   string   `json:"User"`
+	Groups []string `json:"Groups,omitempty"`
+	// TTL of the token in seconds, 0 means the TokenTTL of the apiserver.
+	TTL int64 `json:"TTL,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"RefreshToken"`
+}
+
+// TokenInfo is returned once when a token is issued or refreshed, the
+// apiserver only keeps the digests of the secrets.
+type TokenInfo struct {
+	Id                string    `json:"Id"`
+	Token             string    `json:"Token"`
+	RefreshToken      string    `json:"RefreshToken"`
+	ExpireTime        time.Time `json:"ExpireTime"`
+	RefreshExpireTime time.Time `json:"RefreshExpireTime"`
+}
+
+// TokenRecord is what the apiserver stores of an issued token.
+type TokenRecord struct {
+	Id                string    `json:"Id"`
+	User              string    `json:"User"`
+	Groups            []string  `json:"Groups,omitempty"`
+	TokenHash         string    `json:"TokenHash"`
+	RefreshTokenHash  string    `json:"RefreshTokenHash"`
+	TTL               int64     `json:"TTL"`
+	ExpireTime        time.Time `json:"ExpireTime"`
+	RefreshExpireTime time.Time `json:"RefreshExpireTime"`
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenComma
+	tokenOpenParen
+	tokenCloseParen
+	tokenNot
+	tokenEqual
+	tokenDoubleEqual
+	tokenNotEqual
+	tokenGreater
+	tokenGreaterEqual
+	tokenLess
+	tokenLessEqual
+)
+
+var tokenNames = map[tokenKind]string{
+	tokenEOF:          "end of filter",
+	tokenWord:         "word",
+	tokenString:       "quoted string",
+	tokenComma:        "','",
+	tokenOpenParen:    "'('",
+	tokenCloseParen:   "')'",
+	tokenNot:          "'!'",
+	tokenEqual:        "'='",
+	tokenDoubleEqual:  "'=='",
+	tokenNotEqual:     "'!='",
+	tokenGreater:      "'>'",
+	tokenGreaterEqual: "'>='",
+	tokenLess:         "'<'",
+	tokenLessEqual:    "'<='",
+}
+
+func (k tokenKind) String() string {
+	return tokenNames[k]
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	// pos is the byte offset of the token in the filter.
+	pos int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenWord, tokenString:
+		return fmt.Sprintf("%q", t.value)
+	default:
+		return t.kind.String()
+	}
+}
+
+// SyntaxError reports where a filter could not be parsed.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+const specialChars = ",()=!<>\"'"
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// lex splits a filter into tokens. Words run until a space or a special
+// character, quoted strings may contain anything and use backslash escapes.
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, pos: i})
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpenParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenCloseParen, pos: i})
+			i++
+		case c == '=':
+			if strings.HasPrefix(input[i:], "==") {
+				tokens = append(tokens, token{kind: tokenDoubleEqual, pos: i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokenEqual, pos: i})
+				i++
+			}
+		case c == '!':
+			if strings.HasPrefix(input[i:], "!=") {
+				tokens = append(tokens, token{kind: tokenNotEqual, pos: i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokenNot, pos: i})
+				i++
+			}
+		case c == '>':
+			if strings.HasPrefix(input[i:], ">=") {
+				tokens = append(tokens, token{kind: tokenGreaterEqual, pos: i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokenGreater, pos: i})
+				i++
+			}
+		case c == '<':
+			if strings.HasPrefix(input[i:], "<=") {
+				tokens = append(tokens, token{kind: tokenLessEqual, pos: i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokenLess, pos: i})
+				i++
+			}
+		case c == '"' || c == '\'':
+			start := i
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, &SyntaxError{Pos: start, Msg: "unterminated quoted string"}
+				}
+				if input[i] == c {
+					i++
+					break
+				}
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+				}
+				value.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value.String(), pos: start})
+		default:
+			start := i
+			for i < len(input) && !isSpace(input[i]) && strings.IndexByte(specialChars, input[i]) < 0 {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: input[start:i], pos: start})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
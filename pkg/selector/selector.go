@@ -0,0 +1,500 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+// Package selector implements the filter language of the list and watch
+// endpoints. A filter is a comma separated list of requirements which must
+// all match:
+//
+//	Status=Failed                     equality, == is the same
+//	Status!=Completed                 inequality
+//	Status in (Failed, Completed)     set membership
+//	Owner notin (c-1, c-2)            set exclusion
+//	Node                              existence
+//	!Node                             non existence
+//	ExitCode>0                        numeric comparison, also >=, < and <=
+//	StartTime>now-24h                 time comparison, RFC 3339 or now[+-]duration
+//	Labels.app=web                    dotted path to a nested field
+//
+// An array matches when any of its elements matches, eg. Cmd=curl. Values
+// with spaces or special characters are quoted: Name="a, b". A missing value
+// is empty: Node= is Node="".
+package selector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Operator string
+
+const (
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	GreaterThan  Operator = ">"
+	GreaterEqual Operator = ">="
+	LessThan     Operator = "<"
+	LessEqual    Operator = "<="
+)
+
+// Requirement is one condition of a selector.
+type Requirement struct {
+	Path     []string
+	Operator Operator
+	Values   []string
+	// bound is the parsed value of the comparison operators.
+	bound bound
+}
+
+// Selector matches the objects satisfying all its requirements, the empty
+// selector matches everything.
+type Selector []Requirement
+
+// Parse parses a filter, a *SyntaxError tells where it is invalid.
+func Parse(filter string) (Selector, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	return p.parse()
+}
+
+// ParseLabels parses a label selector, a filter whose fields are the keys of
+// the Labels map of the objects. Label keys may contain dots, eg.
+// "team=data,app.kubernetes.io/name in (web, api),!deprecated".
+func ParseLabels(labelSelector string) (Selector, error) {
+	s, err := Parse(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	for i := range s {
+		s[i].Path = []string{"Labels", strings.Join(s[i].Path, ".")}
+	}
+	return s, nil
+}
+
+// Everything returns the selector matching every object.
+func Everything() Selector {
+	return Selector{}
+}
+
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// Matches tells whether a decoded JSON object satisfies the selector.
+func (s Selector) Matches(object interface{}) bool {
+	for _, r := range s {
+		if !r.Matches(object) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesJSON decodes a JSON object and tells whether it satisfies the
+// selector, an invalid object matches nothing.
+func (s Selector) MatchesJSON(data []byte) bool {
+	if s.Empty() {
+		return true
+	}
+	var object interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return false
+	}
+	return s.Matches(object)
+}
+
+func (s Selector) String() string {
+	var parts []string
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r Requirement) String() string {
+	key := strings.Join(r.Path, ".")
+	switch r.Operator {
+	case Exists:
+		return key
+	case DoesNotExist:
+		return "!" + key
+	case In, NotIn:
+		var values []string
+		for _, v := range r.Values {
+			values = append(values, quote(v))
+		}
+		return fmt.Sprintf("%s %s (%s)", key, r.Operator, strings.Join(values, ", "))
+	default:
+		return key + string(r.Operator) + quote(r.Values[0])
+	}
+}
+
+// quote quotes a value when it would not be read back as a single word.
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, specialChars+" \t\r\n\\") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// Matches tells whether a decoded JSON object satisfies the requirement.
+func (r Requirement) Matches(object interface{}) bool {
+	values := lookup(object, r.Path)
+
+	switch r.Operator {
+	case Exists:
+		return len(values) > 0
+	case DoesNotExist:
+		return len(values) == 0
+	case Equals, In:
+		return anyEquals(values, r.Values)
+	case NotEquals, NotIn:
+		return !anyEquals(values, r.Values)
+	default:
+		for _, value := range values {
+			if r.bound.compare(r.Operator, value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// lookup returns the values at path, arrays are expanded at every step so
+// that a path may reach the fields of the elements of an array.
+func lookup(object interface{}, path []string) []interface{} {
+	values := expand(object)
+	for _, field := range path {
+		var next []interface{}
+		for _, value := range values {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if v, ok := m[field]; ok {
+				next = append(next, expand(v)...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func expand(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		var values []interface{}
+		for _, e := range v {
+			values = append(values, expand(e)...)
+		}
+		return values
+	default:
+		return []interface{}{v}
+	}
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func anyEquals(values []interface{}, expected []string) bool {
+	for _, value := range values {
+		s := format(value)
+		for _, e := range expected {
+			if s == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bound is the right side of a comparison, a number or a time. A time
+// relative to now is computed when the requirement is evaluated so that a
+// long running watch keeps a sliding window.
+type bound struct {
+	isNumber bool
+	number   float64
+	relative bool
+	offset   time.Duration
+	time     time.Time
+}
+
+func parseBound(value string) (bound, bool) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return bound{isNumber: true, number: n}, true
+	}
+	if value == "now" {
+		return bound{relative: true}, true
+	}
+	if strings.HasPrefix(value, "now+") || strings.HasPrefix(value, "now-") {
+		d, err := time.ParseDuration(value[3:])
+		if err != nil {
+			return bound{}, false
+		}
+		return bound{relative: true, offset: d}, true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return bound{time: t}, true
+	}
+	return bound{}, false
+}
+
+func compareResult(op Operator, c int) bool {
+	switch op {
+	case GreaterThan:
+		return c > 0
+	case GreaterEqual:
+		return c >= 0
+	case LessThan:
+		return c < 0
+	case LessEqual:
+		return c <= 0
+	}
+	return false
+}
+
+// compare compares a field value with the bound, values of another type
+// never match.
+func (b bound) compare(op Operator, value interface{}) bool {
+	if b.isNumber {
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case string:
+			var err error
+			if n, err = strconv.ParseFloat(v, 64); err != nil {
+				return false
+			}
+		default:
+			return false
+		}
+		switch {
+		case n > b.number:
+			return compareResult(op, 1)
+		case n < b.number:
+			return compareResult(op, -1)
+		default:
+			return compareResult(op, 0)
+		}
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return false
+	}
+	limit := b.time
+	if b.relative {
+		limit = time.Now().Add(b.offset)
+	}
+	switch {
+	case t.After(limit):
+		return compareResult(op, 1)
+	case t.Before(limit):
+		return compareResult(op, -1)
+	default:
+		return compareResult(op, 0)
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, a ...interface{}) error {
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *parser) parse() (Selector, error) {
+	selector := Selector{}
+	if p.peek().kind == tokenEOF {
+		return selector, nil
+	}
+
+	for {
+		r, err := p.requirement()
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, r)
+
+		t := p.next()
+		switch t.kind {
+		case tokenEOF:
+			return selector, nil
+		case tokenComma:
+		default:
+			return nil, p.errorf(t, "expected ',' or end of filter, found %s", t)
+		}
+	}
+}
+
+func (p *parser) path() ([]string, error) {
+	t := p.next()
+	if t.kind != tokenWord {
+		return nil, p.errorf(t, "expected a field, found %s", t)
+	}
+	path := strings.Split(t.value, ".")
+	for _, field := range path {
+		if field == "" {
+			return nil, p.errorf(t, "invalid field %q", t.value)
+		}
+	}
+	return path, nil
+}
+
+// value parses a value, it is empty when the separator or the end of the
+// filter follows the operator, eg. Node=,Status=Pending.
+func (p *parser) value() (string, error) {
+	switch p.peek().kind {
+	case tokenComma, tokenCloseParen, tokenEOF:
+		return "", nil
+	}
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenString {
+		return "", p.errorf(t, "expected a value, found %s", t)
+	}
+	return t.value, nil
+}
+
+func (p *parser) requirement() (Requirement, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		path, err := p.path()
+		if err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Path: path, Operator: DoesNotExist}, nil
+	}
+
+	path, err := p.path()
+	if err != nil {
+		return Requirement{}, err
+	}
+	r := Requirement{Path: path}
+
+	// A bare field checks its existence, the separator is left for the
+	// caller.
+	if t := p.peek(); t.kind == tokenEOF || t.kind == tokenComma {
+		r.Operator = Exists
+		return r, nil
+	}
+
+	t := p.next()
+	switch t.kind {
+	case tokenEqual, tokenDoubleEqual, tokenNotEqual:
+		r.Operator = Equals
+		if t.kind == tokenNotEqual {
+			r.Operator = NotEquals
+		}
+		value, err := p.value()
+		if err != nil {
+			return r, err
+		}
+		r.Values = []string{value}
+		return r, nil
+	case tokenGreater, tokenGreaterEqual, tokenLess, tokenLessEqual:
+		r.Operator = map[tokenKind]Operator{
+			tokenGreater:      GreaterThan,
+			tokenGreaterEqual: GreaterEqual,
+			tokenLess:         LessThan,
+			tokenLessEqual:    LessEqual,
+		}[t.kind]
+		vt := p.peek()
+		value, err := p.value()
+		if err != nil {
+			return r, err
+		}
+		b, ok := parseBound(value)
+		if !ok {
+			return r, p.errorf(vt, "expected a number, a RFC 3339 time or now[+-]duration, found %s", vt)
+		}
+		r.Values = []string{value}
+		r.bound = b
+		return r, nil
+	case tokenWord:
+		switch t.value {
+		case "in":
+			r.Operator = In
+		case "notin":
+			r.Operator = NotIn
+		default:
+			return r, p.errorf(t, "expected an operator, found %s", t)
+		}
+		values, err := p.set()
+		if err != nil {
+			return r, err
+		}
+		r.Values = values
+		return r, nil
+	default:
+		return r, p.errorf(t, "expected an operator, found %s", t)
+	}
+}
+
+// set parses the values of in and notin: (a, b, c).
+func (p *parser) set() ([]string, error) {
+	t := p.next()
+	if t.kind != tokenOpenParen {
+		return nil, p.errorf(t, "expected '(', found %s", t)
+	}
+
+	var values []string
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		t := p.next()
+		switch t.kind {
+		case tokenCloseParen:
+			return values, nil
+		case tokenComma:
+		default:
+			return nil, p.errorf(t, "expected ',' or ')', found %s", t)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches(t *testing.T) {
+	job := []byte(`{
+		"Name": "j-1",
+		"Owner": "c-nightly",
+		"Cmd": ["sh", "-c", "backup.sh"],
+		"Status": "Failed",
+		"ExitCode": 2,
+		"Retry": false,
+		"Node": "",
+		"CompleteTime": "` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `",
+		"Labels": {"app": "backup", "tier": "db"},
+		"Mounts": [{"Path": "/data"}, {"Path": "/logs"}]
+	}`)
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{"", true},
+		{"Status=Failed", true},
+		{"Status==Failed", true},
+		{"Status=Completed", false},
+		{"Status!=Completed", true},
+		{"Missing!=x", true},
+		{"Status in (Failed, Completed)", true},
+		{"Status notin (Failed,Completed)", false},
+		{"Owner notin (c-other)", true},
+		{"Cmd=backup.sh", true},
+		{"Cmd=curl", false},
+		{"Cmd!=curl", true},
+		{"Labels.app=backup", true},
+		{"Labels.app=web", false},
+		{"Mounts.Path=/logs", true},
+		{"Labels.tier", true},
+		{"!Labels.owner", true},
+		{"!Labels", false},
+		{"ExitCode>0", true},
+		{"ExitCode>=2", true},
+		{"ExitCode<2", false},
+		{"ExitCode=2", true},
+		{"Retry=false", true},
+		{"Node=''", true},
+		{"Node=", true},
+		{"Node=,Status=Failed", true},
+		{"Node!=,Status=Failed", false},
+		{"CompleteTime>now-24h", true},
+		{"CompleteTime>now-30m", false},
+		{"CompleteTime<2000-01-01T00:00:00Z", false},
+		{"Status=Failed,Owner=c-nightly,CompleteTime>now-24h", true},
+		{"Status=Failed, Owner=c-other", false},
+		{`Name="j-1"`, true},
+		{"Status>0", false},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.filter)
+		if assert.NoError(t, err, test.filter) {
+			assert.Equal(t, test.match, s.MatchesJSON(job), test.filter)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	task := []byte(`{"Name": "t-1", "Labels": {"team": "data", "app.io/name": "etl"}}`)
+
+	tests := []struct {
+		labelSelector string
+		match         bool
+	}{
+		{"team=data", true},
+		{"team in (web, data),app.io/name=etl", true},
+		{"app.io/name!=etl", false},
+		{"!deprecated", true},
+		{"Name=t-1", false},
+	}
+
+	for _, test := range tests {
+		s, err := ParseLabels(test.labelSelector)
+		if assert.NoError(t, err, test.labelSelector) {
+			assert.Equal(t, test.match, s.MatchesJSON(task), test.labelSelector)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		pos    int
+	}{
+		{"=Failed", 0},
+		{"StartTime>", 10},
+		{"StartTime>,Status=Failed", 10},
+		{"Status in Failed", 10},
+		{"Status in (Failed", 17},
+		{"Status in (Failed Completed)", 18},
+		{"Status ~ Failed", 7},
+		{"Status=Failed Owner=x", 14},
+		{"StartTime>yesterday", 10},
+		{`Name="j-1`, 5},
+		{"Labels..app=x", 0},
+		{"Status=Failed,", 14},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.filter)
+		if assert.Error(t, err, test.filter) {
+			assert.Equal(t, test.pos, err.(*SyntaxError).Pos, "%s: %v", test.filter, err)
+		}
+	}
+}
+
+// TestSchedulerFilter parses the filter of the task informer of the
+// scheduler.
+func TestSchedulerFilter(t *testing.T) {
+	s, err := Parse("Node=,Status=Pending")
+	assert.NoError(t, err)
+	assert.Equal(t, `Node="",Status=Pending`, s.String())
+	assert.True(t, s.MatchesJSON([]byte(`{"Name": "t-1", "Node": "", "Status": "Pending"}`)))
+	assert.False(t, s.MatchesJSON([]byte(`{"Name": "t-1", "Node": "n-1", "Status": "Pending"}`)))
+}
+
+func TestString(t *testing.T) {
+	s, err := Parse(`Status in (Failed,"a b"), !Node, ExitCode>=1, Name=x`)
+	assert.NoError(t, err)
+	assert.Equal(t, `Status in (Failed, "a b"),!Node,ExitCode>=1,Name=x`, s.String())
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// auditSink stores the audit events.
+type auditSink interface {
+	write(event *models.AuditEvent) error
+}
+
+type auditSinks []auditSink
+
+func (s auditSinks) write(event *models.AuditEvent) error {
+	var errs []error
+	for _, sink := range s {
+		if err := sink.write(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// rotatingFile appends JSON lines to a file, which is renamed to path.1 when
+// it grows beyond maxSize bytes, path.1 to path.2 and so on up to maxBackups.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if f.maxBackups > 0 {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) write(event *models.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+// auditWebhookQueue is how many events wait for the webhook before new ones
+// are dropped.
+const auditWebhookQueue = 1000
+
+// auditWebhook posts the events to a URL in the background, so that a slow
+// receiver does not slow the apiserver down.
+type auditWebhook struct {
+	url    string
+	client *http.Client
+	events chan *models.AuditEvent
+}
+
+func newAuditWebhook(url string) *auditWebhook {
+	w := &auditWebhook{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		events: make(chan *models.AuditEvent, auditWebhookQueue),
+	}
+	go w.run()
+	return w
+}
+
+func (w *auditWebhook) write(event *models.AuditEvent) error {
+	select {
+	case w.events <- event:
+		return nil
+	default:
+		return fmt.Errorf("audit webhook queue is full, event dropped")
+	}
+}
+
+func (w *auditWebhook) post(event *models.AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *auditWebhook) run() {
+	for event := range w.events {
+		if err := w.post(event); err != nil {
+			logger.Error(nil, "Post audit event of [%s %s/%s] error [%v]", event.Verb, event.Resource, event.Name, err)
+		}
+	}
+}
+
+type auditFilter struct {
+	level string
+	sink  auditSink
+}
+
+// newAuditFilter returns nil when auditing is off, at the None level or
+// without a log file or webhook.
+func newAuditFilter(cfg *config.Config) (*auditFilter, error) {
+	level := cfg.ApiServer.AuditLevel
+	switch level {
+	case models.AuditLevelNone:
+		return nil, nil
+	case models.AuditLevelMetadata, models.AuditLevelRequest:
+	default:
+		return nil, fmt.Errorf("unknown audit level [%s]", level)
+	}
+
+	var sinks auditSinks
+	if cfg.ApiServer.AuditLogPath != "" {
+		file, err := newRotatingFile(cfg.ApiServer.AuditLogPath, int64(cfg.ApiServer.AuditLogMaxSize)<<20, cfg.ApiServer.AuditLogMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, file)
+	}
+	if cfg.ApiServer.AuditWebhookURL != "" {
+		sinks = append(sinks, newAuditWebhook(cfg.ApiServer.AuditWebhookURL))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return &auditFilter{level: level, sink: sinks}, nil
+}
+
+func mutating(verb string) bool {
+	switch verb {
+	case VerbCreate, VerbUpdate, VerbPatch, VerbDelete:
+		return true
+	}
+	return false
+}
+
+func sourceIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// Filter records the mutating calls once they are answered, including the
+// ones refused by the authentication or authorization, so it runs before
+// the authFilter.
+func (f *auditFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	attrs := newAttributes(request)
+	if !mutating(attrs.Verb) {
+		chain.ProcessFilter(request, response)
+		return
+	}
+
+	body, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+	request.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	chain.ProcessFilter(request, response)
+
+	event := &models.AuditEvent{
+		Timestamp: time.Now(),
+		Level:     f.level,
+		SourceIP:  sourceIP(request.Request),
+		Verb:      attrs.Verb,
+		Namespace: attrs.Namespace,
+		Resource:  attrs.Resource,
+		Name:      attrs.Name,
+		Code:      response.StatusCode(),
+	}
+	if user, ok := request.Attribute(attributeUser).(*User); ok {
+		event.User, event.Groups = user.Name, user.Groups
+	}
+	if len(body) > 0 {
+		digest := sha256.Sum256(body)
+		event.BodyDigest = "sha256:" + hex.EncodeToString(digest[:])
+		// The bodies of the token calls carry credentials.
+		if f.level == models.AuditLevelRequest && attrs.Resource != "tokens" && attrs.Resource != "tokens/refresh" {
+			event.Body = string(body)
+		}
+	}
+
+	if err := f.sink.write(event); err != nil {
+		logger.Error(nil, "Write audit event of [%s %s/%s] error [%v]", event.Verb, event.Resource, event.Name, err)
+	}
+}
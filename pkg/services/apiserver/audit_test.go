@@ -0,0 +1,103 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+type recordingSink struct {
+	events []*models.AuditEvent
+}
+
+func (s *recordingSink) write(event *models.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestAuditFilter(t *testing.T) {
+	sink := &recordingSink{}
+	audit := &auditFilter{level: models.AuditLevelRequest, sink: sink}
+
+	ws := new(restful.WebService).Path("/api/v1alpha1")
+	ws.Filter(audit.Filter)
+	ws.Filter(func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		request.SetAttribute(attributeUser, &User{Name: "alice", Groups: []string{GroupAuthenticated}})
+		if request.Request.Header.Get("Authorization") == "" {
+			response.WriteHeader(http.StatusForbidden)
+			return
+		}
+		chain.ProcessFilter(request, response)
+	})
+	ws.Route(ws.PUT("/namespaces/{namespace}/crons/{cron_id}").To(func(request *restful.Request, response *restful.Response) {
+		body, _ := ioutil.ReadAll(request.Request.Body)
+		assert.Equal(t, `{"Info": "{}"}`, string(body))
+	}))
+	ws.Route(ws.GET("/namespaces/{namespace}/crons/{cron_id}").To(func(request *restful.Request, response *restful.Response) {}))
+	ws.Route(ws.DELETE("/namespaces/{namespace}/crons/{cron_id}").To(func(request *restful.Request, response *restful.Response) {}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	request := httptest.NewRequest("PUT", "/api/v1alpha1/namespaces/prod/crons/c-1", strings.NewReader(`{"Info": "{}"}`))
+	request.Header.Set("Authorization", "Bearer secret")
+	request.RemoteAddr = "10.0.0.1:1234"
+	container.ServeHTTP(httptest.NewRecorder(), request)
+
+	request = httptest.NewRequest("GET", "/api/v1alpha1/namespaces/prod/crons/c-1", nil)
+	container.ServeHTTP(httptest.NewRecorder(), request)
+
+	request = httptest.NewRequest("DELETE", "/api/v1alpha1/namespaces/prod/crons/c-1", nil)
+	container.ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Len(t, sink.events, 2)
+	event := sink.events[0]
+	assert.Equal(t, "alice", event.User)
+	assert.Equal(t, "10.0.0.1", event.SourceIP)
+	assert.Equal(t, VerbUpdate, event.Verb)
+	assert.Equal(t, "prod", event.Namespace)
+	assert.Equal(t, "crons", event.Resource)
+	assert.Equal(t, "c-1", event.Name)
+	assert.Equal(t, http.StatusOK, event.Code)
+	assert.True(t, strings.HasPrefix(event.BodyDigest, "sha256:"))
+	assert.Equal(t, `{"Info": "{}"}`, event.Body)
+
+	// Refused calls are recorded too.
+	assert.Equal(t, VerbDelete, sink.events[1].Verb)
+	assert.Equal(t, http.StatusForbidden, sink.events[1].Code)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	f, err := newRotatingFile(path, 200, 2)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, f.write(&models.AuditEvent{Verb: VerbCreate, Resource: "crons", Name: "c-1"}))
+	}
+
+	for _, name := range []string{"audit.log", "audit.log.1", "audit.log.2"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.True(t, len(data) <= 200, name)
+		assert.Contains(t, string(data), `"Name":"c-1"`)
+	}
+	_, err = os.Stat(filepath.Join(dir, "audit.log.3"))
+	assert.True(t, os.IsNotExist(err))
+}
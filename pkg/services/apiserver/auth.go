@@ -0,0 +1,239 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/logger"
+)
+
+const (
+	// GroupMasters may do anything.
+	GroupMasters = "system:masters"
+	// GroupNodes are the node agents, their user is NodeUserPrefix + node name.
+	GroupNodes     = "system:nodes"
+	NodeUserPrefix = "system:node:"
+
+	UserAnonymous = "system:anonymous"
+	// GroupAuthenticated is added to every authenticated user.
+	GroupAuthenticated = "system:authenticated"
+
+	attributeUser = "user"
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+// User is the identity a request is authenticated as.
+type User struct {
+	Name   string
+	Groups []string
+}
+
+func (u *User) inGroup(group string) bool {
+	for _, g := range u.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeName returns the node a node credential belongs to.
+func (u *User) nodeName() (string, bool) {
+	if !u.inGroup(GroupNodes) || !strings.HasPrefix(u.Name, NodeUserPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(u.Name, NodeUserPrefix), true
+}
+
+// Authenticator finds the user of a request. It returns false when the
+// request carries no credential it knows about, and an error when the
+// credential is invalid.
+type Authenticator interface {
+	AuthenticateRequest(request *http.Request) (*User, bool, error)
+}
+
+type unionAuthenticator []Authenticator
+
+func (u unionAuthenticator) AuthenticateRequest(request *http.Request) (*User, bool, error) {
+	for _, a := range u {
+		user, ok, err := a.AuthenticateRequest(request)
+		if err != nil || ok {
+			return user, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
+func bearerToken(request *http.Request) string {
+	auth := strings.TrimSpace(request.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// tokenFileAuthenticator checks bearer tokens against a static list.
+type tokenFileAuthenticator struct {
+	tokens map[string]*User
+}
+
+// newTokenFileAuthenticator reads a file with one "token,user,group..."
+// line per token, empty lines and lines starting with # are skipped.
+func newTokenFileAuthenticator(path string) (*tokenFileAuthenticator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tokens := make(map[string]*User)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("%s:%d: expect token,user[,group...]", path, line)
+		}
+		if _, ok := tokens[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate token", path, line)
+		}
+		tokens[fields[0]] = &User{Name: fields[1], Groups: fields[2:]}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &tokenFileAuthenticator{tokens: tokens}, nil
+}
+
+func (a *tokenFileAuthenticator) AuthenticateRequest(request *http.Request) (*User, bool, error) {
+	token := bearerToken(request)
+	if token == "" {
+		return nil, false, nil
+	}
+	user, ok := a.tokens[token]
+	return user, ok, nil
+}
+
+// x509Authenticator takes the user from the verified client certificate, the
+// common name is the user and the organizations are the groups.
+type x509Authenticator struct{}
+
+func (x509Authenticator) AuthenticateRequest(request *http.Request) (*User, bool, error) {
+	if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 {
+		return nil, false, nil
+	}
+	cert := request.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, false, fmt.Errorf("client certificate has no common name")
+	}
+	return &User{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization}, true, nil
+}
+
+// authFilter authenticates and authorizes the requests of the web service.
+type authFilter struct {
+	authenticator  Authenticator
+	authorizer     *rbacAuthorizer
+	allowAnonymous bool
+}
+
+func newAuthFilter(cfg *config.Config) (*authFilter, error) {
+	authenticators := unionAuthenticator{x509Authenticator{}}
+	if cfg.ApiServer.TokenFile != "" {
+		a, err := newTokenFileAuthenticator(cfg.ApiServer.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	authenticators = append(authenticators, issuedTokenAuthenticator{})
+
+	policy := defaultPolicy()
+	if cfg.ApiServer.PolicyFile != "" {
+		p, err := loadPolicy(cfg.ApiServer.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.Roles = append(policy.Roles, p.Roles...)
+		policy.Bindings = append(policy.Bindings, p.Bindings...)
+	}
+	authorizer, err := newRBACAuthorizer(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authFilter{
+		authenticator:  authenticators,
+		authorizer:     authorizer,
+		allowAnonymous: cfg.ApiServer.AllowAnonymous,
+	}, nil
+}
+
+func (f *authFilter) authenticate(request *http.Request) (*User, error) {
+	user, ok, err := f.authenticator.AuthenticateRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if !f.allowAnonymous {
+			return nil, errUnauthorized
+		}
+		return &User{Name: UserAnonymous}, nil
+	}
+	groups := append([]string{}, user.Groups...)
+	return &User{Name: user.Name, Groups: append(groups, GroupAuthenticated)}, nil
+}
+
+func (f *authFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	attrs := newAttributes(request)
+
+	// The refresh token is the credential of a refresh.
+	if attrs.Resource == "tokens/refresh" {
+		chain.ProcessFilter(request, response)
+		return
+	}
+
+	user, err := f.authenticate(request.Request)
+	if err != nil {
+		logger.Info(nil, "Authenticate [%s %s] error [%v]", request.Request.Method, request.Request.URL.Path, err)
+		response.AddHeader("WWW-Authenticate", `Bearer realm="scheduler"`)
+		response.WriteHeaderAndEntity(http.StatusUnauthorized, Wrap(errUnauthorized))
+		return
+	}
+	// Set before the authorization so that the denied calls are audited with
+	// their user.
+	request.SetAttribute(attributeUser, user)
+
+	if !f.authorizer.authorize(user, attrs) {
+		logger.Info(nil, "Forbid [%s] to [%s] [%s/%s]", user.Name, attrs.Verb, attrs.Resource, attrs.Name)
+		response.WriteHeaderAndEntity(http.StatusForbidden,
+			Wrap(fmt.Errorf("user [%s] cannot %s %s", user.Name, attrs.Verb, attrs.Resource)))
+		return
+	}
+
+	if err := nodeRestriction(user, attrs, request); err != nil {
+		logger.Info(nil, "Forbid [%s] to [%s] [%s/%s]: %v", user.Name, attrs.Verb, attrs.Resource, attrs.Name, err)
+		response.WriteHeaderAndEntity(http.StatusForbidden, Wrap(err))
+		return
+	}
+
+	chain.ProcessFilter(request, response)
+}
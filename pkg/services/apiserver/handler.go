@@ -1,13 +1,16 @@
 package apiserver
 
 import (
-	//"fmt"
-
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
 	"github.com/coreos/etcd/mvcc/mvccpb"
 
 	"github.com/emicklei/go-restful"
@@ -18,6 +21,8 @@ import (
 	"openpitrix.io/scheduler/pkg/global"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/selector"
+	"openpitrix.io/scheduler/pkg/util/idutil"
 )
 
 func parseBool(input string) bool {
@@ -36,118 +41,111 @@ func Wrap(err error) Error {
 	return Error{Message: err.Error()}
 }
 
+// watchersCtx is cancelled on shutdown, which ends every watch stream.
+var watchersCtx, stopWatchers = context.WithCancel(context.Background())
+
 type Watcher struct {
 	key       string
-	filter    string
+	filter    selector.Selector
+	revision  int64
 	storage   map[string][]models.Event
 	eventChan chan models.Event
-	stopChan  chan string
+	ctx       context.Context
+	cancel    context.CancelFunc
 }
 
-func NewWatcher(key string, initValue []models.Info, filter string) *Watcher {
+// NewWatcher creates a watcher starting right after revision. When notifyInit
+// is true the initial values are sent as ADD events, otherwise they are only
+// used to seed the storage, as the client already holds them.
+func NewWatcher(key string, initValue []models.Info, revision int64, filter selector.Selector, notifyInit bool) *Watcher {
 	storage := make(map[string][]models.Event)
 
-	if initValue != nil {
-		for _, info := range initValue {
-			storage[info.Key] = []models.Event{}
-			storage[info.Key] = append(storage[info.Key], models.Event{"ADD", info})
-		}
+	for _, info := range initValue {
+		storage[info.Key] = []models.Event{{Event: "ADD", Data: info}}
 	}
 
-	return &Watcher{
+	ctx, cancel := context.WithCancel(watchersCtx)
+
+	wc := &Watcher{
 		key:       key,
 		filter:    filter,
+		revision:  revision,
 		storage:   storage,
 		eventChan: make(chan models.Event, 10),
-		stopChan:  make(chan string, 1),
-	}
-}
-
-func evaluateRule(rule string, map_value map[string]interface{}) bool {
-	expr := strings.Split(rule, "=")
-	left := ""
-	right := ""
-	if len(expr) == 2 {
-		left = expr[0]
-		right = expr[1]
-	} else if len(expr) == 1 {
-		left = expr[0]
-	} else {
-		return true
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 
-	if val, ok := map_value[left]; ok {
-		if right == val.(string) {
-			return true
-		} else {
-			return false
+	if !notifyInit {
+		for _, v := range wc.storage {
+			if !filterEvent(v[0].Data.Value, wc.filter) {
+				v[0].Event = "DELETE"
+			}
 		}
-	} else {
-		return false
 	}
+
+	return wc
 }
 
-func filterEvent(value []byte, filter string) bool {
-	logger.Info(nil, "filterEvent [%s] [%s]", value, filter)
+func filterEvent(value []byte, filter selector.Selector) bool {
+	logger.Debug(nil, "filterEvent [%s] [%s]", value, filter)
 
 	if len(value) == 0 {
 		return true
 	}
 
-	var map_value map[string]interface{}
+	var object interface{}
 
-	err := json.Unmarshal(value, &map_value)
+	err := json.Unmarshal(value, &object)
 	if err != nil {
 		logger.Error(nil, "filterEvent error [%v]", err)
 		return false
 	}
 
-	if filter == "" {
-		return true
+	return filter.Matches(object)
+}
+
+// requestSelector combines the filter and labelSelector parameters.
+func requestSelector(request *restful.Request) (selector.Selector, error) {
+	filter, err := selector.Parse(request.QueryParameter("filter"))
+	if err != nil {
+		return nil, err
 	}
 
-	result := true
+	labels, err := selector.ParseLabels(request.QueryParameter("labelSelector"))
+	if err != nil {
+		return nil, fmt.Errorf("labelSelector: %v", err)
+	}
 
-	rules := strings.Split(filter, ",")
+	return append(filter, labels...), nil
+}
 
-	for _, rule := range rules {
-		if !evaluateRule(rule, map_value) {
-			result = false
-			break
-		}
+// parseFilter parses the selector of the request, an invalid selector is
+// answered with 400 and the position of the error.
+func parseFilter(fnName string, request *restful.Request, response *restful.Response) (selector.Selector, bool) {
+	filter, err := requestSelector(request)
+	if err != nil {
+		logger.Debug(nil, "%s invalid filter %+v.", fnName, err)
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return nil, false
 	}
-
-	return result
+	return filter, true
 }
 
-func (wc *Watcher) watch() {
+func (wc *Watcher) watch(notifyInit bool) {
 	e := global.GetInstance().GetEtcd()
 
 	defer close(wc.eventChan)
-	defer close(wc.stopChan)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	cancelRoutine := make(chan struct{})
-	defer close(cancelRoutine)
+	opts := append(keyOptions(wc.key), clientv3.WithRev(wc.revision+1))
+	watchRes := e.Watch(wc.ctx, wc.key, opts...)
 
-	watchRes := e.Watch(ctx, wc.key, clientv3.WithPrefix())
-
-	go func() {
-		for {
-			select {
-			case <-wc.stopChan:
-				cancel()
-				return
-			case <-cancelRoutine:
-				return
-			}
-		}
-	}()
-
-	for _, v := range wc.storage {
-		if len(v) > 0 {
+	if notifyInit {
+		for _, v := range wc.storage {
 			if filterEvent(v[0].Data.Value, wc.filter) {
-				wc.eventChan <- v[0]
+				if !wc.send(v[0]) {
+					return
+				}
 			} else {
 				v[0].Event = "DELETE"
 			}
@@ -155,6 +153,20 @@ func (wc *Watcher) watch() {
 	}
 
 	for res := range watchRes {
+		if res.CompactRevision != 0 {
+			logger.Error(nil, "watch [%s] revision %d compacted", wc.key, res.CompactRevision)
+			wc.send(models.Event{
+				Event:   "ERROR",
+				Code:    http.StatusGone,
+				Message: fmt.Sprintf("resource version %d has been compacted", wc.revision),
+			})
+			return
+		}
+		if err := res.Err(); err != nil {
+			logger.Error(nil, "watch [%s] error: %+v", wc.key, err)
+			return
+		}
+
 		for _, ev := range res.Events {
 			if ev.Type == mvccpb.PUT {
 				key := string(ev.Kv.Key)
@@ -197,8 +209,8 @@ func (wc *Watcher) watch() {
 					Event: event,
 					Data:  info,
 				}
-				if notifyWatcher {
-					wc.eventChan <- eventNotify
+				if notifyWatcher && !wc.send(eventNotify) {
+					return
 				}
 
 				wc.storage[key] = append(wc.storage[key], eventNotify)
@@ -233,8 +245,8 @@ func (wc *Watcher) watch() {
 					Event: "DELETE",
 					Data:  info,
 				}
-				if notifyWatcher {
-					wc.eventChan <- eventNotify
+				if notifyWatcher && !wc.send(eventNotify) {
+					return
 				}
 
 				wc.storage[key] = append(wc.storage[key], eventNotify)
@@ -245,41 +257,80 @@ func (wc *Watcher) watch() {
 	logger.Info(nil, "watch ended")
 }
 
+// send delivers the event unless the watcher has been stopped.
+func (wc *Watcher) send(event models.Event) bool {
+	select {
+	case wc.eventChan <- event:
+		return true
+	case <-wc.ctx.Done():
+		return false
+	}
+}
+
 func (wc *Watcher) stop() {
-	wc.stopChan <- "close"
+	wc.cancel()
 }
 
-func listWatch(fnName string, key string, filter string, watch bool, response *restful.Response) {
-	initValue, err := getInfo(key)
+// watchResource streams the events of key, from resourceVersion when it is
+// set or starting with the current objects otherwise.
+func watchResource(fnName string, key string, filter selector.Selector, resourceVersion string, response *restful.Response) {
+	var initValue []models.Info
+	var revision int64
+	var err error
+
+	resume := resourceVersion != ""
+	if resume {
+		// Revision 0 would replay the whole history, a watch from now does
+		// not set a version.
+		revision, err = strconv.ParseInt(resourceVersion, 10, 64)
+		if err != nil || revision <= 0 {
+			logger.Debug(nil, "%s invalid resource version [%s].", fnName, resourceVersion)
+			response.WriteHeaderAndEntity(http.StatusBadRequest, Error{Message: "invalid resource version " + resourceVersion})
+			return
+		}
+		initValue, _, err = getInfoAtRevision(key, revision)
+	} else {
+		initValue, revision, err = getInfo(key)
+	}
+
 	if err != nil {
 		logger.Debug(nil, "%s request data error %+v.", fnName, err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		switch err {
+		case rpctypes.ErrCompacted:
+			response.WriteHeaderAndEntity(http.StatusGone, Wrap(err))
+		case rpctypes.ErrFutureRev:
+			response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		default:
+			response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		}
 		return
 	}
 
-	if watch {
-		watcher := NewWatcher(key, initValue, filter)
-		go watcher.watch()
-
-		notify := response.CloseNotify()
-
-		for {
-			select {
-			case event := <-watcher.eventChan:
-				response.Write([]byte(formatEvent(event) + "\n"))
-				response.Flush()
-				logger.Info(nil, "%s got event [%v]", fnName, event)
-			case <-notify:
-				watcher.stop()
-				logger.Info(nil, "%s disconnected", fnName)
+	response.AddHeader(constants.HeaderResourceVersion, strconv.FormatInt(revision, 10))
+
+	watcher := NewWatcher(key, initValue, revision, filter, !resume)
+	go watcher.watch(!resume)
+
+	resource := strings.SplitN(key, "/", 2)[0]
+	activeWatchers.WithLabelValues(resource).Inc()
+	defer activeWatchers.WithLabelValues(resource).Dec()
+
+	notify := response.CloseNotify()
+
+	for {
+		select {
+		case event, ok := <-watcher.eventChan:
+			if !ok {
+				logger.Info(nil, "%s watch closed", fnName)
 				return
 			}
-		}
-	} else {
-		for _, info := range initValue {
-			if filterEvent(info.Value, filter) {
-				response.Write([]byte(formatInfo(&info) + "\n"))
-			}
+			response.Write([]byte(formatEvent(event) + "\n"))
+			response.Flush()
+			logger.Info(nil, "%s got event [%v]", fnName, event)
+		case <-notify:
+			watcher.stop()
+			logger.Info(nil, "%s disconnected", fnName)
+			return
 		}
 	}
 }
@@ -293,45 +344,47 @@ func formatEvent(event models.Event) string {
 	return string(eventBytes)
 }
 
-func formatInfo(info *models.Info) string {
-	if nil == info {
-		return "{}"
+// keyOptions matches every key of a resource when key is a resource prefix
+// such as "tasks/", and exactly one key otherwise.
+func keyOptions(key string) []clientv3.OpOption {
+	if strings.HasSuffix(key, "/") {
+		return []clientv3.OpOption{clientv3.WithPrefix()}
 	}
+	return []clientv3.OpOption{}
+}
 
-	infoBytes, _ := json.Marshal(*info)
-
-	return string(infoBytes)
+func getInfo(key string) ([]models.Info, int64, error) {
+	return getInfoAtRevision(key, 0)
 }
 
-func getInfo(key string) ([]models.Info, error) {
+// getInfoAtRevision reads the values under key as of revision, 0 means the
+// latest revision. It returns the revision the values were read at.
+func getInfoAtRevision(key string, revision int64) ([]models.Info, int64, error) {
 	ctx := context.Background()
 	e := global.GetInstance().GetEtcd()
 
-	getResp, err := e.Get(ctx, key, clientv3.WithPrefix())
+	opts := keyOptions(key)
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision))
+	}
+
+	getResp, err := e.Get(ctx, key, opts...)
 
 	if err != nil {
 		logger.Error(ctx, "getInfo [%s] from etcd failed: %+v", key, err)
-		return nil, err
+		return nil, 0, err
 	}
 
-	if len(getResp.Kvs) != 0 {
-		var infos []models.Info
-		for _, kv := range getResp.Kvs {
-			info := models.Info{
-				Key:            string(kv.Key),
-				Value:          kv.Value,
-				CreateRevision: kv.CreateRevision,
-				ModRevision:    kv.ModRevision,
-				Version:        kv.Version,
-			}
-
-			infos = append(infos, info)
-		}
+	if revision == 0 {
+		revision = getResp.Header.Revision
+	}
 
-		return infos, nil
-	} else {
-		return nil, nil
+	var infos []models.Info
+	for _, kv := range getResp.Kvs {
+		infos = append(infos, newInfo(kv))
 	}
+
+	return infos, revision, nil
 }
 
 func putInfo(key string, info string, expireTime int64) error {
@@ -363,189 +416,358 @@ func putInfo(key string, info string, expireTime int64) error {
 	}
 }
 
+var (
+	errNotFound      = errors.New("resource not found")
+	errAlreadyExists = errors.New("resource already exists")
+	errConflict      = errors.New("resource has been modified")
+)
+
+// createInfo puts info only if key does not exist yet.
+func createInfo(key string, info string) error {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+
+	resp, err := e.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, info)).
+		Commit()
+	if err != nil {
+		logger.Error(ctx, "createInfo [%s] [%s] to etcd failed: %+v", key, info, err)
+		return err
+	}
+	if !resp.Succeeded {
+		return errAlreadyExists
+	}
+	return nil
+}
+
+// updateInfo puts info only if key already exists and, when resourceVersion
+// is set, was not modified since that revision. It returns the new
+// ModRevision of key.
+func updateInfo(key string, info string, resourceVersion int64) (int64, error) {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+
+	cmp := clientv3.Compare(clientv3.CreateRevision(key), ">", 0)
+	if resourceVersion > 0 {
+		cmp = clientv3.Compare(clientv3.ModRevision(key), "=", resourceVersion)
+	}
+	resp, err := e.Txn(ctx).
+		If(cmp).
+		Then(clientv3.OpPut(key, info)).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		logger.Error(ctx, "updateInfo [%s] [%s] to etcd failed: %+v", key, info, err)
+		return 0, err
+	}
+	if !resp.Succeeded {
+		if resp.Responses[0].GetResponseRange().Count > 0 {
+			return 0, errConflict
+		}
+		return 0, errNotFound
+	}
+	return resp.Header.Revision, nil
+}
+
 func deleteKey(key string) error {
 	ctx := context.Background()
 	e := global.GetInstance().GetEtcd()
 
-	_, err := e.Delete(ctx, key, clientv3.WithPrefix())
+	resp, err := e.Delete(ctx, key)
 
 	if err != nil {
 		logger.Error(ctx, "deleteKey [%s] to etcd failed: %+v", key, err)
 		return err
 	}
-	return err
+	if resp.Deleted == 0 {
+		return errNotFound
+	}
+	return nil
 }
 
-func CreateNode(request *restful.Request, response *restful.Response) {
-	node := request.PathParameter("node_name")
-	nodeInfo := new(models.APIInfo)
+func errorStatus(err error) int {
+	if _, ok := err.(*quotaExceededError); ok {
+		return http.StatusForbidden
+	}
 
-	err := request.ReadEntity(&nodeInfo)
-	if err != nil {
-		logger.Error(nil, "CreateNode request data error %+v.", err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
-		return
+	switch err {
+	case errNotFound, errNamespaceNotFound:
+		return http.StatusNotFound
+	case errAlreadyExists, errConflict, errNodeConflict, errStaleNodeStatus:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
 	}
+}
 
-	ttlValue := nodeInfo.TTL
+func readAPIInfo(fnName string, request *restful.Request, response *restful.Response) (*models.APIInfo, bool) {
+	apiInfo := new(models.APIInfo)
 
-	if ttlValue > constants.TTLMax {
-		ttlValue = constants.TTLMax
+	err := request.ReadEntity(apiInfo)
+	if err != nil {
+		logger.Error(nil, "%s request data error %+v.", fnName, err)
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return nil, false
 	}
+	return apiInfo, true
+}
 
-	if ttlValue < constants.TTLMin {
-		ttlValue = constants.TTLMin
+func createResource(fnName string, key string, request *restful.Request, response *restful.Response) {
+	apiInfo, ok := readAPIInfo(fnName, request, response)
+	if !ok {
+		return
 	}
 
-	key := "nodes/" + node
-
-	err = putInfo(key, nodeInfo.Info, ttlValue)
+	err := createInfo(key, apiInfo.Info)
 	if err != nil {
-		logger.Debug(nil, "CreateNode putInfo error %+v.", err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		logger.Debug(nil, "%s createInfo error %+v.", fnName, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
 		return
 	}
 
-	logger.Debug(nil, "CreateNode success")
+	logger.Debug(nil, "%s success", fnName)
 
-	response.WriteHeaderAndEntity(http.StatusOK, "node")
+	response.WriteHeaderAndEntity(http.StatusOK, key)
 }
 
-func DescribeNodes(request *restful.Request, response *restful.Response) {
-	watch := parseBool(request.QueryParameter("watch"))
-	filter := request.QueryParameter("filter")
-
-	key := "nodes/"
+func updateResource(fnName string, key string, request *restful.Request, response *restful.Response) {
+	apiInfo, ok := readAPIInfo(fnName, request, response)
+	if !ok {
+		return
+	}
 
-	listWatch("DescribeNodes", key, filter, watch, response)
-}
+	revision, err := updateInfo(key, apiInfo.Info, apiInfo.ResourceVersion)
+	if err != nil {
+		logger.Debug(nil, "%s updateInfo error %+v.", fnName, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
 
-func DescribeNode(request *restful.Request, response *restful.Response) {
-	node := request.PathParameter("node_name")
-	watch := parseBool(request.QueryParameter("watch"))
-	filter := request.QueryParameter("filter")
+	logger.Debug(nil, "%s success", fnName)
 
-	key := "nodes/" + node
+	// The writer may update the resource again at this revision.
+	response.AddHeader(constants.HeaderResourceVersion, strconv.FormatInt(revision, 10))
 
-	listWatch("DescribeNodes", key, filter, watch, response)
+	response.WriteHeaderAndEntity(http.StatusOK, key)
 }
 
-func CreateTask(request *restful.Request, response *restful.Response) {
-	task := request.PathParameter("task_name")
-	taskInfo := new(models.APIInfo)
+func describeResource(fnName string, key string, request *restful.Request, response *restful.Response) {
+	watch := parseBool(request.QueryParameter("watch"))
+	resourceVersion := request.QueryParameter("resourceVersion")
+
+	if watch {
+		filter, ok := parseFilter(fnName, request, response)
+		if !ok {
+			return
+		}
+		watchResource(fnName, key, filter, resourceVersion, response)
+		return
+	}
 
-	err := request.ReadEntity(&taskInfo)
+	infos, revision, err := getInfo(key)
 	if err != nil {
-		logger.Error(nil, "CreateTask request data error %+v.", err)
+		logger.Debug(nil, "%s getInfo error %+v.", fnName, err)
 		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
 		return
 	}
+	if len(infos) == 0 {
+		response.WriteHeaderAndEntity(http.StatusNotFound, Wrap(errNotFound))
+		return
+	}
 
-	key := "tasks/" + task
+	response.AddHeader(constants.HeaderResourceVersion, strconv.FormatInt(revision, 10))
+	response.WriteHeaderAndEntity(http.StatusOK, infos[0])
+}
 
-	err = putInfo(key, taskInfo.Info, -1)
+func deleteResource(fnName string, key string, response *restful.Response) {
+	err := deleteKey(key)
 	if err != nil {
-		logger.Debug(nil, "CreateTask putInfo error %+v.", err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		logger.Debug(nil, "%s deleteKey error %+v.", fnName, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
 		return
 	}
 
-	logger.Debug(nil, "CreateTask success")
+	logger.Debug(nil, "%s success", fnName)
 
-	response.WriteHeaderAndEntity(http.StatusOK, "task")
+	response.WriteHeaderAndEntity(http.StatusOK, key)
 }
 
-func DescribeTasks(request *restful.Request, response *restful.Response) {
-	watch := parseBool(request.QueryParameter("watch"))
-	filter := request.QueryParameter("filter")
+func listResource(fnName string, key string, request *restful.Request, response *restful.Response) {
+	if !parseBool(request.QueryParameter("watch")) {
+		listPage(fnName, key, request, response)
+		return
+	}
 
-	key := "tasks/"
+	filter, ok := parseFilter(fnName, request, response)
+	if !ok {
+		return
+	}
+	resourceVersion := request.QueryParameter("resourceVersion")
 
-	listWatch("DescribeTasks", key, filter, watch, response)
+	watchResource(fnName, key, filter, resourceVersion, response)
 }
 
-func CreateJob(request *restful.Request, response *restful.Response) {
-	job := request.PathParameter("job_name")
-	jobInfo := new(models.APIInfo)
+func CreateNode(request *restful.Request, response *restful.Response) {
+	node := request.PathParameter("node_name")
 
-	err := request.ReadEntity(&jobInfo)
-	if err != nil {
-		logger.Error(nil, "CreateJob request data error %+v.", err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+	nodeInfo, ok := readAPIInfo("CreateNode", request, response)
+	if !ok {
 		return
 	}
 
-	key := "jobs/" + job
+	ttlValue := nodeInfo.TTL
+
+	if ttlValue > constants.TTLMax {
+		ttlValue = constants.TTLMax
+	}
+
+	if ttlValue < constants.TTLMin {
+		ttlValue = constants.TTLMin
+	}
 
-	err = putInfo(key, jobInfo.Info, -1)
+	// The heartbeats do not change the fields set by the operator.
+	spec, err := getNodeSpec(node)
 	if err != nil {
-		logger.Debug(nil, "CreateJob putInfo error %+v.", err)
 		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
 		return
 	}
+	value, err := mergeNodeSpec(node, []byte(nodeInfo.Info), spec)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
 
-	logger.Debug(nil, "CreateJob success")
+	err = registerNode(node, value, ttlValue)
+	if err != nil {
+		logger.Info(nil, "CreateNode [%s] error %+v.", node, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
 
-	response.WriteHeaderAndEntity(http.StatusOK, "job")
+	logger.Debug(nil, "CreateNode success")
+
+	response.WriteHeaderAndEntity(http.StatusOK, "node")
 }
 
-func DescribeJobs(request *restful.Request, response *restful.Response) {
-	watch := parseBool(request.QueryParameter("watch"))
-	filter := request.QueryParameter("filter")
+func DescribeNodes(request *restful.Request, response *restful.Response) {
+	listResource("DescribeNodes", "nodes/", request, response)
+}
 
-	key := "jobs/"
+func DescribeNode(request *restful.Request, response *restful.Response) {
+	describeResource("DescribeNode", "nodes/"+request.PathParameter("node_name"), request, response)
+}
 
-	listWatch("DescribeJobs", key, filter, watch, response)
+func DeleteNode(request *restful.Request, response *restful.Response) {
+	deleteResource("DeleteNode", "nodes/"+request.PathParameter("node_name"), response)
 }
 
-func CreateCron(request *restful.Request, response *restful.Response) {
-	cron := request.PathParameter("cron_name")
-	cronInfo := new(models.APIInfo)
+func CreateTask(request *restful.Request, response *restful.Response) {
+	createNamespaced("CreateTask", "tasks", request.PathParameter("task_name"), request, response)
+}
 
-	err := request.ReadEntity(&cronInfo)
-	if err != nil {
-		logger.Error(nil, "CreateCron request data error %+v.", err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
-		return
-	}
+func UpdateTask(request *restful.Request, response *restful.Response) {
+	updateNamespaced("UpdateTask", "tasks", request.PathParameter("task_name"), request, response)
+}
 
-	key := "crons/" + cron
+func DescribeTasks(request *restful.Request, response *restful.Response) {
+	listResource("DescribeTasks", resourcePrefix("tasks", request), request, response)
+}
 
-	err = putInfo(key, cronInfo.Info, -1)
-	if err != nil {
-		logger.Debug(nil, "CreateCron putInfo error %+v.", err)
-		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
-		return
-	}
+func DescribeTask(request *restful.Request, response *restful.Response) {
+	describeResource("DescribeTask", resourceKey("tasks", request, request.PathParameter("task_name")), request, response)
+}
 
-	logger.Debug(nil, "CreateCron success")
+func DeleteTask(request *restful.Request, response *restful.Response) {
+	deleteResource("DeleteTask", resourceKey("tasks", request, request.PathParameter("task_name")), response)
+}
 
-	response.WriteHeaderAndEntity(http.StatusOK, "cron")
+func CreateJob(request *restful.Request, response *restful.Response) {
+	createNamespaced("CreateJob", "jobs", request.PathParameter("job_name"), request, response)
 }
 
-func DescribeCrons(request *restful.Request, response *restful.Response) {
-	watch := parseBool(request.QueryParameter("watch"))
-	filter := request.QueryParameter("filter")
+func UpdateJob(request *restful.Request, response *restful.Response) {
+	updateNamespaced("UpdateJob", "jobs", request.PathParameter("job_name"), request, response)
+}
+
+func DescribeJobs(request *restful.Request, response *restful.Response) {
+	listResource("DescribeJobs", resourcePrefix("jobs", request), request, response)
+}
+
+func DescribeJob(request *restful.Request, response *restful.Response) {
+	describeResource("DescribeJob", resourceKey("jobs", request, request.PathParameter("job_name")), request, response)
+}
+
+func DeleteJob(request *restful.Request, response *restful.Response) {
+	deleteResource("DeleteJob", resourceKey("jobs", request, request.PathParameter("job_name")), response)
+}
+
+func CreateCron(request *restful.Request, response *restful.Response) {
+	createNamespaced("CreateCron", "crons", request.PathParameter("cron_name"), request, response)
+}
 
-	key := "crons/"
+func UpdateCron(request *restful.Request, response *restful.Response) {
+	updateNamespaced("UpdateCron", "crons", request.PathParameter("cron_name"), request, response)
+}
+
+func DescribeCrons(request *restful.Request, response *restful.Response) {
+	listResource("DescribeCrons", resourcePrefix("crons", request), request, response)
+}
 
-	listWatch("DescribeCrons", key, filter, watch, response)
+func DescribeCron(request *restful.Request, response *restful.Response) {
+	describeResource("DescribeCron", resourceKey("crons", request, request.PathParameter("cron_name")), request, response)
 }
 
 func DeleteCrons(request *restful.Request, response *restful.Response) {
+	deleteResource("DeleteCrons", resourceKey("crons", request, request.PathParameter("cron_name")), response)
+}
+
+// TriggerCron records a trigger the controller picks up to run the cron now.
+// The trigger expires if no controller handles it in time.
+func TriggerCron(request *restful.Request, response *restful.Response) {
 	cron := request.PathParameter("cron_name")
 
-	key := "crons/" + cron
+	infos, _, err := getInfo(resourceKey("crons", request, cron))
+	if err != nil {
+		logger.Debug(nil, "TriggerCron getInfo error %+v.", err)
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+	if len(infos) == 0 {
+		response.WriteHeaderAndEntity(http.StatusNotFound, Wrap(errNotFound))
+		return
+	}
 
-	err := deleteKey(key)
+	triggerInfo := models.TriggerInfo{
+		Name:       idutil.GetUuid(constants.TriggerIdPrefix),
+		Namespace:  request.PathParameter("namespace"),
+		Cron:       cron,
+		CreateTime: time.Now(),
+	}
+	value, err := json.Marshal(triggerInfo)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+
+	key := resourceKey("triggers", request, triggerInfo.Name)
 
+	err = putInfo(key, string(value), constants.TTLMax)
 	if err != nil {
-		logger.Debug(nil, "DeleteCrons deleteKey error %+v.", err)
+		logger.Debug(nil, "TriggerCron putInfo error %+v.", err)
 		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
 		return
 	}
 
-	logger.Debug(nil, "DeleteCrons success")
+	logger.Debug(nil, "TriggerCron success")
+
+	response.WriteHeaderAndEntity(http.StatusOK, key)
+}
+
+func DescribeTriggers(request *restful.Request, response *restful.Response) {
+	listResource("DescribeTriggers", resourcePrefix("triggers", request), request, response)
+}
 
-	response.WriteHeaderAndEntity(http.StatusOK, "cron")
+func DeleteTrigger(request *restful.Request, response *restful.Response) {
+	deleteResource("DeleteTrigger", resourceKey("triggers", request, request.PathParameter("trigger_name")), response)
 }
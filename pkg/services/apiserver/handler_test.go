@@ -0,0 +1,63 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/selector"
+)
+
+func TestNewWatcher(t *testing.T) {
+	filter, err := selector.Parse("Status=Running")
+	assert.NoError(t, err)
+	initValue := []models.Info{
+		{Key: "tasks/default/t-1", Value: []byte(`{"Status":"Running"}`), ModRevision: 3},
+		{Key: "tasks/default/t-2", Value: []byte(`{"Status":"Pending"}`), ModRevision: 4},
+	}
+
+	watcher := NewWatcher("tasks/", initValue, 5, filter, true)
+	defer watcher.stop()
+	assert.Equal(t, int64(5), watcher.revision)
+	assert.Equal(t, "ADD", watcher.storage["tasks/default/t-1"][0].Event)
+	assert.Equal(t, "ADD", watcher.storage["tasks/default/t-2"][0].Event)
+
+	// A resumed watch sends no initial events, the objects the client does
+	// not hold are seeded as deleted.
+	resumed := NewWatcher("tasks/", initValue, 5, filter, false)
+	defer resumed.stop()
+	assert.Equal(t, "ADD", resumed.storage["tasks/default/t-1"][0].Event)
+	assert.Equal(t, "DELETE", resumed.storage["tasks/default/t-2"][0].Event)
+	assert.Len(t, resumed.eventChan, 0)
+}
+
+func TestWatcherSend(t *testing.T) {
+	watcher := NewWatcher("tasks/", nil, 0, selector.Everything(), true)
+	for i := 0; i < cap(watcher.eventChan); i++ {
+		assert.True(t, watcher.send(models.Event{Event: "ADD"}))
+	}
+
+	// A stopped watcher does not block on a client which stopped reading.
+	watcher.stop()
+	assert.False(t, watcher.send(models.Event{Event: "ADD"}))
+}
+
+func TestWatchResourceInvalidVersion(t *testing.T) {
+	for _, resourceVersion := range []string{"abc", "-1", "0"} {
+		recorder := httptest.NewRecorder()
+		response := restful.NewResponse(recorder)
+		response.SetRequestAccepts(restful.MIME_JSON)
+
+		watchResource("DescribeTasks", "tasks/", selector.Everything(), resourceVersion, response)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, resourceVersion)
+		assert.Contains(t, recorder.Body.String(), "invalid resource version "+resourceVersion)
+	}
+}
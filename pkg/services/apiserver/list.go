@@ -0,0 +1,350 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/emicklei/go-restful"
+
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/global"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/selector"
+)
+
+const (
+	sortByName           = "name"
+	sortByCreateRevision = "createRevision"
+	sortByStatus         = "status"
+)
+
+var (
+	errInvalidContinue = errors.New("invalid continue token")
+	errInvalidSortBy   = errors.New("invalid sortBy, expected name, createRevision or status")
+	errInvalidLimit    = errors.New("invalid limit")
+)
+
+// continueToken is where the next page of a list starts. All the pages are
+// read at the revision of the first one, so that they form a consistent
+// snapshot. Key is relative to the prefix of the list, the token can not
+// lead out of the resource or namespace it is used with.
+type continueToken struct {
+	Revision       int64  `json:"rev"`
+	SortBy         string `json:"sort"`
+	Key            string `json:"key,omitempty"`
+	CreateRevision int64  `json:"createRev,omitempty"`
+	Offset         int    `json:"offset,omitempty"`
+}
+
+func (t *continueToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeContinue(value string) (*continueToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidContinue
+	}
+	token := &continueToken{}
+	if err := json.Unmarshal(data, token); err != nil || token.Revision <= 0 {
+		return nil, errInvalidContinue
+	}
+	return token, nil
+}
+
+// start is the key the next page starts at under prefix.
+func (t *continueToken) start(prefix string) string {
+	return prefix + t.Key
+}
+
+type listOptions struct {
+	filter selector.Selector
+	limit  int64
+	sortBy string
+	token  *continueToken
+}
+
+func parseListOptions(request *restful.Request) (*listOptions, error) {
+	filter, err := requestSelector(request)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &listOptions{
+		filter: filter,
+		limit:  constants.DefaultSelectLimit,
+		sortBy: request.QueryParameter("sortBy"),
+	}
+
+	if limit := request.QueryParameter("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || value < 0 {
+			return nil, errInvalidLimit
+		}
+		if value > 0 {
+			opts.limit = value
+		}
+	}
+
+	if value := request.QueryParameter("continue"); value != "" {
+		token, err := decodeContinue(value)
+		if err != nil {
+			return nil, err
+		}
+		if opts.sortBy == "" {
+			opts.sortBy = token.SortBy
+		}
+		if opts.sortBy != token.SortBy {
+			return nil, errInvalidContinue
+		}
+		opts.token = token
+	}
+
+	switch opts.sortBy {
+	case "":
+		opts.sortBy = sortByName
+	case sortByName, sortByCreateRevision, sortByStatus:
+	default:
+		return nil, errInvalidSortBy
+	}
+
+	return opts, nil
+}
+
+func newInfo(kv *mvccpb.KeyValue) models.Info {
+	return models.Info{
+		Key:            string(kv.Key),
+		Value:          kv.Value,
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+	}
+}
+
+// listInfo reads one page of the resource under prefix.
+func listInfo(prefix string, opts *listOptions) (*models.InfoList, error) {
+	switch opts.sortBy {
+	case sortByCreateRevision:
+		return listByCreateRevision(prefix, opts)
+	case sortByStatus:
+		return listByStatus(prefix, opts)
+	default:
+		return listByName(prefix, opts)
+	}
+}
+
+// listByName pages through the keys in order, fetching batches until the page
+// is full of objects matching the filter.
+func listByName(prefix string, opts *listOptions) (*models.InfoList, error) {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+
+	start := prefix
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	var revision int64
+	if opts.token != nil {
+		start = opts.token.start(prefix)
+		revision = opts.token.Revision
+	}
+
+	list := &models.InfoList{Items: []models.Info{}}
+	for {
+		getOpts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(opts.limit)}
+		if revision > 0 {
+			getOpts = append(getOpts, clientv3.WithRev(revision))
+		}
+		resp, err := e.Get(ctx, start, getOpts...)
+		if err != nil {
+			return nil, err
+		}
+		if revision == 0 {
+			revision = resp.Header.Revision
+		}
+
+		for i, kv := range resp.Kvs {
+			start = string(kv.Key) + "\x00"
+			if !filterEvent(kv.Value, opts.filter) {
+				continue
+			}
+			list.Items = append(list.Items, newInfo(kv))
+			if int64(len(list.Items)) == opts.limit {
+				if i < len(resp.Kvs)-1 || resp.More {
+					list.Continue = (&continueToken{Revision: revision, SortBy: sortByName, Key: strings.TrimPrefix(start, prefix)}).encode()
+				}
+				break
+			}
+		}
+
+		if list.Continue != "" || !resp.More {
+			break
+		}
+	}
+
+	list.ResourceVersion = revision
+	list.Count = len(list.Items)
+
+	// The remaining count is only cheap to get without a filter.
+	if list.Continue != "" && opts.filter.Empty() {
+		resp, err := e.Get(ctx, start, clientv3.WithRange(end), clientv3.WithRev(revision), clientv3.WithCountOnly())
+		if err != nil {
+			return nil, err
+		}
+		list.RemainingCount = &resp.Count
+	}
+
+	return list, nil
+}
+
+// listByCreateRevision pages through the objects from the oldest to the
+// newest one.
+func listByCreateRevision(prefix string, opts *listOptions) (*models.InfoList, error) {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+
+	var revision, minCreateRevision int64
+	if opts.token != nil {
+		revision = opts.token.Revision
+		minCreateRevision = opts.token.CreateRevision + 1
+	}
+
+	list := &models.InfoList{Items: []models.Info{}}
+	for {
+		getOpts := []clientv3.OpOption{
+			clientv3.WithPrefix(),
+			clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend),
+			clientv3.WithMinCreateRev(minCreateRevision),
+			clientv3.WithLimit(opts.limit),
+		}
+		if revision > 0 {
+			getOpts = append(getOpts, clientv3.WithRev(revision))
+		}
+		resp, err := e.Get(ctx, prefix, getOpts...)
+		if err != nil {
+			return nil, err
+		}
+		if revision == 0 {
+			revision = resp.Header.Revision
+		}
+
+		for i, kv := range resp.Kvs {
+			minCreateRevision = kv.CreateRevision + 1
+			if !filterEvent(kv.Value, opts.filter) {
+				continue
+			}
+			list.Items = append(list.Items, newInfo(kv))
+			if int64(len(list.Items)) == opts.limit {
+				if i < len(resp.Kvs)-1 || resp.More {
+					list.Continue = (&continueToken{Revision: revision, SortBy: sortByCreateRevision, CreateRevision: kv.CreateRevision}).encode()
+				}
+				break
+			}
+		}
+
+		if list.Continue != "" || !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+	}
+
+	list.ResourceVersion = revision
+	list.Count = len(list.Items)
+
+	return list, nil
+}
+
+func statusOf(value []byte) string {
+	var object struct {
+		Status string `json:"Status"`
+	}
+	json.Unmarshal(value, &object)
+	return object.Status
+}
+
+// listByStatus has to read the whole resource since etcd cannot sort by a
+// field of the values, the token keeps the offset in the sorted snapshot.
+func listByStatus(prefix string, opts *listOptions) (*models.InfoList, error) {
+	var revision int64
+	offset := 0
+	if opts.token != nil {
+		revision = opts.token.Revision
+		offset = opts.token.Offset
+	}
+
+	infos, revision, err := getInfoAtRevision(prefix, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []models.Info
+	for _, info := range infos {
+		if filterEvent(info.Value, opts.filter) {
+			matched = append(matched, info)
+		}
+	}
+
+	statuses := make(map[string]string, len(matched))
+	for _, info := range matched {
+		statuses[info.Key] = statusOf(info.Value)
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return statuses[matched[i].Key] < statuses[matched[j].Key]
+	})
+
+	list := &models.InfoList{ResourceVersion: revision, Items: []models.Info{}}
+	if offset < len(matched) {
+		end := offset + int(opts.limit)
+		if end < len(matched) {
+			list.Continue = (&continueToken{Revision: revision, SortBy: sortByStatus, Offset: end}).encode()
+			remaining := int64(len(matched) - end)
+			list.RemainingCount = &remaining
+		} else {
+			end = len(matched)
+		}
+		list.Items = append(list.Items, matched[offset:end]...)
+	}
+	list.Count = len(list.Items)
+
+	return list, nil
+}
+
+func listPage(fnName string, key string, request *restful.Request, response *restful.Response) {
+	opts, err := parseListOptions(request)
+	if err != nil {
+		logger.Debug(nil, "%s invalid list options %+v.", fnName, err)
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+
+	list, err := listInfo(key, opts)
+	if err != nil {
+		logger.Debug(nil, "%s listInfo error %+v.", fnName, err)
+		switch err {
+		case rpctypes.ErrCompacted:
+			// The snapshot of the continue token is gone, the client has to
+			// list from the beginning.
+			response.WriteHeaderAndEntity(http.StatusGone, Wrap(err))
+		case rpctypes.ErrFutureRev:
+			response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		default:
+			response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		}
+		return
+	}
+
+	response.AddHeader(constants.HeaderResourceVersion, strconv.FormatInt(list.ResourceVersion, 10))
+	response.WriteHeaderAndEntity(http.StatusOK, list)
+}
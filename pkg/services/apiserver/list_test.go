@@ -0,0 +1,75 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/constants"
+)
+
+func listRequest(query string) *restful.Request {
+	return restful.NewRequest(httptest.NewRequest("GET", "/api/v1alpha1/namespaces/z/tasks/?"+query, nil))
+}
+
+func TestParseListOptions(t *testing.T) {
+	opts, err := parseListOptions(listRequest(""))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(constants.DefaultSelectLimit), opts.limit)
+	assert.Equal(t, sortByName, opts.sortBy)
+	assert.Nil(t, opts.token)
+
+	opts, err = parseListOptions(listRequest("limit=5&sortBy=status"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), opts.limit)
+	assert.Equal(t, sortByStatus, opts.sortBy)
+
+	// The token keeps the order of the first page.
+	token := (&continueToken{Revision: 7, SortBy: sortByCreateRevision, CreateRevision: 3}).encode()
+	opts, err = parseListOptions(listRequest("continue=" + token))
+	assert.NoError(t, err)
+	assert.Equal(t, sortByCreateRevision, opts.sortBy)
+	assert.Equal(t, &continueToken{Revision: 7, SortBy: sortByCreateRevision, CreateRevision: 3}, opts.token)
+
+	for query, expect := range map[string]error{
+		"limit=-1":                           errInvalidLimit,
+		"limit=abc":                          errInvalidLimit,
+		"sortBy=owner":                       errInvalidSortBy,
+		"sortBy=name&continue=" + token:      errInvalidContinue,
+		"continue=%21%21":                    errInvalidContinue,
+		"continue=" + encodeRaw(`{"rev":0}`): errInvalidContinue,
+	} {
+		_, err := parseListOptions(listRequest(query))
+		assert.Equal(t, expect, err, query)
+	}
+}
+
+func encodeRaw(value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func TestContinueTokenStart(t *testing.T) {
+	prefix := "tasks/z/"
+	end := clientv3.GetPrefixRangeEnd(prefix)
+
+	token := &continueToken{Revision: 7, SortBy: sortByName, Key: "t-1\x00"}
+	decoded, err := decodeContinue(token.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, "tasks/z/t-1\x00", decoded.start(prefix))
+
+	// A forged token can not start the page out of the prefix.
+	forged, err := decodeContinue(encodeRaw(`{"rev":7,"sort":"name","key":"crons/"}`))
+	assert.NoError(t, err)
+	start := forged.start(prefix)
+	assert.True(t, strings.HasPrefix(start, prefix), start)
+	assert.True(t, start < end, start)
+}
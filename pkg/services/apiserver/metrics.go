@@ -0,0 +1,117 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"openpitrix.io/scheduler/pkg/metrics"
+)
+
+const subsystem = "apiserver"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "requests_total",
+		Help:      "Requests by route, method and response code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests by route and method, watches excluded.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	activeWatchers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "active_watchers",
+		Help:      "Open watch streams by resource.",
+	}, []string{"resource"})
+
+	etcdDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "etcd_request_duration_seconds",
+		Help:      "Latency of the etcd operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration, activeWatchers, etcdDuration)
+}
+
+// metricsFilter counts the requests and measures their latency by route.
+func metricsFilter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+	chain.ProcessFilter(request, response)
+
+	route := request.SelectedRoutePath()
+	method := request.Request.Method
+	requestsTotal.WithLabelValues(route, method, strconv.Itoa(response.StatusCode())).Inc()
+	if !parseBool(request.QueryParameter("watch")) {
+		requestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// instrumentedKV measures the latency of the etcd operations.
+type instrumentedKV struct {
+	clientv3.KV
+}
+
+func observeEtcd(operation string, start time.Time) {
+	etcdDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (kv instrumentedKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	defer observeEtcd("get", time.Now())
+	return kv.KV.Get(ctx, key, opts...)
+}
+
+func (kv instrumentedKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	defer observeEtcd("put", time.Now())
+	return kv.KV.Put(ctx, key, val, opts...)
+}
+
+func (kv instrumentedKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	defer observeEtcd("delete", time.Now())
+	return kv.KV.Delete(ctx, key, opts...)
+}
+
+func (kv instrumentedKV) Txn(ctx context.Context) clientv3.Txn {
+	return instrumentedTxn{kv.KV.Txn(ctx)}
+}
+
+type instrumentedTxn struct {
+	clientv3.Txn
+}
+
+func (txn instrumentedTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	return instrumentedTxn{txn.Txn.If(cs...)}
+}
+
+func (txn instrumentedTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	return instrumentedTxn{txn.Txn.Then(ops...)}
+}
+
+func (txn instrumentedTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	return instrumentedTxn{txn.Txn.Else(ops...)}
+}
+
+func (txn instrumentedTxn) Commit() (*clientv3.TxnResponse, error) {
+	defer observeEtcd("txn", time.Now())
+	return txn.Txn.Commit()
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsFilter(t *testing.T) {
+	ws := new(restful.WebService).Path("/api/v1alpha1")
+	ws.Filter(metricsFilter)
+	ws.Route(ws.GET("/namespaces/{namespace}/jobs/{job_id}").To(func(request *restful.Request, response *restful.Response) {
+		response.WriteHeader(http.StatusNotFound)
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	for _, name := range []string{"j-1", "j-2"} {
+		container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1alpha1/namespaces/default/jobs/"+name, nil))
+	}
+
+	route := "/api/v1alpha1/namespaces/{namespace}/jobs/{job_id}"
+	assert.Equal(t, float64(2), testutil.ToFloat64(requestsTotal.WithLabelValues(route, "GET", "404")))
+}
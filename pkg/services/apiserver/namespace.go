@@ -0,0 +1,406 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/emicklei/go-restful"
+
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/global"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// Crons, jobs, tasks and triggers live in namespaces, their keys are
+// <resource>/<namespace>/<name>. Nodes and namespaces are not namespaced.
+var namespacedResources = []string{"crons", "jobs", "tasks", "triggers"}
+
+var (
+	errNamespaceNotFound = errors.New("namespace not found")
+	errNamespaceNotEmpty = errors.New("namespace is not empty")
+)
+
+// quotaExceededError is answered with 403.
+type quotaExceededError struct {
+	namespace string
+	quota     string
+	limit     int
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("namespace [%s] exceeded quota %s=%d", e.namespace, e.quota, e.limit)
+}
+
+// inDefaultNamespace serves a route of before the namespaces, which acts in
+// the default namespace.
+func inDefaultNamespace(handler restful.RouteFunction) restful.RouteFunction {
+	return func(request *restful.Request, response *restful.Response) {
+		request.PathParameters()["namespace"] = constants.DefaultNamespace
+		handler(request, response)
+	}
+}
+
+// resourcePrefix is the prefix of a resource in the namespace of the
+// request, or in every namespace when the request has none.
+func resourcePrefix(resource string, request *restful.Request) string {
+	namespace := request.PathParameter("namespace")
+	if namespace == "" {
+		return resource + "/"
+	}
+	return resource + "/" + namespace + "/"
+}
+
+func resourceKey(resource string, request *restful.Request, name string) string {
+	return resource + "/" + request.PathParameter("namespace") + "/" + name
+}
+
+func getNamespace(name string) (*models.NamespaceInfo, error) {
+	infos, _, err := getInfo("namespaces/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, errNamespaceNotFound
+	}
+	nsInfo := &models.NamespaceInfo{}
+	if err := json.Unmarshal(infos[0].Value, nsInfo); err != nil {
+		return nil, err
+	}
+	return nsInfo, nil
+}
+
+// setNamespace sets the namespace of the object in info to the namespace of
+// the path, an object naming another namespace is refused.
+func setNamespace(info string, namespace string) (string, error) {
+	if info == "" {
+		return info, nil
+	}
+
+	object := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(info), &object); err != nil {
+		return "", err
+	}
+	if ns, ok := object["Namespace"].(string); ok && ns != "" && ns != namespace {
+		return "", fmt.Errorf("object of namespace [%s] in namespace [%s]", ns, namespace)
+	}
+	object["Namespace"] = namespace
+
+	data, err := json.Marshal(object)
+	return string(data), err
+}
+
+func countKeys(prefix string) (int64, error) {
+	e := global.GetInstance().GetEtcd()
+	resp, err := e.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+// quotaOf returns the quota of the namespace limiting the creation of
+// resource, its name is empty when there is none.
+func quotaOf(resource string, nsInfo *models.NamespaceInfo) (string, int) {
+	quota := nsInfo.Quota
+
+	switch {
+	case resource == "crons" && quota.MaxCrons > 0:
+		return "MaxCrons", quota.MaxCrons
+	case resource == "jobs" && quota.MaxConcurrentJobs > 0:
+		return "MaxConcurrentJobs", quota.MaxConcurrentJobs
+	case resource == "tasks" && quota.MaxTasksPerMinute > 0:
+		return "MaxTasksPerMinute", quota.MaxTasksPerMinute
+	}
+	return "", 0
+}
+
+// activeJobs counts the jobs which did not finish.
+func activeJobs(infos []models.Info) int64 {
+	var active int64
+	for _, info := range infos {
+		switch statusOf(info.Value) {
+		case "Completed", "Failed":
+		default:
+			active++
+		}
+	}
+	return active
+}
+
+// quotaUsage returns what the quota of resource is checked against.
+func quotaUsage(resource string, namespace string) (int64, error) {
+	switch resource {
+	case "jobs":
+		infos, _, err := getInfo("jobs/" + namespace + "/")
+		if err != nil {
+			return 0, err
+		}
+		return activeJobs(infos), nil
+	case "tasks":
+		// Every task created leaves a key living for a minute.
+		return countKeys("taskrate/" + namespace + "/")
+	default:
+		return countKeys(resource + "/" + namespace + "/")
+	}
+}
+
+// checkQuota refuses the creation of resource once usage reached its quota.
+func checkQuota(resource string, nsInfo *models.NamespaceInfo, usage int64) error {
+	quota, limit := quotaOf(resource, nsInfo)
+	if quota != "" && usage >= int64(limit) {
+		return &quotaExceededError{nsInfo.Name, quota, limit}
+	}
+	return nil
+}
+
+// admit checks the quota of the namespace before an object is created. The
+// check and the creation are not one transaction, the quotas are best-effort:
+// concurrent creations may all be admitted and exceed them by as many.
+func admit(resource string, nsInfo *models.NamespaceInfo) error {
+	if quota, _ := quotaOf(resource, nsInfo); quota == "" {
+		return nil
+	}
+	usage, err := quotaUsage(resource, nsInfo.Name)
+	if err != nil {
+		return err
+	}
+	return checkQuota(resource, nsInfo, usage)
+}
+
+func createNamespaced(fnName string, resource string, name string, request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("namespace")
+
+	nsInfo, err := getNamespace(namespace)
+	if err != nil {
+		logger.Debug(nil, "%s getNamespace [%s] error %+v.", fnName, namespace, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	apiInfo, ok := readAPIInfo(fnName, request, response)
+	if !ok {
+		return
+	}
+	value, err := setNamespace(apiInfo.Info, namespace)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+
+	if err := admit(resource, nsInfo); err != nil {
+		logger.Info(nil, "%s [%s/%s] refused: %v", fnName, namespace, name, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	key := resource + "/" + namespace + "/" + name
+	err = createInfo(key, value)
+	if err != nil {
+		logger.Debug(nil, "%s createInfo error %+v.", fnName, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	if resource == "tasks" && nsInfo.Quota.MaxTasksPerMinute > 0 {
+		if err := putInfo("taskrate/"+namespace+"/"+name, "", 60); err != nil {
+			logger.Error(nil, "%s record task rate of [%s] error %+v.", fnName, namespace, err)
+		}
+	}
+
+	logger.Debug(nil, "%s success", fnName)
+
+	response.WriteHeaderAndEntity(http.StatusOK, key)
+}
+
+func updateNamespaced(fnName string, resource string, name string, request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("namespace")
+
+	apiInfo, ok := readAPIInfo(fnName, request, response)
+	if !ok {
+		return
+	}
+	value, err := setNamespace(apiInfo.Info, namespace)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+
+	key := resource + "/" + namespace + "/" + name
+	revision, err := updateInfo(key, value, apiInfo.ResourceVersion)
+	if err != nil {
+		logger.Debug(nil, "%s updateInfo error %+v.", fnName, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	logger.Debug(nil, "%s success", fnName)
+
+	// The writer may update the resource again at this revision.
+	response.AddHeader(constants.HeaderResourceVersion, strconv.FormatInt(revision, 10))
+
+	response.WriteHeaderAndEntity(http.StatusOK, key)
+}
+
+func readNamespace(fnName string, request *restful.Request, response *restful.Response) (string, bool) {
+	apiInfo, ok := readAPIInfo(fnName, request, response)
+	if !ok {
+		return "", false
+	}
+
+	nsInfo := &models.NamespaceInfo{}
+	if err := json.Unmarshal([]byte(apiInfo.Info), nsInfo); err != nil {
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return "", false
+	}
+	nsInfo.Name = request.PathParameter("namespace")
+
+	data, err := json.Marshal(nsInfo)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return "", false
+	}
+	return string(data), true
+}
+
+func CreateNamespace(request *restful.Request, response *restful.Response) {
+	value, ok := readNamespace("CreateNamespace", request, response)
+	if !ok {
+		return
+	}
+
+	key := "namespaces/" + request.PathParameter("namespace")
+	err := createInfo(key, value)
+	if err != nil {
+		logger.Debug(nil, "CreateNamespace createInfo error %+v.", err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusOK, key)
+}
+
+func UpdateNamespace(request *restful.Request, response *restful.Response) {
+	value, ok := readNamespace("UpdateNamespace", request, response)
+	if !ok {
+		return
+	}
+
+	key := "namespaces/" + request.PathParameter("namespace")
+	_, err := updateInfo(key, value, 0)
+	if err != nil {
+		logger.Debug(nil, "UpdateNamespace updateInfo error %+v.", err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusOK, key)
+}
+
+func DescribeNamespaces(request *restful.Request, response *restful.Response) {
+	listResource("DescribeNamespaces", "namespaces/", request, response)
+}
+
+func DescribeNamespace(request *restful.Request, response *restful.Response) {
+	describeResource("DescribeNamespace", "namespaces/"+request.PathParameter("namespace"), request, response)
+}
+
+// DeleteNamespace deletes an empty namespace, the default namespace is kept.
+func DeleteNamespace(request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("namespace")
+	if namespace == constants.DefaultNamespace {
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(errors.New("the default namespace can not be deleted")))
+		return
+	}
+
+	for _, resource := range namespacedResources {
+		count, err := countKeys(resource + "/" + namespace + "/")
+		if err != nil {
+			response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+			return
+		}
+		if count > 0 {
+			response.WriteHeaderAndEntity(http.StatusConflict, Wrap(errNamespaceNotEmpty))
+			return
+		}
+	}
+
+	deleteResource("DeleteNamespace", "namespaces/"+namespace, response)
+}
+
+// ensureDefaultNamespace creates the default namespace on the first start.
+func ensureDefaultNamespace() error {
+	value, err := json.Marshal(models.NamespaceInfo{Name: constants.DefaultNamespace})
+	if err != nil {
+		return err
+	}
+	err = createInfo("namespaces/"+constants.DefaultNamespace, string(value))
+	if err != nil && err != errAlreadyExists {
+		return err
+	}
+	return nil
+}
+
+// migratedKey returns the key and the value in the default namespace of an
+// object stored before namespaces existed, ok is false for the keys which
+// already have a namespace.
+func migratedKey(resource string, key string, value string) (string, string, bool, error) {
+	name := strings.TrimPrefix(key, resource+"/")
+	if strings.Contains(name, "/") {
+		return "", "", false, nil
+	}
+
+	value, err := setNamespace(value, constants.DefaultNamespace)
+	if err != nil {
+		return "", "", false, err
+	}
+	return resource + "/" + constants.DefaultNamespace + "/" + name, value, true, nil
+}
+
+// migrateKeys moves the objects stored before namespaces existed, with
+// <resource>/<name> keys, to the default namespace.
+func migrateKeys() error {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+
+	for _, resource := range namespacedResources {
+		resp, err := e.Get(ctx, resource+"/", clientv3.WithPrefix())
+		if err != nil {
+			return err
+		}
+		for _, kv := range resp.Kvs {
+			key, value, ok, err := migratedKey(resource, string(kv.Key), string(kv.Value))
+			if err != nil {
+				logger.Error(nil, "migrateKeys skip [%s]: %v", kv.Key, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			var opts []clientv3.OpOption
+			if kv.Lease != 0 {
+				opts = append(opts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+			}
+			_, err = e.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+				Then(clientv3.OpPut(key, value, opts...), clientv3.OpDelete(string(kv.Key))).
+				Commit()
+			if err != nil {
+				return err
+			}
+			logger.Info(nil, "migrateKeys moved [%s] to [%s]", kv.Key, key)
+		}
+	}
+	return nil
+}
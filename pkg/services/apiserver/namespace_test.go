@@ -0,0 +1,90 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestSetNamespace(t *testing.T) {
+	value, err := setNamespace(`{"Name":"t-1"}`, "team-a")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Name":"t-1","Namespace":"team-a"}`, value)
+
+	value, err = setNamespace(`{"Name":"t-1","Namespace":"team-a"}`, "team-a")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Name":"t-1","Namespace":"team-a"}`, value)
+
+	value, err = setNamespace("", "team-a")
+	assert.NoError(t, err)
+	assert.Empty(t, value)
+
+	// An object may not name another namespace than its path.
+	_, err = setNamespace(`{"Name":"t-1","Namespace":"team-b"}`, "team-a")
+	assert.Error(t, err)
+	_, err = setNamespace("{", "team-a")
+	assert.Error(t, err)
+}
+
+func TestCheckQuota(t *testing.T) {
+	nsInfo := &models.NamespaceInfo{Name: "team-a", Quota: models.NamespaceQuota{MaxCrons: 2, MaxConcurrentJobs: 1}}
+
+	assert.NoError(t, checkQuota("crons", nsInfo, 1))
+	assert.Equal(t, &quotaExceededError{"team-a", "MaxCrons", 2}, checkQuota("crons", nsInfo, 2))
+	assert.Equal(t, &quotaExceededError{"team-a", "MaxConcurrentJobs", 1}, checkQuota("jobs", nsInfo, 1))
+	// No quota limits the tasks.
+	assert.NoError(t, checkQuota("tasks", nsInfo, 1000))
+	assert.NoError(t, checkQuota("triggers", nsInfo, 1000))
+
+	quota, _ := quotaOf("tasks", nsInfo)
+	assert.Empty(t, quota)
+	assert.Equal(t, http.StatusForbidden, errorStatus(checkQuota("crons", nsInfo, 2)))
+
+	jobs := []models.Info{
+		{Value: []byte(`{"Status":"Running"}`)},
+		{Value: []byte(`{"Status":"Created"}`)},
+		{Value: []byte(`{"Status":"Completed"}`)},
+		{Value: []byte(`{"Status":"Failed"}`)},
+	}
+	assert.Equal(t, int64(2), activeJobs(jobs))
+}
+
+func TestMigratedKey(t *testing.T) {
+	key, value, ok, err := migratedKey("crons", "crons/c-1", `{"Name":"c-1"}`)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "crons/default/c-1", key)
+	assert.JSONEq(t, `{"Name":"c-1","Namespace":"default"}`, value)
+
+	// The keys of a namespace stay.
+	_, _, ok, err = migratedKey("crons", "crons/team-a/c-1", `{"Name":"c-1"}`)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, _, err = migratedKey("crons", "crons/c-2", `{"Name":"c-2","Namespace":"team-a"}`)
+	assert.Error(t, err)
+}
+
+func TestInDefaultNamespace(t *testing.T) {
+	var namespace, name string
+	ws := new(restful.WebService).Path("/api/v1alpha1")
+	ws.Route(ws.POST("/tasks/{task_name}").To(inDefaultNamespace(func(request *restful.Request, response *restful.Response) {
+		namespace = request.PathParameter("namespace")
+		name = request.PathParameter("task_name")
+	})))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/v1alpha1/tasks/t-1", nil))
+	assert.Equal(t, "default", namespace)
+	assert.Equal(t, "t-1", name)
+}
@@ -0,0 +1,361 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/emicklei/go-restful"
+
+	"openpitrix.io/scheduler/pkg/global"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// The fields an operator sets on a node are kept in nodespecs/<name>, which
+// has no TTL and outlives the heartbeats. Every heartbeat merges them into
+// nodes/<name>, they are also written there at once when they change.
+
+// defaultDrainGracePeriod is how long a drained node may run its tasks.
+const defaultDrainGracePeriod = 5 * time.Minute
+
+// errNodeConflict is answered with 409 to an agent registering a node ID
+// that another live agent holds.
+var errNodeConflict = errors.New("node is registered by another live agent")
+
+// errStaleNodeStatus is answered with 409 to a status update which lost the
+// race with a heartbeat.
+var errStaleNodeStatus = errors.New("node status changed since")
+
+func nodeSpecKey(name string) string {
+	return "nodespecs/" + name
+}
+
+func getNodeSpec(name string) (*models.NodeInfo, error) {
+	spec := &models.NodeInfo{Name: name}
+	infos, _, err := getInfo(nodeSpecKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) > 0 {
+		if err := json.Unmarshal(infos[0].Value, spec); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// mergeNodeSpec sets the operator fields of spec on the node value.
+func mergeNodeSpec(name string, value []byte, spec *models.NodeInfo) (string, error) {
+	nodeInfo := models.NodeInfo{Name: name}
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &nodeInfo); err != nil {
+			return "", err
+		}
+	}
+	nodeInfo.Unschedulable = spec.Unschedulable
+	nodeInfo.Drain = spec.Drain
+
+	data, err := json.Marshal(nodeInfo)
+	return string(data), err
+}
+
+func decodeNodeInfo(name string, value []byte) (*models.NodeInfo, error) {
+	nodeInfo := &models.NodeInfo{Name: name}
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, nodeInfo); err != nil {
+			return nil, err
+		}
+	}
+	return nodeInfo, nil
+}
+
+// setHeartbeat stamps the heartbeat of node at now, the conditions which
+// did not change keep their transition time from old.
+func setHeartbeat(old *models.NodeInfo, node *models.NodeInfo, now time.Time) {
+	if node.Status == nil {
+		node.Status = &models.NodeStatus{}
+	}
+	node.Status.LastHeartbeatTime = now
+	for i := range node.Status.Conditions {
+		condition := &node.Status.Conditions[i]
+		condition.LastTransitionTime = now
+		if old == nil {
+			continue
+		}
+		if previous := old.Status.Condition(condition.Type); previous != nil && previous.Status == condition.Status {
+			condition.LastTransitionTime = previous.LastTransitionTime
+		}
+	}
+}
+
+// registerNode writes the heartbeat of a node. An agent keeps one lease of
+// ttl seconds for its session, every heartbeat renews it. Until the entry
+// expires or is deleted, only the agent instance which wrote it may renew
+// it.
+func registerNode(name string, value string, ttl int64) error {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+	key := "nodes/" + name
+
+	var granted clientv3.LeaseID
+	for retry := 0; retry < 3; retry++ {
+		node, err := decodeNodeInfo(name, []byte(value))
+		if err != nil {
+			return err
+		}
+
+		resp, err := e.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		var old *models.NodeInfo
+		var lease clientv3.LeaseID
+		cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		if len(resp.Kvs) > 0 {
+			kv := resp.Kvs[0]
+			old, err = decodeNodeInfo(name, kv.Value)
+			if err != nil {
+				return err
+			}
+			if old.Instance != "" && node.Instance != "" && old.Instance != node.Instance {
+				return errNodeConflict
+			}
+			cmp = clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)
+
+			// The same session renews its lease, agents which do not tell
+			// their instance apart start a new one.
+			if kv.Lease != 0 && node.Instance != "" && old.Instance == node.Instance {
+				if _, err := e.KeepAliveOnce(ctx, clientv3.LeaseID(kv.Lease)); err == nil {
+					lease = clientv3.LeaseID(kv.Lease)
+				}
+			}
+		}
+		if lease == 0 {
+			if granted == 0 {
+				grant, err := e.Grant(ctx, ttl)
+				if err != nil {
+					return err
+				}
+				granted = grant.ID
+			}
+			lease = granted
+		}
+
+		setHeartbeat(old, node, time.Now())
+		data, err := json.Marshal(node)
+		if err != nil {
+			return err
+		}
+
+		txn, err := e.Txn(ctx).If(cmp).Then(clientv3.OpPut(key, string(data), clientv3.WithLease(lease))).Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+	if granted != 0 {
+		e.Revoke(ctx, granted)
+	}
+	return fmt.Errorf("node [%s] changed too often", name)
+}
+
+// setNodeStatus replaces the conditions of the node, keeping its lease. It
+// fails with errStaleNodeStatus when a heartbeat was accepted after the one
+// status was computed from.
+func setNodeStatus(name string, status *models.NodeStatus) error {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+	key := "nodes/" + name
+
+	resp, err := e.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return errNotFound
+	}
+	kv := resp.Kvs[0]
+
+	node, err := decodeNodeInfo(name, kv.Value)
+	if err != nil {
+		return err
+	}
+	if node.Status == nil {
+		node.Status = &models.NodeStatus{}
+	}
+	if node.Status.LastHeartbeatTime.After(status.LastHeartbeatTime) {
+		return errStaleNodeStatus
+	}
+	node.Status.Conditions = status.Conditions
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	var opts []clientv3.OpOption
+	if kv.Lease != 0 {
+		opts = append(opts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+	}
+	txn, err := e.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+		Then(clientv3.OpPut(key, string(data), opts...)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !txn.Succeeded {
+		return errStaleNodeStatus
+	}
+	return nil
+}
+
+// applyNodeSpec rewrites the node entry with spec, keeping its lease. A
+// heartbeat written meanwhile is merged again.
+func applyNodeSpec(name string, spec *models.NodeInfo) error {
+	ctx := context.Background()
+	e := global.GetInstance().GetEtcd()
+	key := "nodes/" + name
+
+	for retry := 0; retry < 3; retry++ {
+		resp, err := e.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			// Applied by the first heartbeat.
+			return nil
+		}
+		kv := resp.Kvs[0]
+
+		value, err := mergeNodeSpec(name, kv.Value, spec)
+		if err != nil {
+			return err
+		}
+		var opts []clientv3.OpOption
+		if kv.Lease != 0 {
+			opts = append(opts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+		}
+		txn, err := e.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(key, value, opts...)).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+	return fmt.Errorf("node [%s] changed too often", name)
+}
+
+// updateNodeSpec changes the spec of the node, the spec is deleted once it
+// holds nothing.
+func updateNodeSpec(fnName string, request *restful.Request, response *restful.Response, update func(spec *models.NodeInfo)) {
+	name := request.PathParameter("node_name")
+
+	spec, err := getNodeSpec(name)
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+	update(spec)
+
+	if !spec.Unschedulable && spec.Drain == nil {
+		err = deleteKey(nodeSpecKey(name))
+		if err == errNotFound {
+			err = nil
+		}
+	} else {
+		var data []byte
+		data, err = json.Marshal(spec)
+		if err == nil {
+			err = putInfo(nodeSpecKey(name), string(data), 0)
+		}
+	}
+	if err == nil {
+		err = applyNodeSpec(name, spec)
+	}
+	if err != nil {
+		logger.Debug(nil, "%s [%s] error %+v.", fnName, name, err)
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+
+	logger.Info(nil, "%s [%s] success", fnName, name)
+
+	response.WriteHeaderAndEntity(http.StatusOK, "nodes/"+name)
+}
+
+// CordonNode stops scheduling tasks to the node.
+func CordonNode(request *restful.Request, response *restful.Response) {
+	updateNodeSpec("CordonNode", request, response, func(spec *models.NodeInfo) {
+		spec.Unschedulable = true
+	})
+}
+
+// UncordonNode schedules tasks to the node again and ends its drain.
+func UncordonNode(request *restful.Request, response *restful.Response) {
+	updateNodeSpec("UncordonNode", request, response, func(spec *models.NodeInfo) {
+		spec.Unschedulable = false
+		spec.Drain = nil
+	})
+}
+
+// DrainNode cordons the node and asks its agent to hand back the tasks not
+// started, the running ones are killed and handed back once gracePeriod
+// seconds passed.
+func DrainNode(request *restful.Request, response *restful.Response) {
+	gracePeriod := defaultDrainGracePeriod
+	if value := request.QueryParameter("gracePeriod"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds < 0 {
+			response.WriteHeaderAndEntity(http.StatusBadRequest, Error{Message: "invalid grace period " + value})
+			return
+		}
+		gracePeriod = time.Duration(seconds) * time.Second
+	}
+
+	updateNodeSpec("DrainNode", request, response, func(spec *models.NodeInfo) {
+		spec.Unschedulable = true
+		spec.Drain = &models.NodeDrain{Deadline: time.Now().Add(gracePeriod)}
+	})
+}
+
+// UpdateNodeStatus sets the conditions of the node, the controller marks the
+// nodes whose heartbeats stopped NotReady before their lease expires.
+func UpdateNodeStatus(request *restful.Request, response *restful.Response) {
+	name := request.PathParameter("node_name")
+
+	status := new(models.NodeStatus)
+	err := request.ReadEntity(status)
+	if err != nil {
+		logger.Error(nil, "UpdateNodeStatus request data error %+v.", err)
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+
+	err = setNodeStatus(name, status)
+	if err != nil {
+		logger.Info(nil, "UpdateNodeStatus [%s] error %+v.", name, err)
+		response.WriteHeaderAndEntity(errorStatus(err), Wrap(err))
+		return
+	}
+
+	logger.Info(nil, "UpdateNodeStatus [%s] success", name)
+
+	response.WriteHeaderAndEntity(http.StatusOK, "nodes/"+name)
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestMergeNodeSpec(t *testing.T) {
+	deadline := time.Date(2019, 8, 1, 8, 0, 0, 0, time.UTC)
+	spec := &models.NodeInfo{Name: "n-1", Unschedulable: true, Drain: &models.NodeDrain{Deadline: deadline}}
+
+	// A heartbeat can not uncordon the node.
+	value, err := mergeNodeSpec("n-1", []byte(`{"Name":"n-1","Pool":"gpu","Unschedulable":false}`), spec)
+	assert.NoError(t, err)
+	var nodeInfo models.NodeInfo
+	assert.NoError(t, json.Unmarshal([]byte(value), &nodeInfo))
+	assert.Equal(t, models.NodeInfo{Name: "n-1", Pool: "gpu", Unschedulable: true, Drain: &models.NodeDrain{Deadline: deadline}}, nodeInfo)
+
+	// Nor set what the operator did not.
+	value, err = mergeNodeSpec("n-2", nil, &models.NodeInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name":"n-2"}`, value)
+
+	_, err = mergeNodeSpec("n-1", []byte("{"), spec)
+	assert.Error(t, err)
+}
+
+func TestSetHeartbeat(t *testing.T) {
+	before := time.Date(2019, 8, 1, 8, 0, 0, 0, time.UTC)
+	now := before.Add(time.Minute)
+	old := &models.NodeInfo{Name: "n-1", Status: &models.NodeStatus{
+		LastHeartbeatTime: before,
+		Conditions: []models.NodeCondition{
+			{Type: models.NodeReady, Status: models.ConditionTrue, LastTransitionTime: before},
+			{Type: models.NodeDiskPressure, Status: models.ConditionFalse, LastTransitionTime: before},
+		},
+	}}
+	node := &models.NodeInfo{Name: "n-1", Status: &models.NodeStatus{
+		Conditions: []models.NodeCondition{
+			{Type: models.NodeReady, Status: models.ConditionTrue},
+			{Type: models.NodeDiskPressure, Status: models.ConditionTrue},
+			{Type: models.NodeMemoryPressure, Status: models.ConditionFalse},
+		},
+	}}
+
+	setHeartbeat(old, node, now)
+	assert.Equal(t, now, node.Status.LastHeartbeatTime)
+	assert.Equal(t, before, node.Status.Condition(models.NodeReady).LastTransitionTime)
+	assert.Equal(t, now, node.Status.Condition(models.NodeDiskPressure).LastTransitionTime)
+	assert.Equal(t, now, node.Status.Condition(models.NodeMemoryPressure).LastTransitionTime)
+
+	// The first heartbeat of a node.
+	setHeartbeat(nil, old, now)
+	assert.Equal(t, now, old.Status.Condition(models.NodeReady).LastTransitionTime)
+}
@@ -0,0 +1,295 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"gopkg.in/yaml.v2"
+
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/global"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+const (
+	VerbGet    = "get"
+	VerbList   = "list"
+	VerbWatch  = "watch"
+	VerbCreate = "create"
+	VerbUpdate = "update"
+	VerbPatch  = "patch"
+	VerbDelete = "delete"
+)
+
+// attributes are what a request does, eg. verb "create" on resource
+// "crons/trigger" with name "c-1" in namespace "default" for
+// POST /namespaces/default/crons/c-1/trigger.
+type attributes struct {
+	Verb      string
+	Namespace string
+	Resource  string
+	Name      string
+}
+
+func newAttributes(request *restful.Request) *attributes {
+	path := strings.TrimPrefix(request.Request.URL.Path, "/api/v1alpha1/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	attrs := &attributes{}
+	if parts[0] == "namespaces" && len(parts) > 1 {
+		// A namespace belongs to itself.
+		attrs.Namespace = parts[1]
+		if len(parts) > 2 {
+			parts = parts[2:]
+		}
+	}
+
+	attrs.Resource = parts[0]
+	if len(parts) > 1 {
+		attrs.Name = parts[1]
+	}
+	if attrs.Namespace == "" && attrs.Name != "" {
+		// The routes of before the namespaces act in the default one.
+		for _, resource := range namespacedResources {
+			if resource == attrs.Resource {
+				attrs.Namespace = constants.DefaultNamespace
+			}
+		}
+	}
+	if len(parts) > 2 {
+		attrs.Resource += "/" + parts[2]
+	}
+
+	switch request.Request.Method {
+	case http.MethodGet:
+		switch {
+		case parseBool(request.QueryParameter("watch")):
+			attrs.Verb = VerbWatch
+		case attrs.Name == "":
+			attrs.Verb = VerbList
+		default:
+			attrs.Verb = VerbGet
+		}
+	case http.MethodPost:
+		attrs.Verb = VerbCreate
+	case http.MethodPut:
+		attrs.Verb = VerbUpdate
+	case http.MethodPatch:
+		attrs.Verb = VerbPatch
+	case http.MethodDelete:
+		attrs.Verb = VerbDelete
+	default:
+		attrs.Verb = strings.ToLower(request.Request.Method)
+	}
+	return attrs
+}
+
+// PolicyRule allows the verbs on the resources, "*" matches any.
+type PolicyRule struct {
+	Resources []string `yaml:"resources"`
+	Verbs     []string `yaml:"verbs"`
+}
+
+type Role struct {
+	Name  string       `yaml:"name"`
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// RoleBinding grants a role to users and groups, in the listed namespaces
+// only when there are some.
+type RoleBinding struct {
+	Role       string   `yaml:"role"`
+	Users      []string `yaml:"users"`
+	Groups     []string `yaml:"groups"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+type Policy struct {
+	Roles    []Role        `yaml:"roles"`
+	Bindings []RoleBinding `yaml:"bindings"`
+}
+
+// defaultPolicy lets system:masters do anything and the nodes report
+// themselves and run their tasks.
+func defaultPolicy() *Policy {
+	return &Policy{
+		Roles: []Role{
+			{Name: "admin", Rules: []PolicyRule{{Resources: []string{"*"}, Verbs: []string{"*"}}}},
+			{Name: "viewer", Rules: []PolicyRule{{
+				Resources: []string{"namespaces", "nodes", "tasks", "jobs", "crons", "triggers"},
+				Verbs:     []string{VerbGet, VerbList, VerbWatch},
+			}}},
+			{Name: "node", Rules: []PolicyRule{
+				{Resources: []string{"nodes"}, Verbs: []string{VerbGet, VerbCreate, VerbDelete}},
+				{Resources: []string{"tasks"}, Verbs: []string{VerbGet, VerbList, VerbWatch, VerbUpdate}},
+				// The input artifacts of a task are found on their job.
+				{Resources: []string{"jobs"}, Verbs: []string{VerbGet}},
+			}},
+		},
+		Bindings: []RoleBinding{
+			{Role: "admin", Groups: []string{GroupMasters}},
+			{Role: "node", Groups: []string{GroupNodes}},
+		},
+	}
+}
+
+func loadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return policy, nil
+}
+
+type rbacAuthorizer struct {
+	roles    map[string]*Role
+	bindings []RoleBinding
+}
+
+func newRBACAuthorizer(policy *Policy) (*rbacAuthorizer, error) {
+	roles := make(map[string]*Role)
+	for i := range policy.Roles {
+		role := &policy.Roles[i]
+		if _, ok := roles[role.Name]; ok {
+			return nil, fmt.Errorf("duplicate role [%s]", role.Name)
+		}
+		roles[role.Name] = role
+	}
+	for _, binding := range policy.Bindings {
+		if _, ok := roles[binding.Role]; !ok {
+			return nil, fmt.Errorf("binding of unknown role [%s]", binding.Role)
+		}
+	}
+	return &rbacAuthorizer{roles: roles, bindings: policy.Bindings}, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *RoleBinding) appliesTo(user *User, namespace string) bool {
+	if len(b.Namespaces) > 0 && !contains(b.Namespaces, namespace) {
+		return false
+	}
+
+	for _, name := range b.Users {
+		if name == user.Name {
+			return true
+		}
+	}
+	for _, group := range b.Groups {
+		if user.inGroup(group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rbacAuthorizer) authorize(user *User, attrs *attributes) bool {
+	for i := range r.bindings {
+		if !r.bindings[i].appliesTo(user, attrs.Namespace) {
+			continue
+		}
+		for _, rule := range r.roles[r.bindings[i].Role].Rules {
+			if contains(rule.Resources, attrs.Resource) && contains(rule.Verbs, attrs.Verb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeRestriction limits a node credential to its own node entry and to the
+// tasks assigned to its node, before and after an update. A node may only
+// hand a task back to the scheduler, unassigned and pending.
+func nodeRestriction(user *User, attrs *attributes, request *restful.Request) error {
+	node, ok := user.nodeName()
+	if !ok {
+		return nil
+	}
+
+	switch attrs.Resource {
+	case "nodes":
+		if attrs.Verb != VerbGet && attrs.Name != node {
+			return fmt.Errorf("node [%s] cannot %s node [%s]", node, attrs.Verb, attrs.Name)
+		}
+	case "tasks":
+		if attrs.Verb != VerbUpdate && attrs.Verb != VerbPatch {
+			return nil
+		}
+
+		if attrs.Namespace == "" || attrs.Name == "" {
+			return fmt.Errorf("node [%s] cannot %s tasks", node, attrs.Verb)
+		}
+		stored, err := getStoredTask(attrs.Namespace, attrs.Name)
+		if err != nil {
+			return err
+		}
+
+		// Read the body and put it back for the handler.
+		body, err := ioutil.ReadAll(request.Request.Body)
+		if err != nil {
+			return err
+		}
+		request.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var apiInfo models.APIInfo
+		var updated models.TaskInfo
+		if err := json.Unmarshal(body, &apiInfo); err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(apiInfo.Info), &updated); err != nil {
+			return err
+		}
+		return nodeMayUpdateTask(node, attrs.Name, stored, &updated)
+	}
+	return nil
+}
+
+// getStoredTask reads the task at its exact key, nil when it does not exist.
+func getStoredTask(namespace string, name string) (*models.TaskInfo, error) {
+	e := global.GetInstance().GetEtcd()
+	resp, err := e.Get(context.Background(), "tasks/"+namespace+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	stored := &models.TaskInfo{}
+	if err := json.Unmarshal(resp.Kvs[0].Value, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// nodeMayUpdateTask lets a node update a task stored as assigned to it, and
+// keep it assigned or hand it back unassigned and pending.
+func nodeMayUpdateTask(node string, name string, stored *models.TaskInfo, updated *models.TaskInfo) error {
+	if stored == nil || stored.Node != node {
+		return fmt.Errorf("task [%s] is not assigned to node [%s]", name, node)
+	}
+	if updated.Node != node && !(updated.Node == "" && updated.Status == "Pending") {
+		return fmt.Errorf("node [%s] cannot assign task [%s] to another node", node, name)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestNewAttributes(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		expect attributes
+	}{
+		{"GET", "/api/v1alpha1/tasks/", attributes{Verb: VerbList, Resource: "tasks"}},
+		{"GET", "/api/v1alpha1/tasks/?watch=true", attributes{Verb: VerbWatch, Resource: "tasks"}},
+		{"GET", "/api/v1alpha1/namespaces/ns/tasks/", attributes{Verb: VerbList, Namespace: "ns", Resource: "tasks"}},
+		{"GET", "/api/v1alpha1/namespaces/ns/tasks/t-1", attributes{Verb: VerbGet, Namespace: "ns", Resource: "tasks", Name: "t-1"}},
+		{"PUT", "/api/v1alpha1/namespaces/ns/tasks/t-1", attributes{Verb: VerbUpdate, Namespace: "ns", Resource: "tasks", Name: "t-1"}},
+		{"DELETE", "/api/v1alpha1/namespaces/ns/crons/c-1", attributes{Verb: VerbDelete, Namespace: "ns", Resource: "crons", Name: "c-1"}},
+		{"POST", "/api/v1alpha1/namespaces/ns/crons/c-1/trigger", attributes{Verb: VerbCreate, Namespace: "ns", Resource: "crons/trigger", Name: "c-1"}},
+		{"GET", "/api/v1alpha1/namespaces/", attributes{Verb: VerbList, Resource: "namespaces"}},
+		{"PUT", "/api/v1alpha1/namespaces/ns", attributes{Verb: VerbUpdate, Namespace: "ns", Resource: "namespaces", Name: "ns"}},
+		{"GET", "/api/v1alpha1/nodes/n-1", attributes{Verb: VerbGet, Resource: "nodes", Name: "n-1"}},
+		// The routes of before the namespaces.
+		{"POST", "/api/v1alpha1/tasks/t-1", attributes{Verb: VerbCreate, Namespace: "default", Resource: "tasks", Name: "t-1"}},
+		{"DELETE", "/api/v1alpha1/crons/c-1", attributes{Verb: VerbDelete, Namespace: "default", Resource: "crons", Name: "c-1"}},
+	}
+
+	for _, test := range tests {
+		request := restful.NewRequest(httptest.NewRequest(test.method, test.url, nil))
+		assert.Equal(t, test.expect, *newAttributes(request), test.url)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	policy := defaultPolicy()
+	policy.Roles = append(policy.Roles, Role{Name: "operator", Rules: []PolicyRule{
+		{Resources: []string{"crons", "crons/trigger"}, Verbs: []string{"*"}},
+	}})
+	policy.Bindings = append(policy.Bindings,
+		RoleBinding{Role: "viewer", Groups: []string{GroupAuthenticated}},
+		RoleBinding{Role: "operator", Users: []string{"alice"}, Namespaces: []string{"team-a"}})
+
+	authorizer, err := newRBACAuthorizer(policy)
+	assert.NoError(t, err)
+
+	admin := &User{Name: "controller", Groups: []string{GroupMasters}}
+	node := &User{Name: NodeUserPrefix + "n-1", Groups: []string{GroupNodes}}
+	alice := &User{Name: "alice", Groups: []string{GroupAuthenticated}}
+	bob := &User{Name: "bob", Groups: []string{GroupAuthenticated}}
+	anonymous := &User{Name: UserAnonymous}
+
+	tests := []struct {
+		user   *User
+		attrs  attributes
+		expect bool
+	}{
+		{admin, attributes{Verb: VerbDelete, Resource: "jobs"}, true},
+		{admin, attributes{Verb: VerbCreate, Resource: "tokens"}, true},
+		{node, attributes{Verb: VerbUpdate, Resource: "tasks"}, true},
+		{node, attributes{Verb: VerbCreate, Resource: "nodes"}, true},
+		{node, attributes{Verb: VerbDelete, Resource: "nodes"}, true},
+		{node, attributes{Verb: VerbCreate, Resource: "tasks"}, false},
+		{node, attributes{Verb: VerbList, Resource: "crons"}, false},
+		{node, attributes{Verb: VerbGet, Resource: "jobs"}, true},
+		{node, attributes{Verb: VerbUpdate, Resource: "jobs"}, false},
+		{alice, attributes{Verb: VerbCreate, Namespace: "team-a", Resource: "crons/trigger"}, true},
+		{alice, attributes{Verb: VerbCreate, Namespace: "team-b", Resource: "crons/trigger"}, false},
+		{alice, attributes{Verb: VerbCreate, Resource: "crons/trigger"}, false},
+		{alice, attributes{Verb: VerbDelete, Namespace: "team-a", Resource: "jobs"}, false},
+		{bob, attributes{Verb: VerbWatch, Resource: "jobs"}, true},
+		{bob, attributes{Verb: VerbUpdate, Resource: "crons"}, false},
+		{anonymous, attributes{Verb: VerbList, Resource: "jobs"}, false},
+	}
+
+	for _, test := range tests {
+		attrs := test.attrs
+		assert.Equal(t, test.expect, authorizer.authorize(test.user, &attrs), "%s %+v", test.user.Name, attrs)
+	}
+
+	_, err = newRBACAuthorizer(&Policy{Bindings: []RoleBinding{{Role: "missing"}}})
+	assert.Error(t, err)
+}
+
+func TestNodeMayUpdateTask(t *testing.T) {
+	assigned := &models.TaskInfo{Name: "a", Node: "n-1", Status: "Scheduled"}
+	other := &models.TaskInfo{Name: "a", Node: "n-2", Status: "Scheduled"}
+
+	assert.NoError(t, nodeMayUpdateTask("n-1", "a", assigned, &models.TaskInfo{Node: "n-1", Status: "Running"}))
+	// Handed back to the scheduler.
+	assert.NoError(t, nodeMayUpdateTask("n-1", "a", assigned, &models.TaskInfo{Status: "Pending"}))
+	assert.Error(t, nodeMayUpdateTask("n-1", "a", assigned, &models.TaskInfo{Node: "n-2", Status: "Scheduled"}))
+	assert.Error(t, nodeMayUpdateTask("n-1", "a", assigned, &models.TaskInfo{Status: "Running"}))
+	assert.Error(t, nodeMayUpdateTask("n-1", "a", other, &models.TaskInfo{Node: "n-1", Status: "Running"}))
+	// A task which does not exist is not the node's.
+	assert.Error(t, nodeMayUpdateTask("n-1", "a", nil, &models.TaskInfo{Node: "n-1", Status: "Running"}))
+}
+
+func TestTokenFileAuthenticator(t *testing.T) {
+	file, err := ioutil.TempFile("", "tokens")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	file.WriteString("# token,user,groups\nsecret-1,admin,system:masters\n\nsecret-2, node-1, system:nodes, zone-a\n")
+	file.Close()
+
+	a, err := newTokenFileAuthenticator(file.Name())
+	assert.NoError(t, err)
+
+	request := httptest.NewRequest("GET", "/api/v1alpha1/tasks/", nil)
+	_, ok, err := a.AuthenticateRequest(request)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	request.Header.Set("Authorization", "Bearer secret-2")
+	user, ok, err := a.AuthenticateRequest(request)
+	assert.True(t, ok)
+	assert.Equal(t, &User{Name: "node-1", Groups: []string{"system:nodes", "zone-a"}}, user)
+
+	request.Header.Set("Authorization", "Bearer secret-3")
+	_, ok, _ = a.AuthenticateRequest(request)
+	assert.False(t, ok)
+}
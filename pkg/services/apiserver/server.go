@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"context"
 
@@ -17,10 +19,13 @@ import (
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
 
+	"openpitrix.io/scheduler/pkg/admin"
 	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/constants"
 	"openpitrix.io/scheduler/pkg/global"
 	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/util/tlsutil"
 )
 
 func WebService() *restful.WebService {
@@ -41,111 +46,508 @@ func WebService() *restful.WebService {
 	ws.Route(ws.GET("/nodes/").To(DescribeNodes).
 		Doc("Describe Nodes").
 		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
-		Param(ws.QueryParameter("filter", "filter, eg. group=abc.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
 	ws.Route(ws.GET("/nodes/{node_name}").To(DescribeNode).
-		Doc("Describe Nodes").
+		Doc("Describe Node").
+		Param(ws.PathParameter("node_name", "Specify node").DataType("string").Required(true).DefaultValue("")).
 		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
-		Param(ws.QueryParameter("filter", "filter, eg. group=abc.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/nodes/{node_name}").To(DeleteNode).
+		Doc("Delete Node").
+		Param(ws.PathParameter("node_name", "Specify node").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.POST("/nodes/{node_name}/cordon").To(CordonNode).
+		Doc("Cordon Node").
+		Param(ws.PathParameter("node_name", "Specify node").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.POST("/nodes/{node_name}/uncordon").To(UncordonNode).
+		Doc("Uncordon Node").
+		Param(ws.PathParameter("node_name", "Specify node").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.POST("/nodes/{node_name}/drain").To(DrainNode).
+		Doc("Drain Node").
+		Param(ws.PathParameter("node_name", "Specify node").DataType("string").Required(true).DefaultValue("")).
+		Param(ws.QueryParameter("gracePeriod", "seconds the running tasks may finish in, 300 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.POST("/nodes/{node_name}/status").To(UpdateNodeStatus).
+		Doc("Update Node Status").
+		Param(ws.PathParameter("node_name", "Specify node").DataType("string").Required(true).DefaultValue("")).
+		Reads(models.NodeStatus{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
+	tags = []string{"Namespace"}
+
+	ws.Route(ws.POST("/namespaces/{namespace}").To(CreateNamespace).
+		Doc("Create Namespace").
+		Param(ws.PathParameter("namespace", "Specify namespace").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.PUT("/namespaces/{namespace}").To(UpdateNamespace).
+		Doc("Update Namespace").
+		Param(ws.PathParameter("namespace", "Specify namespace").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/").To(DescribeNamespaces).
+		Doc("Describe Namespaces").
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}").To(DescribeNamespace).
+		Doc("Describe Namespace").
+		Param(ws.PathParameter("namespace", "Specify namespace").DataType("string").Required(true).DefaultValue("")).
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/namespaces/{namespace}").To(DeleteNamespace).
+		Doc("Delete Namespace").
+		Param(ws.PathParameter("namespace", "Specify namespace").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
 	tags = []string{"Task"}
 
-	ws.Route(ws.POST("/tasks/{task_name}").To(CreateTask).
+	ws.Route(ws.POST("/namespaces/{namespace}/tasks/{task_name}").To(CreateTask).
 		Doc("Create Task").
 		Param(ws.PathParameter("task_name", "Specify task").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
+	// Kept from before the namespaces, in the default namespace.
+	ws.Route(ws.POST("/tasks/{task_name}").To(inDefaultNamespace(CreateTask)).
+		Doc("Create Task in the default namespace").
+		Param(ws.PathParameter("task_name", "Specify task").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Deprecate().
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.PUT("/namespaces/{namespace}/tasks/{task_name}").To(UpdateTask).
+		Doc("Update Task").
+		Param(ws.PathParameter("task_name", "Specify task").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
 	ws.Route(ws.GET("/tasks/").To(DescribeTasks).
 		Doc("Describe Tasks").
 		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
-		Param(ws.QueryParameter("filter", "filter, eg. group=abc.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/tasks/").To(DescribeTasks).
+		Doc("Describe Tasks").
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/tasks/{task_name}").To(DescribeTask).
+		Doc("Describe Task").
+		Param(ws.PathParameter("task_name", "Specify task").DataType("string").Required(true).DefaultValue("")).
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/namespaces/{namespace}/tasks/{task_name}").To(DeleteTask).
+		Doc("Delete Task").
+		Param(ws.PathParameter("task_name", "Specify task").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
 	tags = []string{"Job"}
 
-	ws.Route(ws.POST("/jobs/{job_name}").To(CreateJob).
+	ws.Route(ws.POST("/namespaces/{namespace}/jobs/{job_name}").To(CreateJob).
 		Doc("Create Job").
 		Param(ws.PathParameter("job_name", "Specify job").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
+	// Kept from before the namespaces, in the default namespace.
+	ws.Route(ws.POST("/jobs/{job_name}").To(inDefaultNamespace(CreateJob)).
+		Doc("Create Job in the default namespace").
+		Param(ws.PathParameter("job_name", "Specify job").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Deprecate().
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.PUT("/namespaces/{namespace}/jobs/{job_name}").To(UpdateJob).
+		Doc("Update Job").
+		Param(ws.PathParameter("job_name", "Specify job").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
 	ws.Route(ws.GET("/jobs/").To(DescribeJobs).
 		Doc("Describe Jobs").
 		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
-		Param(ws.QueryParameter("filter", "filter, eg. group=abc.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/jobs/").To(DescribeJobs).
+		Doc("Describe Jobs").
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/jobs/{job_name}").To(DescribeJob).
+		Doc("Describe Job").
+		Param(ws.PathParameter("job_name", "Specify job").DataType("string").Required(true).DefaultValue("")).
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/namespaces/{namespace}/jobs/{job_name}").To(DeleteJob).
+		Doc("Delete Job").
+		Param(ws.PathParameter("job_name", "Specify job").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
 	tags = []string{"Cron"}
 
-	ws.Route(ws.POST("/crons/{cron_name}").To(CreateCron).
+	ws.Route(ws.POST("/namespaces/{namespace}/crons/{cron_name}").To(CreateCron).
 		Doc("Create Cron").
 		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
+	// Kept from before the namespaces, in the default namespace.
+	ws.Route(ws.POST("/crons/{cron_name}").To(inDefaultNamespace(CreateCron)).
+		Doc("Create Cron in the default namespace").
+		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Deprecate().
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.PUT("/namespaces/{namespace}/crons/{cron_name}").To(UpdateCron).
+		Doc("Update Cron").
+		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
 	ws.Route(ws.GET("/crons/").To(DescribeCrons).
 		Doc("Describe Crons").
 		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
-		Param(ws.QueryParameter("filter", "filter, eg. group=abc.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
-	ws.Route(ws.DELETE("/crons/{cron_name}").To(DeleteCrons).
-		Doc("Delete Crons").
+	ws.Route(ws.GET("/namespaces/{namespace}/crons/").To(DescribeCrons).
+		Doc("Describe Crons").
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/crons/{cron_name}").To(DescribeCron).
+		Doc("Describe Cron").
+		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/namespaces/{namespace}/crons/{cron_name}").To(DeleteCrons).
+		Doc("Delete Cron").
+		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	// Kept from before the namespaces, in the default namespace.
+	ws.Route(ws.DELETE("/crons/{cron_name}").To(inDefaultNamespace(DeleteCrons)).
+		Doc("Delete Cron in the default namespace").
 		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Deprecate().
 		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
 		Produces(restful.MIME_JSON))
 
+	ws.Route(ws.POST("/namespaces/{namespace}/crons/{cron_name}/trigger").To(TriggerCron).
+		Doc("Trigger Cron").
+		Param(ws.PathParameter("cron_name", "Specify cron").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/triggers/").To(DescribeTriggers).
+		Doc("Describe Triggers").
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/triggers/").To(DescribeTriggers).
+		Doc("Describe Triggers").
+		Param(ws.QueryParameter("watch", "watch resource, true/false.").DataType("bool").DefaultValue("false").Required(false)).
+		Param(ws.QueryParameter("filter", "filter, eg. Status=Failed,Owner in (c-1,c-2),StartTime>now-24h.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("labelSelector", "label selector, eg. team=data,service in (web,api).").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("resourceVersion", "resume watch after this revision.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("limit", "maximum number of objects of a page, 200 by default.").DataType("integer").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("continue", "continue token of the previous page.").DataType("string").DefaultValue("").Required(false)).
+		Param(ws.QueryParameter("sortBy", "name, createRevision or status.").DataType("string").DefaultValue("name").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/namespaces/{namespace}/triggers/{trigger_name}").To(DeleteTrigger).
+		Doc("Delete Trigger").
+		Param(ws.PathParameter("trigger_name", "Specify trigger").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Consumes(restful.MIME_JSON, constants.MIME_MERGEPATCH).
+		Produces(restful.MIME_JSON))
+
+	authTags := []string{"Auth"}
+
+	ws.Route(ws.POST("/tokens/").To(IssueToken).
+		Doc("Issue Token").
+		Reads(models.TokenRequest{}).
+		Writes(models.TokenInfo{}).
+		Metadata(restfulspec.KeyOpenAPITags, authTags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.POST("/tokens/{token_id}/refresh").To(RefreshToken).
+		Doc("Refresh Token").
+		Param(ws.PathParameter("token_id", "Specify token").DataType("string").Required(true).DefaultValue("")).
+		Reads(models.RefreshTokenRequest{}).
+		Writes(models.TokenInfo{}).
+		Metadata(restfulspec.KeyOpenAPITags, authTags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
+	ws.Route(ws.DELETE("/tokens/{token_id}").To(DeleteToken).
+		Doc("Delete Token").
+		Param(ws.PathParameter("token_id", "Specify token").DataType("string").Required(true).DefaultValue("")).
+		Metadata(restfulspec.KeyOpenAPITags, authTags).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON))
+
 	return ws
 }
 
 var Container = restful.DefaultContainer
 
-func Run() {
-	Container.Add(WebService())
-	enableCORS()
-
-	global.GetInstance()
+// checkEtcd is the readiness check of the apiserver.
+func checkEtcd() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-	go watchGlobal("nodes/")
+	e := global.GetInstance().GetEtcd()
+	_, err := e.Get(ctx, "namespaces/"+constants.DefaultNamespace, clientv3.WithCountOnly())
+	return err
+}
 
+// Run serves the api until ctx is done, then closes the watch streams and
+// lets the other requests finish within the shutdown timeout.
+func Run(ctx context.Context) {
 	cfg := config.GetInstance()
+
+	auth, err := newAuthFilter(cfg)
+	if err != nil {
+		logger.Critical(nil, "Load authentication config failed: %+v", err)
+		panic(err)
+	}
+
+	audit, err := newAuditFilter(cfg)
+	if err != nil {
+		logger.Critical(nil, "Load audit config failed: %+v", err)
+		panic(err)
+	}
+
+	ws := WebService()
+	ws.Filter(metricsFilter)
+	if audit != nil {
+		ws.Filter(audit.Filter)
+	}
+	ws.Filter(auth.Filter)
+	Container.Add(ws)
+	enableCORS(cfg)
+
+	e := global.GetInstance().GetEtcd()
+	e.KV = instrumentedKV{e.KV}
+	adminServer := admin.NewServer(cfg.Admin.Port)
+	adminServer.AddReadyCheck("etcd", checkEtcd)
+	adminServer.Start()
+
+	if err := migrateKeys(); err != nil {
+		logger.Critical(nil, "Migrate keys to namespaces failed: %+v", err)
+		panic(err)
+	}
+	if err := ensureDefaultNamespace(); err != nil {
+		logger.Critical(nil, "Create default namespace failed: %+v", err)
+		panic(err)
+	}
+
+	go watchGlobal(ctx, "nodes/")
+
 	apiPort, _ := strconv.Atoi(cfg.ApiServer.ApiPort)
-	listen := fmt.Sprintf(":%d", apiPort)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", apiPort)}
+
+	serveErr := make(chan error, 1)
+	if cfg.ApiServer.TLSCertFile == "" {
+		go func() { serveErr <- server.ListenAndServe() }()
+	} else {
+		reloader, err := tlsutil.NewReloader(cfg.ApiServer.TLSCertFile, cfg.ApiServer.TLSKeyFile, cfg.ApiServer.ClientCAFile)
+		if err != nil {
+			logger.Critical(nil, "Load apiserver certificates failed: %+v", err)
+			panic(err)
+		}
+		server.TLSConfig = reloader.ServerConfig()
+		go func() { serveErr <- server.ListenAndServeTLS("", "") }()
+	}
+
+	select {
+	case err := <-serveErr:
+		logger.Critical(nil, "%+v", err)
+		return
+	case <-ctx.Done():
+	}
+	logger.Info(nil, "Apiserver shutting down")
+
+	// The watch streams never end by themselves, close them first.
+	stopWatchers()
 
-	logger.Info(nil, "%+v", http.ListenAndServe(listen, nil))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ApiServer.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error(nil, "Apiserver shutdown error [%v]", err)
+	}
+	logger.Info(nil, "Apiserver stopped")
 }
 
-func enableCORS() {
-	// Optionally, you may need to enable CORS for the UI to work.
+// enableCORS lets the configured origins, eg. of a UI, call the apiserver.
+func enableCORS(cfg *config.Config) {
+	var origins []string
+	for _, origin := range strings.Split(cfg.ApiServer.AllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" && origin != "*" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return
+	}
+
 	cors := restful.CrossOriginResourceSharing{
-		AllowedHeaders: []string{"Content-Type", "Accept"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		CookiesAllowed: false,
-		AllowedDomains: []string{"*"},
+		AllowedDomains: origins,
 		Container:      Container}
 	Container.Filter(cors.Filter)
 }
 
-func watchGlobal(key string) {
+func watchGlobal(ctx context.Context, key string) {
 	e := global.GetInstance().GetEtcd()
-	watchRes := e.Watch(context.Background(), key, clientv3.WithPrefix())
+	watchRes := e.Watch(ctx, key, clientv3.WithPrefix())
 
 	for res := range watchRes {
 		for _, ev := range res.Events {
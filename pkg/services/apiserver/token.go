@@ -0,0 +1,204 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/util/idutil"
+)
+
+// Issued tokens and refresh tokens look like "<id>.<secret>". The apiserver
+// stores the record of a token under tokens/<id> with the digests of the
+// secrets, the record expires with the refresh token.
+
+var errInvalidToken = errors.New("invalid token")
+
+func digest(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func splitToken(token string) (string, string, bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], constants.TokenIdPrefix) ||
+		strings.Contains(parts[0], "/") || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func getTokenRecord(id string) (*models.TokenRecord, error) {
+	infos, _, err := getInfo("tokens/" + id)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, errNotFound
+	}
+	record := &models.TokenRecord{}
+	if err := json.Unmarshal(infos[0].Value, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// issueToken creates new secrets for the record and stores it.
+func issueToken(record *models.TokenRecord) (*models.TokenInfo, error) {
+	cfg := config.GetInstance()
+	now := time.Now()
+
+	token := record.Id + "." + idutil.GetSecret()
+	refreshToken := record.Id + "." + idutil.GetRefreshToken()
+
+	record.TokenHash = digest(token)
+	record.RefreshTokenHash = digest(refreshToken)
+	record.ExpireTime = now.Add(time.Duration(record.TTL) * time.Second)
+	record.RefreshExpireTime = now.Add(cfg.ApiServer.RefreshTokenTTL)
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	err = putInfo("tokens/"+record.Id, string(value), int64(cfg.ApiServer.RefreshTokenTTL/time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenInfo{
+		Id:                record.Id,
+		Token:             token,
+		RefreshToken:      refreshToken,
+		ExpireTime:        record.ExpireTime,
+		RefreshExpireTime: record.RefreshExpireTime,
+	}, nil
+}
+
+// issuedTokenAuthenticator checks bearer tokens issued by IssueToken.
+type issuedTokenAuthenticator struct{}
+
+func (issuedTokenAuthenticator) AuthenticateRequest(request *http.Request) (*User, bool, error) {
+	token := bearerToken(request)
+	id, _, ok := splitToken(token)
+	if !ok {
+		return nil, false, nil
+	}
+
+	record, err := getTokenRecord(id)
+	if err == errNotFound {
+		return nil, false, errInvalidToken
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(digest(token)), []byte(record.TokenHash)) != 1 ||
+		time.Now().After(record.ExpireTime) {
+		return nil, false, errInvalidToken
+	}
+	return &User{Name: record.User, Groups: record.Groups}, true, nil
+}
+
+// IssueToken issues a token and a refresh token for a user. Only the
+// digests are stored, the secrets are in this answer only.
+func IssueToken(request *restful.Request, response *restful.Response) {
+	tokenRequest := new(models.TokenRequest)
+	err := request.ReadEntity(tokenRequest)
+	if err != nil || tokenRequest.User == "" {
+		if err == nil {
+			err = errors.New("user is required")
+		}
+		logger.Error(nil, "IssueToken request data error %+v.", err)
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+
+	ttl := tokenRequest.TTL
+	if ttl <= 0 {
+		ttl = int64(config.GetInstance().ApiServer.TokenTTL / time.Second)
+	}
+
+	tokenInfo, err := issueToken(&models.TokenRecord{
+		Id:     idutil.GetUuid(constants.TokenIdPrefix),
+		User:   tokenRequest.User,
+		Groups: tokenRequest.Groups,
+		TTL:    ttl,
+	})
+	if err != nil {
+		logger.Debug(nil, "IssueToken error %+v.", err)
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+
+	logger.Info(nil, "IssueToken [%s] for user [%s]", tokenInfo.Id, tokenRequest.User)
+
+	response.WriteHeaderAndEntity(http.StatusOK, tokenInfo)
+}
+
+// RefreshToken replaces both secrets of a token, the old refresh token can
+// not be used again.
+func RefreshToken(request *restful.Request, response *restful.Response) {
+	refreshRequest := new(models.RefreshTokenRequest)
+	err := request.ReadEntity(refreshRequest)
+	if err != nil {
+		logger.Error(nil, "RefreshToken request data error %+v.", err)
+		response.WriteHeaderAndEntity(http.StatusBadRequest, Wrap(err))
+		return
+	}
+
+	id, _, ok := splitToken(refreshRequest.RefreshToken)
+	if !ok || id != request.PathParameter("token_id") {
+		response.WriteHeaderAndEntity(http.StatusUnauthorized, Wrap(errInvalidToken))
+		return
+	}
+
+	record, err := getTokenRecord(id)
+	if err == errNotFound {
+		response.WriteHeaderAndEntity(http.StatusUnauthorized, Wrap(errInvalidToken))
+		return
+	}
+	if err != nil {
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(digest(refreshRequest.RefreshToken)), []byte(record.RefreshTokenHash)) != 1 ||
+		time.Now().After(record.RefreshExpireTime) {
+		response.WriteHeaderAndEntity(http.StatusUnauthorized, Wrap(errInvalidToken))
+		return
+	}
+
+	tokenInfo, err := issueToken(record)
+	if err != nil {
+		logger.Debug(nil, "RefreshToken error %+v.", err)
+		response.WriteHeaderAndEntity(http.StatusInternalServerError, Wrap(err))
+		return
+	}
+
+	logger.Info(nil, "RefreshToken [%s] of user [%s]", id, record.User)
+
+	response.WriteHeaderAndEntity(http.StatusOK, tokenInfo)
+}
+
+// DeleteToken revokes a token and its refresh token.
+func DeleteToken(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("token_id")
+	if strings.Contains(id, "/") {
+		response.WriteHeaderAndEntity(http.StatusNotFound, Wrap(errNotFound))
+		return
+	}
+	deleteResource("DeleteToken", "tokens/"+id, response)
+}
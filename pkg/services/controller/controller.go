@@ -5,36 +5,77 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron/v3"
 
+	"openpitrix.io/scheduler/pkg/admin"
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/metrics"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
+// namespacedName is the key of a cron or trigger in the maps of the
+// controller, names are only unique within a namespace.
+func namespacedName(namespace, name string) string {
+	return namespace + "/" + name
+}
+
 type CronRunners struct {
 	sync.RWMutex
 	Map map[string]*CronRunner
 }
 
 type Controller struct {
-	jobWatcher  *JobWatcher
-	cronWatcher *CronWatcher
-	cronCore    *cron.Cron
-	cronRunners *CronRunners
+	client         clientset.Interface
+	jobWatcher     *JobWatcher
+	cronWatcher    *CronWatcher
+	triggerWatcher *TriggerWatcher
+	cronCore       *cron.Cron
+	cronRunners    *CronRunners
+	gc             *GarbageCollector
+	nodeMonitor    *NodeMonitor
+	notifier       *Notifier
+	// triggers holds the triggers already run whose deletion has not been
+	// observed yet, so that a resync does not run them twice.
+	triggers map[string]struct{}
 }
 
 func NewController() *Controller {
+	cfg := config.GetInstance()
+	client := clientset.NewForConfigOrDie(clientset.LoadConfig(cfg))
+
 	ct := &Controller{
-		jobWatcher:  NewJobWatcher("Status=Created"),
-		cronWatcher: NewCronWatcher(""),
-		cronCore:    cron.New(),
-		cronRunners: &CronRunners{Map: make(map[string]*CronRunner)},
+		client:         client,
+		jobWatcher:     NewJobWatcher(client, "", "Status=Created"),
+		cronWatcher:    NewCronWatcher(client, ""),
+		triggerWatcher: NewTriggerWatcher(client),
+		cronCore:       cron.New(),
+		cronRunners:    &CronRunners{Map: make(map[string]*CronRunner)},
+		triggers:       make(map[string]struct{}),
+		gc:             NewGarbageCollector(client, cfg.Controller.GCPeriod),
+		nodeMonitor:    NewNodeMonitor(client, cfg.Controller.NodeMonitorPeriod, cfg.Controller.NodeMonitorGracePeriod),
+		notifier:       NewNotifier(cfg),
 	}
 
 	ct.cronCore.Start()
 
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "job_queue_depth",
+		Help:      "Created jobs waiting for a job runner.",
+	}, func() float64 { return float64(len(ct.jobWatcher.jobChan)) }))
+	adminServer := admin.NewServer(cfg.Admin.Port)
+	adminServer.AddReadyCheck("informers", ct.informersSynced)
+	adminServer.Start()
+
 	return ct
 }
 
@@ -45,7 +86,7 @@ func Init() *Controller {
 }
 
 func (ct *Controller) jobRun(jobInfo models.JobInfo) {
-	jobRunner := NewJobRunner(jobInfo)
+	jobRunner := NewJobRunner(ct.client, ct.notifier, jobInfo)
 
 	jobRunner.Run()
 }
@@ -54,9 +95,11 @@ func (ct *Controller) scheduleJob(jobInfo models.JobInfo) {
 	go ct.jobRun(jobInfo)
 }
 
-func (ct *Controller) scheduleJobLoop() {
+func (ct *Controller) scheduleJobLoop(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case jobEvent := <-ct.jobWatcher.jobChan:
 			logger.Debug(nil, "scheduleJob %v", jobEvent)
 
@@ -67,31 +110,43 @@ func (ct *Controller) scheduleJobLoop() {
 	}
 }
 
-func (ct *Controller) cronRun(cronInfo models.CronInfo) {
+// scheduleCron starts the runner of the cron unless it has one. The runner is
+// scheduled and registered before the next cron event is handled, so that an
+// update or a deletion right after the creation finds it.
+func (ct *Controller) scheduleCron(cronInfo models.CronInfo) {
+	key := namespacedName(cronInfo.Namespace, cronInfo.Name)
+
 	ct.cronRunners.Lock()
-	_, ok := ct.cronRunners.Map[cronInfo.Name]
-	ct.cronRunners.Unlock()
-	if ok {
-		logger.Error(nil, "Controller cronRun error: cron %s already exists", cronInfo.Name)
+	defer ct.cronRunners.Unlock()
+	if _, ok := ct.cronRunners.Map[key]; ok {
+		logger.Error(nil, "Controller scheduleCron error: cron %s already exists", key)
 		return
 	}
 
-	cronRunner := NewCronRunner(ct.cronCore, cronInfo)
+	cronRunner := NewCronRunner(ct.client, ct.cronCore, ct.notifier, cronInfo)
+	cronRunner.schedule()
+	ct.cronRunners.Map[key] = cronRunner
+
+	go cronRunner.Run()
+}
 
+func (ct *Controller) updateCron(cronInfo models.CronInfo) {
 	ct.cronRunners.Lock()
-	ct.cronRunners.Map[cronInfo.Name] = cronRunner
+	cronRunner, ok := ct.cronRunners.Map[namespacedName(cronInfo.Namespace, cronInfo.Name)]
 	ct.cronRunners.Unlock()
+	if !ok {
+		ct.scheduleCron(cronInfo)
+		return
+	}
 
-	cronRunner.Run()
+	cronRunner.Update(cronInfo)
 }
 
-func (ct *Controller) scheduleCron(cronInfo models.CronInfo) {
-	go ct.cronRun(cronInfo)
-}
+func (ct *Controller) stopCron(namespace, name string) {
+	key := namespacedName(namespace, name)
 
-func (ct *Controller) stopCron(name string) {
 	ct.cronRunners.Lock()
-	cronRunner, ok := ct.cronRunners.Map[name]
+	cronRunner, ok := ct.cronRunners.Map[key]
 	ct.cronRunners.Unlock()
 	if !ok {
 		logger.Error(nil, "Controller stopCron error: cron does not exist")
@@ -101,29 +156,143 @@ func (ct *Controller) stopCron(name string) {
 	cronRunner.Stop()
 
 	ct.cronRunners.Lock()
-	delete(ct.cronRunners.Map, name)
+	delete(ct.cronRunners.Map, key)
 	ct.cronRunners.Unlock()
 }
 
-func (ct *Controller) scheduleCronLoop() {
+func (ct *Controller) scheduleCronLoop(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case cronEvent := <-ct.cronWatcher.cronChan:
 			logger.Info(nil, "scheduleCron %v", cronEvent)
 
 			switch cronEvent.Event {
 			case "ADD":
 				ct.scheduleCron(cronEvent.CronInfo)
+			case "MODIFY":
+				ct.updateCron(cronEvent.CronInfo)
+			case "DELETE":
+				ct.stopCron(cronEvent.CronInfo.Namespace, cronEvent.CronInfo.Name)
+				go ct.gc.DeleteCron(cronEvent.CronInfo.Namespace, cronEvent.CronInfo.Name)
+			}
+		}
+	}
+}
+
+// runTrigger runs the cron of the trigger once and deletes the trigger. A
+// trigger whose cron has no runner yet is kept and retried on the next resync.
+func (ct *Controller) runTrigger(triggerInfo models.TriggerInfo) {
+	key := namespacedName(triggerInfo.Namespace, triggerInfo.Name)
+	if _, ok := ct.triggers[key]; ok {
+		return
+	}
+
+	ct.cronRunners.Lock()
+	cronRunner, ok := ct.cronRunners.Map[namespacedName(triggerInfo.Namespace, triggerInfo.Cron)]
+	ct.cronRunners.Unlock()
+
+	if ok {
+		logger.Info(nil, "Controller run trigger [%s] of cron [%s]", triggerInfo.Name, triggerInfo.Cron)
+		cronRunner.Trigger()
+	} else {
+		_, err := ct.client.Crons(triggerInfo.Namespace).Get(context.Background(), triggerInfo.Cron)
+		if err == nil || !clientset.IsNotFound(err) {
+			logger.Info(nil, "Controller trigger [%s]: cron [%s] is not running yet", triggerInfo.Name, triggerInfo.Cron)
+			return
+		}
+		logger.Error(nil, "Controller trigger [%s] error: cron [%s] does not exist", triggerInfo.Name, triggerInfo.Cron)
+	}
+
+	ct.triggers[key] = struct{}{}
+	err := ct.client.Triggers(triggerInfo.Namespace).Delete(context.Background(), triggerInfo.Name)
+	if err != nil && !clientset.IsNotFound(err) {
+		logger.Error(nil, "Controller delete trigger [%s] error [%v]", triggerInfo.Name, err)
+	}
+}
+
+func (ct *Controller) scheduleTriggerLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case triggerEvent := <-ct.triggerWatcher.triggerChan:
+			logger.Debug(nil, "scheduleTrigger %v", triggerEvent)
+
+			switch triggerEvent.Event {
+			case "ADD", "MODIFY":
+				ct.runTrigger(triggerEvent.TriggerInfo)
 			case "DELETE":
-				ct.stopCron(cronEvent.CronInfo.Name)
+				delete(ct.triggers, namespacedName(triggerEvent.TriggerInfo.Namespace, triggerEvent.TriggerInfo.Name))
 			}
 		}
 	}
 }
 
-func (ct *Controller) Run() {
-	go ct.jobWatcher.Run()
-	go ct.cronWatcher.Run()
-	go ct.scheduleJobLoop()
-	ct.scheduleCronLoop()
+// informersSynced is the readiness check of the controller.
+func (ct *Controller) informersSynced() error {
+	var pending []string
+	if !ct.jobWatcher.HasSynced() {
+		pending = append(pending, "jobs")
+	}
+	if !ct.cronWatcher.HasSynced() {
+		pending = append(pending, "crons")
+	}
+	if !ct.triggerWatcher.HasSynced() {
+		pending = append(pending, "triggers")
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%s not synced yet", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// stopCronRunners stops every cron runner, they are started again from the
+// crons listed by the next controller.
+func (ct *Controller) stopCronRunners() {
+	ct.cronRunners.Lock()
+	runners := make([]*CronRunner, 0, len(ct.cronRunners.Map))
+	for key, cronRunner := range ct.cronRunners.Map {
+		runners = append(runners, cronRunner)
+		delete(ct.cronRunners.Map, key)
+	}
+	ct.cronRunners.Unlock()
+
+	for _, cronRunner := range runners {
+		cronRunner.Stop()
+	}
+}
+
+// Run runs the controller until ctx is done.
+func (ct *Controller) Run(ctx context.Context) {
+	ct.jobWatcher.Run()
+	ct.cronWatcher.Run()
+	ct.triggerWatcher.Run()
+
+	loopCtx, stopLoops := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, loop := range []func(context.Context){ct.gc.Run, ct.nodeMonitor.Run, ct.scheduleJobLoop, ct.scheduleTriggerLoop, ct.scheduleCronLoop} {
+		wg.Add(1)
+		go func(loop func(context.Context)) {
+			defer wg.Done()
+			loop(loopCtx)
+		}(loop)
+	}
+
+	<-ctx.Done()
+	logger.Info(nil, "Controller shutting down")
+
+	// The loops keep draining the channels until the informers stopped.
+	ct.jobWatcher.Stop()
+	ct.cronWatcher.Stop()
+	ct.triggerWatcher.Stop()
+	stopLoops()
+	wg.Wait()
+
+	ct.stopCronRunners()
+	<-ct.cronCore.Stop().Done()
+	ct.notifier.Stop()
+
+	logger.Info(nil, "Controller stopped")
 }
@@ -5,14 +5,14 @@
 package controller
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
 
-	"openpitrix.io/scheduler/pkg/client/writer"
-	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/constants"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
@@ -20,6 +20,9 @@ import (
 )
 
 type CronRunner struct {
+	sync.Mutex
+	client     clientset.Interface
+	notifier   *Notifier
 	entryId    cron.EntryID
 	cronCore   *cron.Cron
 	cronInfo   models.CronInfo
@@ -31,115 +34,180 @@ func NewJobId() string {
 	return idutil.GetUuid(constants.JobIdPrefix)
 }
 
+func (cr *CronRunner) getCronInfo() models.CronInfo {
+	cr.Lock()
+	defer cr.Unlock()
+
+	return cr.cronInfo
+}
+
 func (cr *CronRunner) cronFunc() {
+	cr.Lock()
+	entryId := cr.entryId
+	cr.Unlock()
+	// Prev is the scheduled time of the running tick.
+	if prev := cr.cronCore.Entry(entryId).Prev; !prev.IsZero() {
+		cronTickLateness.Observe(time.Since(prev).Seconds())
+	}
+
+	cronInfo := cr.getCronInfo()
+	if cronInfo.Suspend {
+		logger.Info(nil, "Cron [%s] is suspended, skip this run", cronInfo.Name)
+		return
+	}
+
+	cr.runJob(cronInfo, "")
+}
+
+// Trigger runs the job of the cron now, outside of its schedule and even if
+// the cron is suspended. The job is marked as triggered manually.
+func (cr *CronRunner) Trigger() {
+	cr.runJob(cr.getCronInfo(), constants.TriggerManual)
+}
+
+func (cr *CronRunner) runJob(cronInfo models.CronInfo, trigger string) {
 	jobId := NewJobId()
 
 	jobInfo := models.JobInfo{
-		Name:   jobId,
-		Owner:  cr.cronInfo.Name,
-		Cmd:    cr.cronInfo.Cmd,
-		Status: "Created",
+		Name:      jobId,
+		Namespace: cronInfo.Namespace,
+		Owner:     cronInfo.Name,
+		OwnerKind: constants.OwnerKindCron,
+		Labels:    copyLabels(cronInfo.Labels),
+		Cmd:       cronInfo.Cmd,
+		Container: cronInfo.Container,
+		Resources: cronInfo.Resources,
+		Inputs:    cronInfo.Inputs,
+		Outputs:   cronInfo.Outputs,
+		Status:    "Created",
+		Trigger:   trigger,
+
+		Notifications: cronInfo.Notifications,
 	}
 
-	cr.createJob(jobInfo)
+	if err := cr.createJob(jobInfo); err != nil && trigger == "" {
+		cr.notifier.MissedSchedule(cronInfo, time.Now(), cr.recordDeliveries)
+	}
 }
 
 func (cr *CronRunner) updateCron(cronInfo models.CronInfo) {
-	value, err := json.Marshal(cronInfo)
+	err := cr.client.Crons(cronInfo.Namespace).Update(context.Background(), &cronInfo)
 	if err != nil {
-		logger.Error(nil, "updateCron marshal cron info error [%v]", err)
-		return
-	}
-
-	info := models.APIInfo{
-		Info: string(value),
-		TTL:  0,
+		logger.Error(nil, "updateCron [%s] error [%v]", cronInfo.Name, err)
 	}
+}
 
-	value, err = json.Marshal(info)
+func (cr *CronRunner) createJob(jobInfo models.JobInfo) error {
+	err := cr.client.Jobs(jobInfo.Namespace).Create(context.Background(), &jobInfo)
 	if err != nil {
-		logger.Error(nil, "updateCron marshal info error [%v]", err)
-		return
+		logger.Error(nil, "createJob [%s] error [%v]", jobInfo.Name, err)
 	}
+	return err
+}
 
-	cfg := config.GetInstance()
+// recordDeliveries adds the deliveries of the notifications to the cron.
+func (cr *CronRunner) recordDeliveries(deliveries []models.NotificationDelivery) {
+	cr.Lock()
+	cr.cronInfo.Deliveries = appendDeliveries(cr.cronInfo.Deliveries, deliveries)
+	cronInfo := cr.cronInfo
+	cr.Unlock()
 
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	writer.WriteAPIServer(url, "crons", cronInfo.Name, string(value))
+	cr.updateCron(cronInfo)
 }
 
-func (cr *CronRunner) createJob(jobInfo models.JobInfo) {
-	value, err := json.Marshal(jobInfo)
-	if err != nil {
-		logger.Error(nil, "createJob marshal job info error [%v]", err)
+// checkMissedSchedule notifies when the cron did not run on schedule while
+// no controller was running it.
+func (cr *CronRunner) checkMissedSchedule() {
+	cronInfo := cr.getCronInfo()
+	if cronInfo.Suspend || !hasRule(cronInfo.Notifications, models.NotifyOnMissedSchedule) {
 		return
 	}
-
-	info := models.APIInfo{
-		Info: string(value),
-		TTL:  0,
+	schedule, err := cron.ParseStandard(cronInfo.Script)
+	if err != nil {
+		return
 	}
-
-	value, err = json.Marshal(info)
+	list, err := cr.client.Jobs(cronInfo.Namespace).List(context.Background(), clientset.ListOptions{Filter: "Owner=" + cronInfo.Name})
 	if err != nil {
-		logger.Error(nil, "createJob marshal info error [%v]", err)
+		logger.Error(nil, "checkMissedSchedule [%s] error [%v]", cronInfo.Name, err)
 		return
 	}
-
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	writer.WriteAPIServer(url, "jobs", jobInfo.Name, string(value))
+	if scheduled, missed := missedSchedule(schedule, list.Items, time.Now()); missed {
+		logger.Info(nil, "Cron [%s] missed its run of [%v]", cronInfo.Name, scheduled)
+		cr.notifier.MissedSchedule(cronInfo, scheduled, cr.recordDeliveries)
+	}
 }
 
-func NewCronRunner(cronCore *cron.Cron, cronInfo models.CronInfo) *CronRunner {
+func NewCronRunner(client clientset.Interface, cronCore *cron.Cron, notifier *Notifier, cronInfo models.CronInfo) *CronRunner {
 	cr := &CronRunner{
+		client:     client,
+		notifier:   notifier,
 		cronCore:   cronCore,
 		cronInfo:   cronInfo,
-		jobWatcher: NewJobWatcher(fmt.Sprintf("Owner=%s", cronInfo.Name)),
+		jobWatcher: NewJobWatcher(client, cronInfo.Namespace, fmt.Sprintf("Owner=%s", cronInfo.Name)),
 		stopChan:   make(chan string, 1),
 	}
 	return cr
 }
 
+// setStatus records the status of the last job and writes the cron back.
+func (cr *CronRunner) setStatus(status string, scheduled bool) {
+	cr.Lock()
+	cr.cronInfo.Status = status
+	if scheduled {
+		cr.cronInfo.LastScheduleTime = time.Now()
+	}
+	cronInfo := cr.cronInfo
+	cr.Unlock()
+
+	cr.updateCron(cronInfo)
+}
+
+// statusChanged tells if the job event moved the job to another status. The
+// resyncs and the updates of a finished job, eg. the deliveries of its
+// notifications, repeat its status.
+func statusChanged(jobEvent models.JobEvent) bool {
+	return jobEvent.Event == "MODIFY" && jobEvent.OldStatus != jobEvent.JobInfo.Status
+}
+
 func (cr *CronRunner) jobMonitor() {
 	defer close(cr.stopChan)
 
-	cronInfoMonitor := models.CronInfo{
-		Name:             cr.cronInfo.Name,
-		Script:           cr.cronInfo.Script,
-		Owner:            cr.cronInfo.Owner,
-		Cmd:              cr.cronInfo.Cmd,
-		Status:           cr.cronInfo.Status,
-		LastScheduleTime: cr.cronInfo.LastScheduleTime,
-	}
-
 	for {
 		select {
 		case <-cr.stopChan:
 			return
 		case jobEvent := <-cr.jobWatcher.jobChan:
 			logger.Info(nil, "jobMonitor %v", jobEvent)
+			if !statusChanged(jobEvent) {
+				continue
+			}
+			// Manual runs do not count as scheduled ones.
+			scheduled := jobEvent.JobInfo.Trigger != constants.TriggerManual
 			switch jobEvent.JobInfo.Status {
 			case "Running":
-				cronInfoMonitor.Status = "Active"
-				cr.updateCron(cronInfoMonitor)
-			case "Completed":
-				cronInfoMonitor.Status = ""
-				cronInfoMonitor.LastScheduleTime = time.Now()
-				cr.updateCron(cronInfoMonitor)
+				cr.setStatus("Active", false)
+			case "Completed", "Failed":
+				cr.setStatus("", scheduled)
+				cronJobsTotal.WithLabelValues(jobEvent.JobInfo.Namespace, jobEvent.JobInfo.Owner, jobEvent.JobInfo.Status).Inc()
 			}
 		}
 	}
 }
 
-func (cr *CronRunner) Run() {
+// schedule adds the cron to the cron core, before Run.
+func (cr *CronRunner) schedule() {
 	logger.Info(nil, "Cron Runner Starting Cron[%v]", cr.cronInfo)
 
+	cr.Lock()
 	cr.entryId, _ = cr.cronCore.AddFunc(cr.cronInfo.Script, cr.cronFunc)
+	cr.Unlock()
 
 	logger.Info(nil, "Cron Runner Started Cron[%d]", cr.entryId)
+}
 
+// Run follows the jobs of the scheduled cron until it is stopped.
+func (cr *CronRunner) Run() {
+	cr.checkMissedSchedule()
 	cr.jobWatcher.watchJobs()
 
 	cr.jobMonitor()
@@ -147,9 +215,31 @@ func (cr *CronRunner) Run() {
 	logger.Info(nil, "Cron Runner Stopped Cron %d", cr.entryId)
 }
 
+// Update applies a modified cron, the schedule is replaced when it changed.
+func (cr *CronRunner) Update(cronInfo models.CronInfo) {
+	cr.Lock()
+	defer cr.Unlock()
+
+	if cronInfo.Script != cr.cronInfo.Script {
+		entryId, err := cr.cronCore.AddFunc(cronInfo.Script, cr.cronFunc)
+		if err != nil {
+			logger.Error(nil, "Cron Runner Update Cron [%s] invalid script [%s]: %v", cronInfo.Name, cronInfo.Script, err)
+			return
+		}
+		cr.cronCore.Remove(cr.entryId)
+		cr.entryId = entryId
+	}
+
+	cr.cronInfo = cronInfo
+}
+
 func (cr *CronRunner) Stop() {
 	logger.Info(nil, "Cron Runner Stopping Cron %d", cr.entryId)
 
+	cr.Lock()
 	cr.cronCore.Remove(cr.entryId)
+	cr.Unlock()
+	// The job monitor drains the job events until the informer stopped.
+	cr.jobWatcher.Stop()
 	cr.stopChan <- "stop"
 }
@@ -0,0 +1,26 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestStatusChanged(t *testing.T) {
+	event := func(event string, oldStatus string, status string) models.JobEvent {
+		return models.JobEvent{Event: event, OldStatus: oldStatus, JobInfo: models.JobInfo{Status: status}}
+	}
+
+	assert.True(t, statusChanged(event("MODIFY", "Created", "Running")))
+	assert.True(t, statusChanged(event("MODIFY", "Running", "Failed")))
+	// The deliveries of the notifications of a finished job.
+	assert.False(t, statusChanged(event("MODIFY", "Failed", "Failed")))
+	assert.False(t, statusChanged(event("ADD", "", "Created")))
+	assert.False(t, statusChanged(event("DELETE", "", "Completed")))
+}
@@ -6,23 +6,25 @@ package controller
 
 import (
 	"encoding/json"
-	"fmt"
-	"strings"
 
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/client/informer"
-	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
 type CronWatcher struct {
+	client   clientset.Interface
 	filter   string
+	informer *informer.Informer
 	cronChan chan models.CronEvent
 }
 
-func NewCronWatcher(filter string) *CronWatcher {
+func NewCronWatcher(client clientset.Interface, filter string) *CronWatcher {
 	cw := &CronWatcher{
+		client:   client,
 		filter:   filter,
+		informer: client.Crons("").Informer(filter, 0),
 		cronChan: make(chan models.CronEvent, 100),
 	}
 
@@ -33,7 +35,7 @@ func (cw *CronWatcher) scheduleCron(event string, key string, value []byte) {
 	cronInfo := models.CronInfo{}
 
 	if event == "DELETE" {
-		cronInfo.Name = strings.TrimPrefix(key, "crons/")
+		cronInfo.Namespace, cronInfo.Name = models.SplitKey(key)
 	} else {
 		err := json.Unmarshal(value, &cronInfo)
 		if err != nil {
@@ -51,16 +53,7 @@ func (cw *CronWatcher) scheduleCron(event string, key string, value []byte) {
 }
 
 func (cw *CronWatcher) watchCrons() {
-	cfg := config.GetInstance()
-
-	informerURL := fmt.Sprintf("http://%s:%s/api/v1alpha1/crons/?watch=true", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	if cw.filter == "" {
-		informerURL = fmt.Sprintf("http://%s:%s/api/v1alpha1/crons/?watch=true", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	} else {
-		informerURL = fmt.Sprintf("http://%s:%s/api/v1alpha1/crons/?watch=true&filter=%s", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort, cw.filter)
-	}
-
-	cronInformer := informer.NewInformer(informerURL)
+	cronInformer := cw.informer
 
 	cronInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -101,3 +94,13 @@ func (cw *CronWatcher) watchCrons() {
 func (cw *CronWatcher) Run() {
 	cw.watchCrons()
 }
+
+// Stop stops watching the crons.
+func (cw *CronWatcher) Stop() {
+	cw.informer.Stop()
+}
+
+// HasSynced tells if the first list of the crons has been handled.
+func (cw *CronWatcher) HasSynced() bool {
+	return cw.informer.HasSynced()
+}
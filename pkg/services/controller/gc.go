@@ -0,0 +1,269 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// GarbageCollector deletes the finished jobs that are past their TTL or
+// beyond the history limits of their cron, together with their tasks. It
+// follows the Owner references: a task is owned by a job and a job by a cron.
+type GarbageCollector struct {
+	client clientset.Interface
+	period time.Duration
+}
+
+func NewGarbageCollector(client clientset.Interface, period time.Duration) *GarbageCollector {
+	return &GarbageCollector{
+		client: client,
+		period: period,
+	}
+}
+
+func finished(jobInfo models.JobInfo) bool {
+	return jobInfo.Status == "Completed" || jobInfo.Status == "Failed"
+}
+
+func historyLimit(limit *int, defaultLimit int) int {
+	if limit == nil || *limit < 0 {
+		return defaultLimit
+	}
+	return *limit
+}
+
+// expiredJobs returns the finished jobs to delete at now.
+func expiredJobs(crons []models.CronInfo, jobs []models.JobInfo, now time.Time) []models.JobInfo {
+	var expired []models.JobInfo
+	history := make(map[string][]models.JobInfo)
+
+	for _, jobInfo := range jobs {
+		if !finished(jobInfo) {
+			continue
+		}
+		ttl := jobInfo.TTLSecondsAfterFinished
+		if ttl != nil && !now.Before(jobInfo.CompleteTime.Add(time.Duration(*ttl)*time.Second)) {
+			expired = append(expired, jobInfo)
+			continue
+		}
+		owner := namespacedName(jobInfo.Namespace, jobInfo.Owner)
+		history[owner] = append(history[owner], jobInfo)
+	}
+
+	for _, cronInfo := range crons {
+		var succeeded, failed []models.JobInfo
+		for _, jobInfo := range history[namespacedName(cronInfo.Namespace, cronInfo.Name)] {
+			if jobInfo.Status == "Completed" {
+				succeeded = append(succeeded, jobInfo)
+			} else {
+				failed = append(failed, jobInfo)
+			}
+		}
+		expired = append(expired, oldestJobs(succeeded, historyLimit(cronInfo.SuccessfulJobsHistoryLimit, constants.DefaultSuccessfulJobsHistoryLimit))...)
+		expired = append(expired, oldestJobs(failed, historyLimit(cronInfo.FailedJobsHistoryLimit, constants.DefaultFailedJobsHistoryLimit))...)
+	}
+
+	return expired
+}
+
+// oldestJobs returns the jobs beyond the limit most recent ones.
+func oldestJobs(jobs []models.JobInfo, limit int) []models.JobInfo {
+	if len(jobs) <= limit {
+		return nil
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CompleteTime.After(jobs[j].CompleteTime)
+	})
+	return jobs[limit:]
+}
+
+// deleteJob deletes the tasks of the job first, so that no task is left
+// without its job if the collector stops halfway.
+func (gc *GarbageCollector) deleteJob(ctx context.Context, namespace, name string) error {
+	list, err := gc.client.Tasks(namespace).List(ctx, clientset.ListOptions{Filter: "Owner=" + name})
+	if err != nil {
+		return err
+	}
+	for _, taskInfo := range list.Items {
+		err := gc.client.Tasks(namespace).Delete(ctx, taskInfo.Name)
+		if err != nil && !clientset.IsNotFound(err) {
+			return err
+		}
+	}
+
+	err = gc.client.Jobs(namespace).Delete(ctx, name)
+	if err != nil && !clientset.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteCron cascades the deletion of a cron to its jobs and their tasks.
+func (gc *GarbageCollector) DeleteCron(namespace, name string) {
+	ctx := context.Background()
+
+	list, err := gc.client.Jobs(namespace).List(ctx, clientset.ListOptions{Filter: "Owner=" + name})
+	if err != nil {
+		logger.Error(nil, "GarbageCollector list jobs of cron [%s] error [%v]", name, err)
+		return
+	}
+
+	for _, jobInfo := range list.Items {
+		if err := gc.deleteJob(ctx, namespace, jobInfo.Name); err != nil {
+			logger.Error(nil, "GarbageCollector delete job [%s] of cron [%s] error [%v]", jobInfo.Name, name, err)
+			continue
+		}
+		logger.Info(nil, "GarbageCollector deleted job [%s] of cron [%s]", jobInfo.Name, name)
+	}
+}
+
+// orphanTasks returns the tasks owned by a job, as the job ids tell, which is
+// not in jobs. The tasks created through the API or owned by anything else
+// are left alone.
+func orphanTasks(tasks []models.TaskInfo, jobs []models.JobInfo) []models.TaskInfo {
+	jobNames := make(map[string]struct{}, len(jobs))
+	for _, jobInfo := range jobs {
+		jobNames[namespacedName(jobInfo.Namespace, jobInfo.Name)] = struct{}{}
+	}
+
+	var orphans []models.TaskInfo
+	for _, taskInfo := range tasks {
+		if !strings.HasPrefix(taskInfo.Owner, constants.JobIdPrefix) {
+			continue
+		}
+		if _, ok := jobNames[namespacedName(taskInfo.Namespace, taskInfo.Owner)]; ok {
+			continue
+		}
+		orphans = append(orphans, taskInfo)
+	}
+	return orphans
+}
+
+// orphanJobs returns the finished jobs owned by a cron, as OwnerKind tells,
+// which is not in crons. The cron was deleted while the controller did not
+// see it, the jobs are deleted as DeleteCron would have.
+func orphanJobs(crons []models.CronInfo, jobs []models.JobInfo) []models.JobInfo {
+	cronNames := make(map[string]struct{}, len(crons))
+	for _, cronInfo := range crons {
+		cronNames[namespacedName(cronInfo.Namespace, cronInfo.Name)] = struct{}{}
+	}
+
+	var orphans []models.JobInfo
+	for _, jobInfo := range jobs {
+		if jobInfo.OwnerKind != constants.OwnerKindCron || jobInfo.Owner == "" || !finished(jobInfo) {
+			continue
+		}
+		if _, ok := cronNames[namespacedName(jobInfo.Namespace, jobInfo.Owner)]; ok {
+			continue
+		}
+		orphans = append(orphans, jobInfo)
+	}
+	return orphans
+}
+
+// countTasks sets the tasks metric, the statuses no task has any more are
+// reset to zero.
+func countTasks(tasks []models.TaskInfo) {
+	tasksByStatus.Reset()
+	for _, status := range []string{"Pending", "Scheduled", "Running", "Completed", "Failed"} {
+		tasksByStatus.WithLabelValues(status)
+	}
+	for _, taskInfo := range tasks {
+		tasksByStatus.WithLabelValues(taskInfo.Status).Inc()
+	}
+}
+
+func (gc *GarbageCollector) collect() error {
+	ctx := context.Background()
+
+	// The tasks are listed before the jobs, a task created meanwhile belongs
+	// to a job of the job list and is not taken for an orphan.
+	tasks, err := gc.client.Tasks("").List(ctx, clientset.ListOptions{})
+	if err != nil {
+		return err
+	}
+	jobs, err := gc.client.Jobs("").List(ctx, clientset.ListOptions{})
+	if err != nil {
+		return err
+	}
+	crons, err := gc.client.Crons("").List(ctx, clientset.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	countTasks(tasks.Items)
+
+	deleted := make(map[string]bool)
+	for _, jobInfo := range expiredJobs(crons.Items, jobs.Items, time.Now()) {
+		if err := gc.deleteJob(ctx, jobInfo.Namespace, jobInfo.Name); err != nil {
+			logger.Error(nil, "GarbageCollector delete job [%s] error [%v]", jobInfo.Name, err)
+			continue
+		}
+		deleted[namespacedName(jobInfo.Namespace, jobInfo.Name)] = true
+		logger.Info(nil, "GarbageCollector deleted job [%s] finished at %s", jobInfo.Name, jobInfo.CompleteTime)
+	}
+
+	for _, jobInfo := range orphanJobs(crons.Items, jobs.Items) {
+		if deleted[namespacedName(jobInfo.Namespace, jobInfo.Name)] {
+			continue
+		}
+		// Only a cron confirmed deleted leaves its jobs orphaned.
+		_, err := gc.client.Crons(jobInfo.Namespace).Get(ctx, jobInfo.Owner)
+		if !clientset.IsNotFound(err) {
+			if err != nil {
+				logger.Error(nil, "GarbageCollector get cron [%s] of job [%s] error [%v]", jobInfo.Owner, jobInfo.Name, err)
+			}
+			continue
+		}
+		if err := gc.deleteJob(ctx, jobInfo.Namespace, jobInfo.Name); err != nil {
+			logger.Error(nil, "GarbageCollector delete orphan job [%s] error [%v]", jobInfo.Name, err)
+			continue
+		}
+		logger.Info(nil, "GarbageCollector deleted orphan job [%s] of cron [%s]", jobInfo.Name, jobInfo.Owner)
+	}
+
+	for _, taskInfo := range orphanTasks(tasks.Items, jobs.Items) {
+		// Only a job confirmed deleted leaves its tasks orphaned.
+		_, err := gc.client.Jobs(taskInfo.Namespace).Get(ctx, taskInfo.Owner)
+		if !clientset.IsNotFound(err) {
+			if err != nil {
+				logger.Error(nil, "GarbageCollector get job [%s] of task [%s] error [%v]", taskInfo.Owner, taskInfo.Name, err)
+			}
+			continue
+		}
+		err = gc.client.Tasks(taskInfo.Namespace).Delete(ctx, taskInfo.Name)
+		if err != nil && !clientset.IsNotFound(err) {
+			logger.Error(nil, "GarbageCollector delete orphan task [%s] error [%v]", taskInfo.Name, err)
+			continue
+		}
+		logger.Info(nil, "GarbageCollector deleted orphan task [%s] of job [%s]", taskInfo.Name, taskInfo.Owner)
+	}
+
+	return nil
+}
+
+func (gc *GarbageCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(gc.period)
+	defer ticker.Stop()
+
+	for {
+		if err := gc.collect(); err != nil {
+			logger.Error(nil, "GarbageCollector collect error [%v]", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
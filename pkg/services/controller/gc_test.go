@@ -0,0 +1,95 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestExpiredJobs(t *testing.T) {
+	now := time.Now()
+	ago := func(minutes int) time.Time {
+		return now.Add(-time.Duration(minutes) * time.Minute)
+	}
+	one := 1
+	ttl := int64(600)
+
+	crons := []models.CronInfo{
+		{Name: "c-default"},
+		{Name: "c-limited", SuccessfulJobsHistoryLimit: &one},
+	}
+	jobs := []models.JobInfo{
+		// Default limits: 3 successful and 1 failed jobs.
+		{Name: "j-1", Owner: "c-default", Status: "Completed", CompleteTime: ago(1)},
+		{Name: "j-2", Owner: "c-default", Status: "Completed", CompleteTime: ago(2)},
+		{Name: "j-3", Owner: "c-default", Status: "Completed", CompleteTime: ago(3)},
+		{Name: "j-4", Owner: "c-default", Status: "Completed", CompleteTime: ago(4)},
+		{Name: "j-5", Owner: "c-default", Status: "Failed", CompleteTime: ago(5)},
+		{Name: "j-6", Owner: "c-default", Status: "Failed", CompleteTime: ago(6)},
+		{Name: "j-7", Owner: "c-default", Status: "Running", StartTime: ago(60)},
+		{Name: "j-8", Owner: "c-limited", Status: "Completed", CompleteTime: ago(1)},
+		{Name: "j-9", Owner: "c-limited", Status: "Completed", CompleteTime: ago(2)},
+		// Jobs outside of a cron only expire with their TTL.
+		{Name: "j-10", Owner: "schedctl", Status: "Completed", CompleteTime: ago(5), TTLSecondsAfterFinished: &ttl},
+		{Name: "j-11", Owner: "schedctl", Status: "Completed", CompleteTime: ago(15), TTLSecondsAfterFinished: &ttl},
+		{Name: "j-12", Owner: "schedctl", Status: "Completed", CompleteTime: ago(15)},
+		// A cron of the same name in another namespace has its own history.
+		{Name: "j-13", Namespace: "team-a", Owner: "c-limited", Status: "Completed", CompleteTime: ago(3)},
+	}
+
+	var names []string
+	for _, jobInfo := range expiredJobs(crons, jobs, now) {
+		names = append(names, jobInfo.Name)
+	}
+	sort.Strings(names)
+
+	assert.Equal(t, []string{"j-11", "j-4", "j-6", "j-9"}, names)
+}
+
+func TestOrphanTasks(t *testing.T) {
+	jobs := []models.JobInfo{{Name: "j-1"}, {Namespace: "team-a", Name: "j-2"}}
+	tasks := []models.TaskInfo{
+		{Name: "t-1", Owner: "j-1"},
+		{Name: "t-2", Owner: "j-2"},
+		{Name: "t-3", Namespace: "team-a", Owner: "j-2"},
+		{Name: "t-4", Owner: "j-3"},
+		// Tasks created through the API or owned by anything else.
+		{Name: "t-5"},
+		{Name: "t-6", Owner: "pipeline"},
+	}
+
+	var names []string
+	for _, taskInfo := range orphanTasks(tasks, jobs) {
+		names = append(names, taskInfo.Name)
+	}
+	assert.Equal(t, []string{"t-2", "t-4"}, names)
+}
+
+func TestOrphanJobs(t *testing.T) {
+	crons := []models.CronInfo{{Name: "nightly"}, {Namespace: "team-a", Name: "hourly"}}
+	jobs := []models.JobInfo{
+		{Name: "j-1", Owner: "nightly", OwnerKind: "Cron", Status: "Completed"},
+		{Name: "j-2", Owner: "hourly", OwnerKind: "Cron", Status: "Failed"},
+		{Name: "j-3", Namespace: "team-a", Owner: "hourly", OwnerKind: "Cron", Status: "Completed"},
+		{Name: "j-4", Owner: "weekly", OwnerKind: "Cron", Status: "Completed"},
+		// A running job is left to finish.
+		{Name: "j-5", Owner: "weekly", OwnerKind: "Cron", Status: "Running"},
+		// Jobs which no cron owns.
+		{Name: "j-6", Owner: "schedctl", Status: "Completed"},
+		{Name: "j-7", Status: "Completed"},
+	}
+
+	var names []string
+	for _, jobInfo := range orphanJobs(crons, jobs) {
+		names = append(names, jobInfo.Name)
+	}
+	assert.Equal(t, []string{"j-2", "j-4"}, names)
+}
@@ -5,90 +5,128 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
-	"openpitrix.io/scheduler/pkg/client/writer"
-	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/constants"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 	"openpitrix.io/scheduler/pkg/util/idutil"
 )
 
+// The creation of the task of a job refused by the quota of its namespace
+// is retried, for over a minute so that MaxTasksPerMinute lets it through.
+var (
+	taskCreateAttempts = 5
+	taskCreateBackoff  = 5 * time.Second
+)
+
 type JobRunner struct {
+	client      clientset.Interface
+	notifier    *Notifier
 	jobInfo     models.JobInfo
 	taskWatcher *TaskWatcher
+	// stopChan stops the task monitor of a job whose task was not created.
+	stopChan chan struct{}
 }
 
 func NewTaskId() string {
 	return idutil.GetUuid(constants.TaskIdPrefix)
 }
 
-func (jr *JobRunner) updateJob(jobInfo models.JobInfo) {
-	value, err := json.Marshal(jobInfo)
-	if err != nil {
-		logger.Error(nil, "updateJob marshal job info error [%v]", err)
-		return
+// copyLabels copies the labels an owner passes down to the objects it
+// creates, so that a cron, its jobs and their tasks share the same labels.
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
 	}
-
-	info := models.APIInfo{
-		Info: string(value),
-		TTL:  0,
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
 	}
+	return copied
+}
 
-	value, err = json.Marshal(info)
+func (jr *JobRunner) updateJob(jobInfo models.JobInfo) {
+	err := jr.client.Jobs(jobInfo.Namespace).Update(context.Background(), &jobInfo)
 	if err != nil {
-		logger.Error(nil, "updateJob marshal info error [%v]", err)
-		return
+		logger.Error(nil, "updateJob [%s] error [%v]", jobInfo.Name, err)
 	}
-
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	writer.WriteAPIServer(url, "jobs", jobInfo.Name, string(value))
 }
 
-func (jr *JobRunner) createTask(taskInfo models.TaskInfo) {
-	value, err := json.Marshal(taskInfo)
-	if err != nil {
-		logger.Error(nil, "createTask marshal task info error [%v]", err)
-		return
+// createTask creates the task of the job, retrying with a backoff while the
+// quota of the namespace refuses it.
+func (jr *JobRunner) createTask(taskInfo models.TaskInfo) error {
+	backoff := taskCreateBackoff
+	for attempt := 1; ; attempt++ {
+		err := jr.client.Tasks(taskInfo.Namespace).Create(context.Background(), &taskInfo)
+		if err == nil {
+			return nil
+		}
+		if !clientset.IsForbidden(err) || attempt >= taskCreateAttempts {
+			logger.Error(nil, "createTask [%s] error [%v]", taskInfo.Name, err)
+			return err
+		}
+		logger.Info(nil, "createTask [%s] refused, retrying in %s: %v", taskInfo.Name, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
+}
 
-	info := models.APIInfo{
-		Info: string(value),
-		TTL:  0,
+// consecutiveFailures counts the failures in a row of the cron of the
+// finished job, the jobs of no cron fail alone.
+func (jr *JobRunner) consecutiveFailures(jobInfo models.JobInfo) int {
+	if jobInfo.Status != "Failed" || jobInfo.Owner == "" {
+		return consecutiveFailures(jobInfo, nil)
+	}
+	list, err := jr.client.Jobs(jobInfo.Namespace).List(context.Background(), clientset.ListOptions{Filter: "Owner=" + jobInfo.Owner})
+	if err != nil {
+		logger.Error(nil, "consecutiveFailures [%s] error [%v]", jobInfo.Name, err)
+		return consecutiveFailures(jobInfo, nil)
 	}
+	return consecutiveFailures(jobInfo, list.Items)
+}
 
-	value, err = json.Marshal(info)
+// recordDeliveries adds the deliveries of the notifications to the job as
+// it is stored now.
+func (jr *JobRunner) recordDeliveries(deliveries []models.NotificationDelivery) {
+	ctx := context.Background()
+	jobInfo, err := jr.client.Jobs(jr.jobInfo.Namespace).Get(ctx, jr.jobInfo.Name)
 	if err != nil {
-		logger.Error(nil, "createTask marshal info error [%v]", err)
+		logger.Error(nil, "recordDeliveries [%s] error [%v]", jr.jobInfo.Name, err)
 		return
 	}
-
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	writer.WriteAPIServer(url, "tasks", taskInfo.Name, string(value))
+	jobInfo.Deliveries = appendDeliveries(jobInfo.Deliveries, deliveries)
+	jr.updateJob(*jobInfo)
 }
 
-func NewJobRunner(jobInfo models.JobInfo) *JobRunner {
+func NewJobRunner(client clientset.Interface, notifier *Notifier, jobInfo models.JobInfo) *JobRunner {
 	jr := &JobRunner{
+		client:      client,
+		notifier:    notifier,
 		jobInfo:     jobInfo,
-		taskWatcher: NewTaskWatcher(jobInfo.Name),
+		taskWatcher: NewTaskWatcher(client, jobInfo.Namespace, jobInfo.Name),
+		stopChan:    make(chan struct{}),
 	}
 	return jr
 }
 
-func (jr *JobRunner) taskMonitor(wg *sync.WaitGroup) {
-	jobInfoNew := models.JobInfo{
-		Name:  jr.jobInfo.Name,
-		Owner: jr.jobInfo.Owner,
-		Cmd:   jr.jobInfo.Cmd,
+// finishJob records the job Completed or Failed and sends its
+// notifications.
+func (jr *JobRunner) finishJob(jobInfo models.JobInfo) {
+	jobInfo.CompleteTime = time.Now()
+	if len(jobInfo.Notifications) > 0 {
+		jobInfo.ConsecutiveFailures = jr.consecutiveFailures(jobInfo)
 	}
+	jr.updateJob(jobInfo)
+	jr.notifier.JobFinished(jobInfo, jr.recordDeliveries)
+}
+
+func (jr *JobRunner) taskMonitor(wg *sync.WaitGroup) {
+	// Keep the fields set by the creator of the job, eg. its trigger.
+	jobInfoNew := jr.jobInfo
 
 	for {
 		select {
@@ -99,13 +137,20 @@ func (jr *JobRunner) taskMonitor(wg *sync.WaitGroup) {
 				jobInfoNew.Status = "Running"
 				jobInfoNew.StartTime = time.Now()
 				jr.updateJob(jobInfoNew)
-			case "Completed":
-				jobInfoNew.Status = "Completed"
-				jobInfoNew.CompleteTime = time.Now()
-				jr.updateJob(jobInfoNew)
+			case "Completed", "Failed":
+				jobInfoNew.Status = taskInfo.Status
+				jobInfoNew.Artifacts = taskInfo.Artifacts
+				jr.finishJob(jobInfoNew)
+				wg.Done()
+				return
+			case "Deleted":
+				// The job is being garbage collected with its tasks.
 				wg.Done()
 				return
 			}
+		case <-jr.stopChan:
+			wg.Done()
+			return
 		}
 	}
 }
@@ -121,15 +166,33 @@ func (jr *JobRunner) Run() {
 	go jr.taskMonitor(&wg)
 
 	taskInfo := models.TaskInfo{
-		Name:   taskId,
-		Owner:  jr.jobInfo.Name,
-		Cmd:    jr.jobInfo.Cmd,
-		Status: "Pending",
+		Name:       taskId,
+		Namespace:  jr.jobInfo.Namespace,
+		Owner:      jr.jobInfo.Name,
+		Labels:     copyLabels(jr.jobInfo.Labels),
+		Cmd:        jr.jobInfo.Cmd,
+		Container:  jr.jobInfo.Container,
+		Resources:  jr.jobInfo.Resources,
+		Inputs:     jr.jobInfo.Inputs,
+		Outputs:    jr.jobInfo.Outputs,
+		Status:     "Pending",
+		CreateTime: time.Now(),
 	}
 
-	jr.createTask(taskInfo)
+	if err := jr.createTask(taskInfo); err != nil {
+		// No task will end the job, it fails now.
+		close(jr.stopChan)
+		jobInfo := jr.jobInfo
+		jobInfo.Status = "Failed"
+		jobInfo.Reason = models.JobReasonTaskCreateFailed
+		if clientset.IsForbidden(err) {
+			jobInfo.Reason = models.JobReasonQuotaExceeded
+		}
+		jr.finishJob(jobInfo)
+	}
 
 	wg.Wait()
+	jr.taskWatcher.Stop()
 
 	logger.Info(nil, "Job Runner Complete Job[%v]", jr.jobInfo)
 }
@@ -0,0 +1,54 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestCreateTask(t *testing.T) {
+	var mutex sync.Mutex
+	refusals := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if refusals > 0 {
+			refusals--
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "namespace [default] exceeded quota MaxTasksPerMinute=1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	defer func(attempts int, backoff time.Duration) {
+		taskCreateAttempts, taskCreateBackoff = attempts, backoff
+	}(taskCreateAttempts, taskCreateBackoff)
+	taskCreateAttempts, taskCreateBackoff = 3, time.Millisecond
+
+	client, err := clientset.NewForConfig(&clientset.Config{Host: server.URL})
+	assert.NoError(t, err)
+	jr := &JobRunner{client: client}
+	taskInfo := models.TaskInfo{Name: "t-1", Namespace: "default"}
+
+	// Refused twice, created on the third attempt.
+	assert.NoError(t, jr.createTask(taskInfo))
+
+	mutex.Lock()
+	refusals = 3
+	mutex.Unlock()
+	err = jr.createTask(taskInfo)
+	assert.True(t, clientset.IsForbidden(err), "%v", err)
+}
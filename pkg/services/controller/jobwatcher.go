@@ -6,29 +6,35 @@ package controller
 
 import (
 	"encoding/json"
-	"fmt"
 
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/client/informer"
-	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
 type JobWatcher struct {
-	filter  string
-	jobChan chan models.JobEvent
+	client clientset.Interface
+	// namespace of the jobs, empty watches every namespace.
+	namespace string
+	filter    string
+	informer  *informer.Informer
+	jobChan   chan models.JobEvent
 }
 
-func NewJobWatcher(filter string) *JobWatcher {
+func NewJobWatcher(client clientset.Interface, namespace string, filter string) *JobWatcher {
 	jw := &JobWatcher{
-		filter:  filter,
-		jobChan: make(chan models.JobEvent, 100),
+		client:    client,
+		namespace: namespace,
+		filter:    filter,
+		informer:  client.Jobs(namespace).Informer(filter, 0),
+		jobChan:   make(chan models.JobEvent, 100),
 	}
 
 	return jw
 }
 
-func (jw *JobWatcher) scheduleJob(event string, value []byte) {
+func (jw *JobWatcher) scheduleJob(event string, value []byte, oldValue []byte) {
 	jobInfo := models.JobInfo{}
 
 	err := json.Unmarshal(value, &jobInfo)
@@ -41,21 +47,18 @@ func (jw *JobWatcher) scheduleJob(event string, value []byte) {
 		Event:   event,
 		JobInfo: jobInfo,
 	}
+	if oldValue != nil {
+		oldJobInfo := models.JobInfo{}
+		if err := json.Unmarshal(oldValue, &oldJobInfo); err == nil {
+			jobEvent.OldStatus = oldJobInfo.Status
+		}
+	}
 
 	jw.jobChan <- jobEvent
 }
 
 func (jw *JobWatcher) watchJobs() {
-	cfg := config.GetInstance()
-
-	informerURL := fmt.Sprintf("http://%s:%s/api/v1alpha1/jobs/?watch=true", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	if jw.filter == "" {
-		informerURL = fmt.Sprintf("http://%s:%s/api/v1alpha1/jobs/?watch=true", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	} else {
-		informerURL = fmt.Sprintf("http://%s:%s/api/v1alpha1/jobs/?watch=true&filter=%s", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort, jw.filter)
-	}
-
-	jobInformer := informer.NewInformer(informerURL)
+	jobInformer := jw.informer
 
 	jobInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -63,7 +66,7 @@ func (jw *JobWatcher) watchJobs() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				jw.scheduleJob("ADD", info.Value)
+				jw.scheduleJob("ADD", info.Value, nil)
 			} else {
 				logger.Error(nil, "watchJobs data error")
 			}
@@ -73,7 +76,7 @@ func (jw *JobWatcher) watchJobs() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				jw.scheduleJob("DELETE", info.Value)
+				jw.scheduleJob("DELETE", info.Value, nil)
 			} else {
 				logger.Error(nil, "watchJobs data error")
 			}
@@ -82,8 +85,9 @@ func (jw *JobWatcher) watchJobs() {
 			logger.Info(nil, "watchJobs updated job: %v", newObj)
 
 			info, ok := (newObj).(models.Info)
+			oldInfo, _ := (oldObj).(models.Info)
 			if ok {
-				jw.scheduleJob("MODIFY", info.Value)
+				jw.scheduleJob("MODIFY", info.Value, oldInfo.Value)
 			} else {
 				logger.Error(nil, "watchJobs data error")
 			}
@@ -96,3 +100,13 @@ func (jw *JobWatcher) watchJobs() {
 func (jw *JobWatcher) Run() {
 	jw.watchJobs()
 }
+
+// Stop stops watching the jobs.
+func (jw *JobWatcher) Stop() {
+	jw.informer.Stop()
+}
+
+// HasSynced tells if the first list of the jobs has been handled.
+func (jw *JobWatcher) HasSynced() bool {
+	return jw.informer.HasSynced()
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"openpitrix.io/scheduler/pkg/metrics"
+)
+
+const subsystem = "controller"
+
+var (
+	cronTickLateness = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "cron_tick_lateness_seconds",
+		Help:      "Delay between the scheduled time of a cron and its run.",
+		Buckets:   []float64{.001, .01, .1, .5, 1, 5, 10, 30, 60},
+	})
+
+	// Alert on increase(scheduler_controller_cron_jobs_total{status="Failed"}[1h]) > 0.
+	cronJobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "cron_jobs_total",
+		Help:      "Finished jobs of the crons by status.",
+	}, []string{"namespace", "cron", "status"})
+
+	tasksByStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "tasks",
+		Help:      "Tasks by status, counted by the garbage collector.",
+	}, []string{"status"})
+
+	notificationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "notifications_total",
+		Help:      "Notifications sent by event and result.",
+	}, []string{"on", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(cronTickLateness, cronJobsTotal, tasksByStatus, notificationsTotal)
+}
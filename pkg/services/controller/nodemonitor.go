@@ -0,0 +1,103 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// NodeMonitor marks the nodes whose heartbeats stopped NotReady. The
+// scheduler then stops placing tasks there, well before the lease of the
+// node expires and its entry is deleted.
+type NodeMonitor struct {
+	client clientset.Interface
+	period time.Duration
+	// gracePeriod is how old the last heartbeat of a ready node may be.
+	gracePeriod time.Duration
+}
+
+func NewNodeMonitor(client clientset.Interface, period, gracePeriod time.Duration) *NodeMonitor {
+	return &NodeMonitor{
+		client:      client,
+		period:      period,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// staleNodes returns the ready nodes whose last heartbeat is older than
+// gracePeriod at now. Agents which do not report their status are left out.
+func staleNodes(nodes []models.NodeInfo, gracePeriod time.Duration, now time.Time) []models.NodeInfo {
+	var stale []models.NodeInfo
+	for _, nodeInfo := range nodes {
+		if nodeInfo.Status == nil || nodeInfo.Status.LastHeartbeatTime.IsZero() || !nodeInfo.IsReady() {
+			continue
+		}
+		if now.Sub(nodeInfo.Status.LastHeartbeatTime) > gracePeriod {
+			stale = append(stale, nodeInfo)
+		}
+	}
+	return stale
+}
+
+// unknownStatus returns status with every condition Unknown, the node
+// stopped telling about them.
+func unknownStatus(status *models.NodeStatus, now time.Time) *models.NodeStatus {
+	unknown := &models.NodeStatus{LastHeartbeatTime: status.LastHeartbeatTime}
+	for _, condition := range status.Conditions {
+		if condition.Status != models.ConditionUnknown {
+			condition.Status = models.ConditionUnknown
+			condition.LastTransitionTime = now
+		}
+		condition.Reason = "NodeStatusUnknown"
+		condition.Message = "Node agent stopped posting node status."
+		unknown.Conditions = append(unknown.Conditions, condition)
+	}
+	return unknown
+}
+
+func (nm *NodeMonitor) monitor() error {
+	ctx := context.Background()
+
+	list, err := nm.client.Nodes().List(ctx, clientset.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, nodeInfo := range staleNodes(list.Items, nm.gracePeriod, now) {
+		err := nm.client.Nodes().UpdateStatus(ctx, nodeInfo.Name, unknownStatus(nodeInfo.Status, now))
+		if clientset.IsConflict(err) || clientset.IsNotFound(err) {
+			// A heartbeat came meanwhile, or the node is gone already.
+			continue
+		}
+		if err != nil {
+			logger.Error(nil, "NodeMonitor mark node [%s] NotReady error [%v]", nodeInfo.Name, err)
+			continue
+		}
+		logger.Info(nil, "NodeMonitor marked node [%s] NotReady, last heartbeat at %s", nodeInfo.Name, nodeInfo.Status.LastHeartbeatTime)
+	}
+	return nil
+}
+
+func (nm *NodeMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(nm.period)
+	defer ticker.Stop()
+
+	for {
+		if err := nm.monitor(); err != nil {
+			logger.Error(nil, "NodeMonitor monitor error [%v]", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestStaleNodes(t *testing.T) {
+	now := time.Now()
+	status := func(seconds int, ready string) *models.NodeStatus {
+		return &models.NodeStatus{
+			LastHeartbeatTime: now.Add(-time.Duration(seconds) * time.Second),
+			Conditions:        []models.NodeCondition{{Type: models.NodeReady, Status: ready}},
+		}
+	}
+	nodes := []models.NodeInfo{
+		{Name: "n-fresh", Status: status(10, models.ConditionTrue)},
+		{Name: "n-stale", Status: status(50, models.ConditionTrue)},
+		{Name: "n-unknown", Status: status(50, models.ConditionUnknown)},
+		{Name: "n-shutting-down", Status: status(50, models.ConditionFalse)},
+		// Agents which report no status.
+		{Name: "n-old"},
+	}
+
+	var names []string
+	for _, nodeInfo := range staleNodes(nodes, 40*time.Second, now) {
+		names = append(names, nodeInfo.Name)
+	}
+	assert.Equal(t, []string{"n-stale"}, names)
+
+	unknown := unknownStatus(nodes[1].Status, now)
+	assert.Equal(t, nodes[1].Status.LastHeartbeatTime, unknown.LastHeartbeatTime)
+	assert.Equal(t, models.ConditionUnknown, unknown.Condition(models.NodeReady).Status)
+	assert.Equal(t, now, unknown.Condition(models.NodeReady).LastTransitionTime)
+	// The status of the node is left as it was.
+	assert.Equal(t, models.ConditionTrue, nodes[1].Status.Condition(models.NodeReady).Status)
+}
@@ -0,0 +1,420 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// notificationHistoryLimit is the number of deliveries kept on a job or a
+// cron.
+const notificationHistoryLimit = 20
+
+// missedScheduleGrace is how late a tick may create its job before it is
+// missed.
+const missedScheduleGrace = time.Minute
+
+// The templates of the notifications which set none. The default webhook
+// body is understood by the Slack incoming webhooks.
+const (
+	defaultWebhookTemplate = `{"text": {{json .Message}}}`
+	defaultEmailSubject    = `[scheduler] {{.Message}}`
+	defaultEmailBody       = `{{.Message}}
+
+Namespace: {{.Namespace}}
+Owner: {{.Owner}}
+{{- if .Job}}
+Job: {{.Job}}
+Status: {{.Status}}
+Start time: {{.StartTime}}
+Complete time: {{.CompleteTime}}
+{{- end}}
+{{- if .ConsecutiveFailures}}
+Consecutive failures: {{.ConsecutiveFailures}}
+{{- end}}
+{{- if not .ScheduledTime.IsZero}}
+Scheduled time: {{.ScheduledTime}}
+{{- end}}
+`
+)
+
+// notificationEvent is what the templates of the notifications render.
+type notificationEvent struct {
+	On        string
+	Namespace string
+	// Owner is the cron of the job, or the cron which missed ScheduledTime.
+	Owner               string
+	Job                 string
+	Status              string
+	StartTime           time.Time
+	CompleteTime        time.Time
+	ConsecutiveFailures int
+	ScheduledTime       time.Time
+	Message             string
+}
+
+func jobNotificationEvent(on string, jobInfo models.JobInfo) notificationEvent {
+	event := notificationEvent{
+		On:                  on,
+		Namespace:           jobInfo.Namespace,
+		Owner:               jobInfo.Owner,
+		Job:                 jobInfo.Name,
+		Status:              jobInfo.Status,
+		StartTime:           jobInfo.StartTime,
+		CompleteTime:        jobInfo.CompleteTime,
+		ConsecutiveFailures: jobInfo.ConsecutiveFailures,
+	}
+	switch on {
+	case models.NotifyOnConsecutiveFailures:
+		event.Message = fmt.Sprintf("%s/%s failed %d times in a row, last job %s", jobInfo.Namespace, jobInfo.Owner, jobInfo.ConsecutiveFailures, jobInfo.Name)
+	default:
+		event.Message = fmt.Sprintf("Job %s/%s of %s %s", jobInfo.Namespace, jobInfo.Name, jobInfo.Owner, strings.ToLower(jobInfo.Status))
+	}
+	return event
+}
+
+// jobRules returns the rules of the job its outcome matches.
+func jobRules(jobInfo models.JobInfo) []models.NotificationRule {
+	var rules []models.NotificationRule
+	for _, rule := range jobInfo.Notifications {
+		var match bool
+		switch rule.On {
+		case models.NotifyOnFailure:
+			match = jobInfo.Status == "Failed"
+		case models.NotifyOnSuccess:
+			match = jobInfo.Status == "Completed"
+		case models.NotifyOnConsecutiveFailures:
+			// Sent once when the failures reach the threshold.
+			match = jobInfo.Status == "Failed" && jobInfo.ConsecutiveFailures == rule.Threshold
+		}
+		if match {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// consecutiveFailures counts the failed jobs of the owner of jobInfo in a
+// row up to it, from the one among jobs which finished last before it.
+func consecutiveFailures(jobInfo models.JobInfo, jobs []models.JobInfo) int {
+	if jobInfo.Status != "Failed" {
+		return 0
+	}
+
+	var previous *models.JobInfo
+	for i := range jobs {
+		other := &jobs[i]
+		if other.Name == jobInfo.Name || !finished(*other) || other.CompleteTime.After(jobInfo.CompleteTime) {
+			continue
+		}
+		if previous == nil || other.CompleteTime.After(previous.CompleteTime) {
+			previous = other
+		}
+	}
+	if previous == nil || previous.Status != "Failed" {
+		return 1
+	}
+	// The jobs which failed before the count was kept count once.
+	if previous.ConsecutiveFailures == 0 {
+		return 2
+	}
+	return previous.ConsecutiveFailures + 1
+}
+
+// missedSchedule returns the first tick of schedule after the last
+// scheduled job of the cron started, which is missed when it is more than
+// missedScheduleGrace before now.
+func missedSchedule(schedule cron.Schedule, jobs []models.JobInfo, now time.Time) (time.Time, bool) {
+	var last time.Time
+	for _, jobInfo := range jobs {
+		if jobInfo.Trigger != constants.TriggerManual && jobInfo.StartTime.After(last) {
+			last = jobInfo.StartTime
+		}
+	}
+	if last.IsZero() {
+		return time.Time{}, false
+	}
+	next := schedule.Next(last)
+	return next, next.Add(missedScheduleGrace).Before(now)
+}
+
+func hasRule(rules []models.NotificationRule, on string) bool {
+	for _, rule := range rules {
+		if rule.On == on {
+			return true
+		}
+	}
+	return false
+}
+
+// appendDeliveries appends deliveries to history, keeping the
+// notificationHistoryLimit last ones.
+func appendDeliveries(history []models.NotificationDelivery, deliveries []models.NotificationDelivery) []models.NotificationDelivery {
+	history = append(history, deliveries...)
+	if len(history) > notificationHistoryLimit {
+		history = history[len(history)-notificationHistoryLimit:]
+	}
+	return history
+}
+
+func renderTemplate(text string, event notificationEvent) (string, error) {
+	tmpl, err := template.New("notification").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, event); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Notifier sends the notifications of the jobs and the crons in the
+// background, retrying with an exponential backoff, and hands their
+// deliveries to the callers to record.
+type Notifier struct {
+	httpClient *http.Client
+	attempts   int
+	backoff    time.Duration
+
+	smtpAddr     string
+	smtpFrom     string
+	smtpUsername string
+	smtpPassword string
+	// sendMail is smtp.SendMail, replaced by the tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	// stop ends the backoffs of the notifications being sent on shutdown.
+	stop     chan struct{}
+	stopOnce sync.Once
+	sending  sync.WaitGroup
+}
+
+func NewNotifier(cfg *config.Config) *Notifier {
+	attempts := cfg.Notification.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &Notifier{
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		attempts:     attempts,
+		backoff:      cfg.Notification.Backoff,
+		smtpAddr:     cfg.Notification.SMTPAddr,
+		smtpFrom:     cfg.Notification.SMTPFrom,
+		smtpUsername: cfg.Notification.SMTPUsername,
+		smtpPassword: cfg.Notification.SMTPPassword,
+		sendMail:     smtp.SendMail,
+		stop:         make(chan struct{}),
+	}
+}
+
+// JobFinished sends the notifications the outcome of the finished job
+// matches, record receives their deliveries.
+func (n *Notifier) JobFinished(jobInfo models.JobInfo, record func([]models.NotificationDelivery)) {
+	for _, rule := range jobRules(jobInfo) {
+		n.send(rule, jobNotificationEvent(rule.On, jobInfo), record)
+	}
+}
+
+// MissedSchedule sends the MissedSchedule notifications of the cron, which
+// did not create its job at scheduled.
+func (n *Notifier) MissedSchedule(cronInfo models.CronInfo, scheduled time.Time, record func([]models.NotificationDelivery)) {
+	event := notificationEvent{
+		On:            models.NotifyOnMissedSchedule,
+		Namespace:     cronInfo.Namespace,
+		Owner:         cronInfo.Name,
+		ScheduledTime: scheduled,
+		Message:       fmt.Sprintf("Cron %s/%s missed its run of %s", cronInfo.Namespace, cronInfo.Name, scheduled.Format(time.RFC3339)),
+	}
+	for _, rule := range cronInfo.Notifications {
+		if rule.On == models.NotifyOnMissedSchedule {
+			n.send(rule, event, record)
+		}
+	}
+}
+
+// Stop gives up the retries and waits for the notifications being sent.
+func (n *Notifier) Stop() {
+	n.stopOnce.Do(func() { close(n.stop) })
+	n.sending.Wait()
+}
+
+func (n *Notifier) send(rule models.NotificationRule, event notificationEvent, record func([]models.NotificationDelivery)) {
+	n.sending.Add(1)
+	go func() {
+		defer n.sending.Done()
+
+		var deliveries []models.NotificationDelivery
+		if rule.Webhook != nil {
+			deliveries = append(deliveries, n.deliver(rule.On, webhookTarget(rule.Webhook.URL), func() (bool, error) {
+				return n.postWebhook(rule.Webhook, event)
+			}))
+		}
+		if rule.Email != nil {
+			deliveries = append(deliveries, n.deliver(rule.On, "email "+strings.Join(rule.Email.To, ","), func() (bool, error) {
+				return n.sendEmail(rule.Email, event)
+			}))
+		}
+		if len(deliveries) > 0 {
+			record(deliveries)
+		}
+	}()
+}
+
+// webhookTarget names the webhook in the deliveries without its path, which
+// often holds a secret.
+func webhookTarget(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "webhook"
+	}
+	return "webhook " + u.Scheme + "://" + u.Host
+}
+
+// deliver calls send until it succeeds, fails for good or the attempts are
+// exhausted, the backoff doubling after every retry.
+func (n *Notifier) deliver(on string, target string, send func() (bool, error)) models.NotificationDelivery {
+	delivery := models.NotificationDelivery{On: on, Target: target}
+	backoff := n.backoff
+	for {
+		delivery.Attempts++
+		delivery.Time = time.Now()
+		retry, err := send()
+		if err == nil {
+			delivery.Error = ""
+			notificationsTotal.WithLabelValues(on, "Delivered").Inc()
+			return delivery
+		}
+		delivery.Error = err.Error()
+		if !retry || delivery.Attempts >= n.attempts {
+			break
+		}
+
+		logger.Info(nil, "Notifier %s to [%s] attempt %d error [%v]", on, target, delivery.Attempts, err)
+		select {
+		case <-n.stop:
+			delivery.Error += ", controller stopped"
+			notificationsTotal.WithLabelValues(on, "Failed").Inc()
+			return delivery
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	logger.Error(nil, "Notifier %s to [%s] failed after %d attempts [%s]", on, target, delivery.Attempts, delivery.Error)
+	notificationsTotal.WithLabelValues(on, "Failed").Inc()
+	return delivery
+}
+
+// postWebhook posts the rendered body, it tells if a failure may be
+// retried: the network errors, 429 and the server errors.
+func (n *Notifier) postWebhook(webhook *models.WebhookTarget, event notificationEvent) (bool, error) {
+	text := webhook.Template
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	body, err := renderTemplate(text, event)
+	if err != nil {
+		return false, err
+	}
+	if !json.Valid([]byte(body)) {
+		return false, errors.New("webhook template did not render JSON")
+	}
+
+	request, err := http.NewRequest("POST", webhook.URL, strings.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range webhook.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := n.httpClient.Do(request)
+	if err != nil {
+		// The URL may hold a secret.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode <= 299 {
+		return false, nil
+	}
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %d", response.StatusCode)
+}
+
+// sendEmail mails the rendered notification, the permanent SMTP errors are
+// not retried.
+func (n *Notifier) sendEmail(email *models.EmailTarget, event notificationEvent) (bool, error) {
+	if n.smtpAddr == "" {
+		return false, errors.New("no SMTP server configured")
+	}
+
+	subjectText, bodyText := email.Subject, email.Body
+	if subjectText == "" {
+		subjectText = defaultEmailSubject
+	}
+	if bodyText == "" {
+		bodyText = defaultEmailBody
+	}
+	subject, err := renderTemplate(subjectText, event)
+	if err != nil {
+		return false, err
+	}
+	body, err := renderTemplate(bodyText, event)
+	if err != nil {
+		return false, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.smtpFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(email.To, ", "))
+	// A subject spanning lines would add headers.
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(subject))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+
+	var auth smtp.Auth
+	if n.smtpUsername != "" {
+		host, _, _ := net.SplitHostPort(n.smtpAddr)
+		auth = smtp.PlainAuth("", n.smtpUsername, n.smtpPassword, host)
+	}
+	err = n.sendMail(n.smtpAddr, auth, n.smtpFrom, email.To, msg.Bytes())
+	if protoErr, ok := err.(*textproto.Error); ok && protoErr.Code >= 500 {
+		return false, err
+	}
+	return err != nil, err
+}
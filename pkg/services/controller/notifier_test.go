@@ -0,0 +1,233 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestJobRules(t *testing.T) {
+	rules := []models.NotificationRule{
+		{On: models.NotifyOnFailure},
+		{On: models.NotifyOnSuccess},
+		{On: models.NotifyOnConsecutiveFailures, Threshold: 3},
+	}
+	ons := func(jobInfo models.JobInfo) []string {
+		jobInfo.Notifications = rules
+		var ons []string
+		for _, rule := range jobRules(jobInfo) {
+			ons = append(ons, rule.On)
+		}
+		return ons
+	}
+
+	assert.Equal(t, []string{"Success"}, ons(models.JobInfo{Status: "Completed"}))
+	assert.Equal(t, []string{"Failure"}, ons(models.JobInfo{Status: "Failed", ConsecutiveFailures: 2}))
+	assert.Equal(t, []string{"Failure", "ConsecutiveFailures"}, ons(models.JobInfo{Status: "Failed", ConsecutiveFailures: 3}))
+	// Sent once when the failures reach the threshold.
+	assert.Equal(t, []string{"Failure"}, ons(models.JobInfo{Status: "Failed", ConsecutiveFailures: 4}))
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	now := time.Now()
+	ago := func(minutes int) time.Time {
+		return now.Add(-time.Duration(minutes) * time.Minute)
+	}
+	jobs := []models.JobInfo{
+		{Name: "j-1", Status: "Failed", CompleteTime: ago(30), ConsecutiveFailures: 1},
+		{Name: "j-2", Status: "Failed", CompleteTime: ago(20), ConsecutiveFailures: 2},
+		{Name: "j-3", Status: "Running", StartTime: ago(5)},
+	}
+
+	jobInfo := models.JobInfo{Name: "j-4", Status: "Failed", CompleteTime: now}
+	assert.Equal(t, 3, consecutiveFailures(jobInfo, jobs))
+	assert.Equal(t, 1, consecutiveFailures(jobInfo, nil))
+
+	jobInfo.Status = "Completed"
+	assert.Equal(t, 0, consecutiveFailures(jobInfo, jobs))
+
+	// A completed job ends the failures.
+	jobInfo.Status = "Failed"
+	jobs = append(jobs, models.JobInfo{Name: "j-5", Status: "Completed", CompleteTime: ago(10)})
+	assert.Equal(t, 1, consecutiveFailures(jobInfo, jobs))
+
+	// A failure before the count was kept counts once.
+	jobs = []models.JobInfo{{Name: "j-6", Status: "Failed", CompleteTime: ago(10)}}
+	assert.Equal(t, 2, consecutiveFailures(jobInfo, jobs))
+}
+
+func TestMissedSchedule(t *testing.T) {
+	schedule, err := cron.ParseStandard("0 * * * *")
+	assert.NoError(t, err)
+	last := time.Date(2019, 6, 1, 10, 0, 5, 0, time.UTC)
+	jobs := []models.JobInfo{
+		{Name: "j-1", StartTime: last.Add(-time.Hour)},
+		{Name: "j-2", StartTime: last},
+		// Manual runs are not on schedule.
+		{Name: "j-3", StartTime: last.Add(30 * time.Minute), Trigger: constants.TriggerManual},
+	}
+
+	scheduled, missed := missedSchedule(schedule, jobs, last.Add(time.Hour))
+	assert.False(t, missed)
+	assert.Equal(t, time.Date(2019, 6, 1, 11, 0, 0, 0, time.UTC), scheduled)
+
+	_, missed = missedSchedule(schedule, jobs, last.Add(time.Hour+2*time.Minute))
+	assert.True(t, missed)
+
+	_, missed = missedSchedule(schedule, nil, last.Add(24*time.Hour))
+	assert.False(t, missed)
+}
+
+func testNotifier(attempts int) *Notifier {
+	cfg := &config.Config{}
+	cfg.Notification.Attempts = attempts
+	cfg.Notification.Backoff = time.Millisecond
+	cfg.Notification.SMTPAddr = "localhost:25"
+	cfg.Notification.SMTPFrom = "scheduler@example.com"
+	return NewNotifier(cfg)
+}
+
+// deliveries collects the deliveries recorded by a notifier.
+type deliveries struct {
+	sync.Mutex
+	items []models.NotificationDelivery
+}
+
+func (d *deliveries) record(items []models.NotificationDelivery) {
+	d.Lock()
+	defer d.Unlock()
+	d.items = append(d.items, items...)
+}
+
+func TestWebhook(t *testing.T) {
+	var mutex sync.Mutex
+	var requests int
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(data, &body))
+	}))
+	defer server.Close()
+
+	notifier := testNotifier(3)
+	var recorded deliveries
+	jobInfo := models.JobInfo{
+		Namespace: "default",
+		Name:      "j-1",
+		Owner:     "nightly",
+		Status:    "Failed",
+		Notifications: []models.NotificationRule{{
+			On:      models.NotifyOnFailure,
+			Webhook: &models.WebhookTarget{URL: server.URL + "/hooks/secret", Headers: map[string]string{"Authorization": "Bearer token"}},
+		}},
+	}
+	notifier.JobFinished(jobInfo, recorded.record)
+	notifier.sending.Wait()
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, map[string]string{"text": "Job default/j-1 of nightly failed"}, body)
+	assert.Len(t, recorded.items, 1)
+	delivery := recorded.items[0]
+	assert.Equal(t, "Failure", delivery.On)
+	assert.Equal(t, "webhook "+server.URL, delivery.Target)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Empty(t, delivery.Error)
+}
+
+func TestWebhookNotRetried(t *testing.T) {
+	var mutex sync.Mutex
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	notifier := testNotifier(3)
+	var recorded deliveries
+	jobInfo := models.JobInfo{
+		Status: "Completed",
+		Notifications: []models.NotificationRule{
+			{On: models.NotifyOnSuccess, Webhook: &models.WebhookTarget{URL: server.URL}},
+			{On: models.NotifyOnSuccess, Webhook: &models.WebhookTarget{URL: server.URL, Template: `{"text": {{.Message}}}`}},
+		},
+	}
+	notifier.JobFinished(jobInfo, recorded.record)
+	notifier.sending.Wait()
+
+	assert.Equal(t, 1, requests)
+	assert.Len(t, recorded.items, 2)
+	errors := []string{recorded.items[0].Error, recorded.items[1].Error}
+	assert.Contains(t, errors, "webhook returned 404")
+	assert.Contains(t, errors, "webhook template did not render JSON")
+}
+
+func TestEmail(t *testing.T) {
+	notifier := testNotifier(3)
+	var sent []string
+	var msg string
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, data []byte) error {
+		assert.Equal(t, "localhost:25", addr)
+		assert.Nil(t, a)
+		assert.Equal(t, "scheduler@example.com", from)
+		sent = to
+		msg = string(data)
+		return nil
+	}
+
+	var recorded deliveries
+	scheduled := time.Date(2019, 6, 1, 2, 0, 0, 0, time.UTC)
+	cronInfo := models.CronInfo{
+		Namespace: "default",
+		Name:      "nightly",
+		Notifications: []models.NotificationRule{
+			{On: models.NotifyOnMissedSchedule, Email: &models.EmailTarget{To: []string{"oncall@example.com"}, Subject: "Missed {{.Owner}}"}},
+			{On: models.NotifyOnFailure, Email: &models.EmailTarget{To: []string{"dev@example.com"}}},
+		},
+	}
+	notifier.MissedSchedule(cronInfo, scheduled, recorded.record)
+	notifier.sending.Wait()
+
+	assert.Equal(t, []string{"oncall@example.com"}, sent)
+	assert.True(t, strings.HasPrefix(msg, "From: scheduler@example.com\r\nTo: oncall@example.com\r\nSubject: Missed nightly\r\n"), msg)
+	assert.Contains(t, msg, "Cron default/nightly missed its run of 2019-06-01T02:00:00Z\r\n")
+	assert.Len(t, recorded.items, 1)
+	assert.Equal(t, "email oncall@example.com", recorded.items[0].Target)
+}
+
+func TestAppendDeliveries(t *testing.T) {
+	var history []models.NotificationDelivery
+	for i := 0; i < notificationHistoryLimit+5; i++ {
+		history = appendDeliveries(history, []models.NotificationDelivery{{Attempts: i}})
+	}
+	assert.Len(t, history, notificationHistoryLimit)
+	assert.Equal(t, 5, history[0].Attempts)
+}
@@ -8,28 +8,32 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/client/informer"
-	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
 type TaskWatcher struct {
+	client       clientset.Interface
+	Namespace    string
 	Owner        string
 	taskChan     chan models.TaskInfo
 	taskInformer *informer.Informer
 }
 
-func NewTaskWatcher(owner string) *TaskWatcher {
+func NewTaskWatcher(client clientset.Interface, namespace string, owner string) *TaskWatcher {
 	tw := &TaskWatcher{
-		Owner:    owner,
-		taskChan: make(chan models.TaskInfo, 100),
+		client:    client,
+		Namespace: namespace,
+		Owner:     owner,
+		taskChan:  make(chan models.TaskInfo, 100),
 	}
 
 	return tw
 }
 
-func (tw *TaskWatcher) notifyTask(value []byte) {
+func (tw *TaskWatcher) notifyTask(value []byte, deleted bool) {
 	taskInfo := models.TaskInfo{}
 
 	err := json.Unmarshal(value, &taskInfo)
@@ -37,15 +41,15 @@ func (tw *TaskWatcher) notifyTask(value []byte) {
 		logger.Error(nil, "Unmarshal TaskInfo error: %v", err)
 		return
 	}
+	if deleted {
+		taskInfo.Status = "Deleted"
+	}
 
 	tw.taskChan <- taskInfo
 }
 
 func (tw *TaskWatcher) watchTasks() {
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1/tasks/?watch=true&filter=Owner=%s", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort, tw.Owner)
-	tw.taskInformer = informer.NewInformer(url)
+	tw.taskInformer = tw.client.Tasks(tw.Namespace).Informer(fmt.Sprintf("Owner=%s", tw.Owner), 0)
 
 	tw.taskInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -53,7 +57,7 @@ func (tw *TaskWatcher) watchTasks() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				tw.notifyTask(info.Value)
+				tw.notifyTask(info.Value, false)
 			} else {
 				logger.Error(nil, "watchTasks data error")
 			}
@@ -63,7 +67,7 @@ func (tw *TaskWatcher) watchTasks() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				tw.notifyTask(info.Value)
+				tw.notifyTask(info.Value, true)
 			} else {
 				logger.Error(nil, "watchTasks data error")
 			}
@@ -71,9 +75,9 @@ func (tw *TaskWatcher) watchTasks() {
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			logger.Debug(nil, "watchTasks updated task: %v", newObj)
 
-			info, ok := (oldObj).(models.Info)
+			info, ok := (newObj).(models.Info)
 			if ok {
-				tw.notifyTask(info.Value)
+				tw.notifyTask(info.Value, false)
 			} else {
 				logger.Error(nil, "watchTasks data error")
 			}
@@ -0,0 +1,103 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/client/informer"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// triggerRetryPeriod is how often a trigger whose cron is not running yet is
+// handed to the controller again.
+const triggerRetryPeriod = 10 * time.Second
+
+type TriggerWatcher struct {
+	client      clientset.Interface
+	informer    *informer.Informer
+	triggerChan chan models.TriggerEvent
+}
+
+func NewTriggerWatcher(client clientset.Interface) *TriggerWatcher {
+	tw := &TriggerWatcher{
+		client:      client,
+		informer:    client.Triggers("").Informer("", triggerRetryPeriod),
+		triggerChan: make(chan models.TriggerEvent, 100),
+	}
+
+	return tw
+}
+
+func (tw *TriggerWatcher) scheduleTrigger(event string, key string, value []byte) {
+	triggerInfo := models.TriggerInfo{}
+
+	if event == "DELETE" {
+		triggerInfo.Namespace, triggerInfo.Name = models.SplitKey(key)
+	} else {
+		err := json.Unmarshal(value, &triggerInfo)
+		if err != nil {
+			logger.Error(nil, "Unmarshal TriggerInfo error: %v", err)
+			return
+		}
+	}
+
+	tw.triggerChan <- models.TriggerEvent{
+		Event:       event,
+		TriggerInfo: triggerInfo,
+	}
+}
+
+func (tw *TriggerWatcher) watchTriggers() {
+	triggerInformer := tw.informer
+
+	triggerInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			logger.Info(nil, "watchTriggers added trigger: %v", obj)
+
+			info, ok := (obj).(models.Info)
+			if ok {
+				tw.scheduleTrigger("ADD", info.Key, info.Value)
+			} else {
+				logger.Error(nil, "watchTriggers data error")
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			info, ok := (obj).(models.Info)
+			if ok {
+				tw.scheduleTrigger("DELETE", info.Key, nil)
+			} else {
+				logger.Error(nil, "watchTriggers data error")
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			info, ok := (newObj).(models.Info)
+			if ok {
+				tw.scheduleTrigger("MODIFY", info.Key, info.Value)
+			} else {
+				logger.Error(nil, "watchTriggers data error")
+			}
+		},
+	})
+
+	triggerInformer.Start()
+}
+
+func (tw *TriggerWatcher) Run() {
+	tw.watchTriggers()
+}
+
+// Stop stops watching the triggers.
+func (tw *TriggerWatcher) Stop() {
+	tw.informer.Stop()
+}
+
+// HasSynced tells if the first list of the triggers has been handled.
+func (tw *TriggerWatcher) HasSynced() bool {
+	return tw.informer.HasSynced()
+}
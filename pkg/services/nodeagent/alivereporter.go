@@ -5,22 +5,43 @@
 package nodeagent
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"openpitrix.io/scheduler/pkg/client/writer"
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
+// nodeTTL is how long the node entry lives after a heartbeat.
+const nodeTTL = 60
+
+// heartbeatPeriod is how often the node reports alive, and retries to
+// register while another agent holds the node ID.
+const heartbeatPeriod = 20 * time.Second
+
 type AliveReporter struct {
 	nodeAgent *NodeAgent
+	// instance tells this agent from another one started with the same
+	// node ID, the apiserver rejects the second while the first is alive.
+	// It is kept across restarts, see loadInstance.
+	instance string
+	// lastBeat is the unix nano time of the last accepted heartbeat.
+	lastBeat int64
+	// shuttingDown is set once the agent shuts down, the node is then
+	// reported not ready.
+	shuttingDown int32
 }
 
-func NewAliveReporter() *AliveReporter {
-	ar := &AliveReporter{}
+func NewAliveReporter(instance string) *AliveReporter {
+	ar := &AliveReporter{
+		instance: instance,
+	}
 
 	return ar
 }
@@ -29,34 +50,114 @@ func (ar *AliveReporter) SetNodeAgent(nodeAgent *NodeAgent) {
 	ar.nodeAgent = nodeAgent
 }
 
-func (ar *AliveReporter) doHeartBeat() {
-	nodeInfo := models.APIInfo{
-		Info: "",
-		TTL:  60,
+// parseLabels parses labels written as "key=value,key=value".
+func parseLabels(value string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			logger.Error(nil, "Invalid node label [%s]", pair)
+			continue
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels
+}
+
+func (ar *AliveReporter) doHeartBeat() error {
+	nodeInfo := nodeFacts(ar.nodeAgent.HostName, ar.nodeAgent.executorNames())
+	nodeInfo.Name = ar.nodeAgent.NodeID
+	nodeInfo.Labels = parseLabels(config.GetInstance().NodeAgent.Labels)
+	nodeInfo.Pool = config.GetInstance().NodeAgent.Pool
+	nodeInfo.Instance = ar.instance
+	nodeInfo.Status = &models.NodeStatus{Conditions: nodeConditions(atomic.LoadInt32(&ar.shuttingDown) != 0)}
+
+	err := ar.nodeAgent.client.Nodes().Create(context.Background(), &nodeInfo, nodeTTL)
+	if err != nil {
+		logger.Error(nil, "doHeartBeat [%s] error [%v]", ar.nodeAgent.NodeID, err)
+		return err
 	}
+	lastHeartbeat.SetToCurrentTime()
+	atomic.StoreInt64(&ar.lastBeat, time.Now().UnixNano())
 
-	value, err := json.Marshal(nodeInfo)
+	// The node entry carries the drain requested by the operator.
+	node, err := ar.nodeAgent.client.Nodes().Get(context.Background(), ar.nodeAgent.NodeID)
 	if err != nil {
-		logger.Error(nil, "doHeartBeat marshal node info error [%v]", err)
-		return
+		logger.Error(nil, "doHeartBeat get node [%s] error [%v]", ar.nodeAgent.NodeID, err)
+		return nil
 	}
+	ar.nodeAgent.setNodeDrain(node.Drain)
+	return nil
+}
+
+// register reports the node alive for the first time. While another live
+// agent holds the node ID it retries, so that the two never take the same
+// tasks. It returns false when ctx is done first.
+func (ar *AliveReporter) register(ctx context.Context) bool {
+	for {
+		err := ar.doHeartBeat()
+		if err == nil {
+			logger.Info(nil, "Node [%s] registered as host [%s]", ar.nodeAgent.NodeID, ar.nodeAgent.HostName)
+			return true
+		}
+		if clientset.IsConflict(err) {
+			logger.Critical(nil, "Node [%s] is registered by another live agent, set a distinct node ID", ar.nodeAgent.NodeID)
+		}
 
-	cfg := config.GetInstance()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(heartbeatPeriod):
+		}
+	}
+}
 
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	writer.WriteAPIServer(url, "nodes", ar.nodeAgent.HostName, string(value))
+// checkHeartbeat fails until a heartbeat is accepted and once the node entry
+// may have expired.
+func (ar *AliveReporter) checkHeartbeat() error {
+	last := atomic.LoadInt64(&ar.lastBeat)
+	if last == 0 {
+		return errors.New("no heartbeat accepted yet")
+	}
+	if age := time.Since(time.Unix(0, last)); age > nodeTTL*time.Second {
+		return fmt.Errorf("last heartbeat accepted %s ago", age.Round(time.Second))
+	}
+	return nil
 }
 
-func (ar *AliveReporter) HeartBeat() {
+// markNotReady reports the node not ready at once, so that no task is
+// scheduled to it while the agent drains it.
+func (ar *AliveReporter) markNotReady() {
+	atomic.StoreInt32(&ar.shuttingDown, 1)
 	ar.doHeartBeat()
+}
 
-	timer := time.NewTicker(time.Second * 20)
+// HeartBeat reports the node alive until ctx is done.
+func (ar *AliveReporter) HeartBeat(ctx context.Context) {
+	timer := time.NewTicker(heartbeatPeriod)
 	defer timer.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-timer.C:
 			ar.doHeartBeat()
 		}
 	}
 }
+
+// deregister deletes the node entry, so that no task is scheduled to the
+// node anymore.
+func (ar *AliveReporter) deregister() {
+	err := ar.nodeAgent.client.Nodes().Delete(context.Background(), ar.nodeAgent.NodeID)
+	if err != nil {
+		logger.Error(nil, "deregister [%s] error [%v]", ar.nodeAgent.NodeID, err)
+		return
+	}
+	logger.Info(nil, "Node [%s] deregistered", ar.nodeAgent.NodeID)
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/artifact"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+var errNoArtifactStore = errors.New("node has no artifact store")
+
+// workPath resolves the path of an artifact in the working directory, which
+// it may not leave.
+func workPath(workDir string, path string) (string, error) {
+	cleaned := filepath.Clean(path)
+	if filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("artifact path [%s] is not in the working directory", path)
+	}
+	return filepath.Join(workDir, cleaned), nil
+}
+
+// checkArtifactName refuses the names which are not a single element of a
+// key.
+func checkArtifactName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid artifact name [%s]", name)
+	}
+	return nil
+}
+
+// outputFile resolves the output artifact the task left at path, which has
+// to be a regular file of the working directory: a symlink may not make the
+// agent upload a file of the host.
+func outputFile(workDir string, path string) (string, error) {
+	filePath, err := workPath(workDir, path)
+	if err != nil {
+		return "", err
+	}
+	realDir, err := filepath.EvalSymlinks(workDir)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(realPath, realDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("artifact path [%s] is not in the working directory", path)
+	}
+	if info, err := os.Lstat(realPath); err != nil {
+		return "", err
+	} else if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("[%s] is not a file", path)
+	}
+	return realPath, nil
+}
+
+// artifactJob is the job whose artifacts the task uploads.
+func artifactJob(taskInfo models.TaskInfo) string {
+	if taskInfo.Owner != "" {
+		return taskInfo.Owner
+	}
+	return taskInfo.Name
+}
+
+// fetchInputs creates the working directory of the task and downloads its
+// input artifacts there. They are read from the key of the job in the
+// namespace of the task, not from the key recorded on the job, which its
+// creator may have set.
+func (na *NodeAgent) fetchInputs(ctx context.Context, taskInfo models.TaskInfo, record *taskRecord) error {
+	if err := os.MkdirAll(record.WorkDir, 0755); err != nil {
+		return err
+	}
+	if len(taskInfo.Inputs) == 0 {
+		return nil
+	}
+	if na.artifacts == nil {
+		return errNoArtifactStore
+	}
+
+	for _, input := range taskInfo.Inputs {
+		path, err := workPath(record.WorkDir, input.Path)
+		if err != nil {
+			return err
+		}
+		if err := checkArtifactName(input.Name); err != nil {
+			return err
+		}
+		if err := checkArtifactName(input.Job); err != nil {
+			return fmt.Errorf("input artifact [%s]: invalid job [%s]", input.Name, input.Job)
+		}
+		job, err := na.client.Jobs(taskInfo.Namespace).Get(ctx, input.Job)
+		if err != nil {
+			return fmt.Errorf("input artifact [%s] of job [%s]: %v", input.Name, input.Job, err)
+		}
+
+		found := false
+		for _, info := range job.Artifacts {
+			if info.Name == input.Name {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("job [%s] has no artifact [%s]", input.Job, input.Name)
+		}
+
+		key := artifact.Key(taskInfo.Namespace, input.Job, input.Name)
+		if err := na.artifacts.Get(ctx, key, path); err != nil {
+			return fmt.Errorf("download artifact [%s] of job [%s]: %v", input.Name, input.Job, err)
+		}
+		logger.Info(nil, "fetchInputs task [%s] downloaded [%s] to [%s]", taskInfo.Name, key, input.Path)
+	}
+	return nil
+}
+
+// uploadOutputs uploads the output artifacts the task left in its working
+// directory. The ones which could not be uploaded are reported in the error,
+// the others are returned.
+func (na *NodeAgent) uploadOutputs(ctx context.Context, taskInfo models.TaskInfo, record *taskRecord) ([]models.ArtifactInfo, error) {
+	if na.artifacts == nil {
+		return nil, errNoArtifactStore
+	}
+
+	var artifacts []models.ArtifactInfo
+	var failures []string
+	for _, output := range taskInfo.Outputs {
+		info, err := na.uploadOutput(ctx, taskInfo, record, output)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("artifact [%s]: %v", output.Name, err))
+			continue
+		}
+		artifacts = append(artifacts, *info)
+	}
+	if len(failures) > 0 {
+		return artifacts, errors.New(strings.Join(failures, ", "))
+	}
+	return artifacts, nil
+}
+
+func (na *NodeAgent) uploadOutput(ctx context.Context, taskInfo models.TaskInfo, record *taskRecord, output models.Artifact) (*models.ArtifactInfo, error) {
+	if err := checkArtifactName(output.Name); err != nil {
+		return nil, err
+	}
+	path, err := outputFile(record.WorkDir, output.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	size, digest, err := artifact.Digest(path)
+	if err != nil {
+		return nil, err
+	}
+	key := artifact.Key(taskInfo.Namespace, artifactJob(taskInfo), output.Name)
+	if err := na.artifacts.Put(ctx, key, path); err != nil {
+		return nil, err
+	}
+	logger.Info(nil, "uploadOutput task [%s] uploaded [%s] as [%s]", taskInfo.Name, output.Path, key)
+	return &models.ArtifactInfo{Name: output.Name, Key: key, Size: size, SHA256: digest, UploadTime: time.Now()}, nil
+}
+
+// collectOutputs records the output artifacts on the finished task. A task
+// which succeeded fails when one of them could not be uploaded, the failed
+// ones may not have left them.
+func (na *NodeAgent) collectOutputs(taskInfo *models.TaskInfo, record *taskRecord) {
+	if len(taskInfo.Outputs) == 0 || record.WorkDir == "" {
+		return
+	}
+
+	artifacts, err := na.uploadOutputs(context.Background(), *taskInfo, record)
+	taskInfo.Artifacts = artifacts
+	if err != nil {
+		logger.Error(nil, "collectOutputs task [%s] error [%v]", taskInfo.Name, err)
+		taskInfo.Output += "upload artifacts: " + err.Error() + "\n"
+		if taskInfo.ExitCode == 0 && taskInfo.Reason == "" {
+			taskInfo.Reason = models.TaskReasonArtifactFailed
+		}
+	}
+}
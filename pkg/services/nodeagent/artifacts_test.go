@@ -0,0 +1,107 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/artifact"
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestWorkPath(t *testing.T) {
+	path, err := workPath("/work", "out/report.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "/work/out/report.csv", path)
+
+	for _, path := range []string{"/etc/passwd", "..", "../x", "out/../../x", "."} {
+		_, err := workPath("/work", path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	workDir := filepath.Join(dir, "work")
+	assert.NoError(t, os.MkdirAll(filepath.Join(workDir, "out"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "out", "report.csv"), []byte("a,b\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shadow"), []byte("root\n"), 0600))
+
+	path, err := outputFile(workDir, "out/report.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "report.csv", filepath.Base(path))
+
+	// The task may not point its outputs at the files of the host.
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "shadow"), filepath.Join(workDir, "out", "shadow")))
+	assert.NoError(t, os.Symlink(dir, filepath.Join(workDir, "host")))
+	assert.NoError(t, os.Symlink("report.csv", filepath.Join(workDir, "out", "link.csv")))
+	for _, path := range []string{"out/shadow", "host/shadow", "out", "out/missing"} {
+		_, err := outputFile(workDir, path)
+		assert.Error(t, err, path)
+	}
+
+	// A link staying in the working directory is followed.
+	path, err = outputFile(workDir, "out/link.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "report.csv", filepath.Base(path))
+}
+
+func TestCollectOutputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Artifact.Store = filepath.Join(dir, "store")
+	store, err := artifact.NewStore(cfg)
+	assert.NoError(t, err)
+	na := &NodeAgent{artifacts: store}
+
+	journal, err := newTaskJournal(filepath.Join(dir, "tasks"))
+	assert.NoError(t, err)
+	taskInfo := models.TaskInfo{
+		Namespace: "default",
+		Name:      "t-1",
+		Owner:     "j-1",
+		Outputs:   []models.Artifact{{Name: "report", Path: "out/report.csv"}},
+	}
+	record := journal.newRecord(taskInfo)
+	assert.Equal(t, filepath.Join(dir, "tasks", "default_t-1.work"), record.WorkDir)
+	assert.NoError(t, na.fetchInputs(context.Background(), taskInfo, record))
+
+	// A task which did not leave its output fails.
+	na.collectOutputs(&taskInfo, record)
+	assert.Equal(t, models.TaskReasonArtifactFailed, taskInfo.Reason)
+	assert.Empty(t, taskInfo.Artifacts)
+
+	taskInfo.Reason = ""
+	taskInfo.Output = ""
+	assert.NoError(t, os.MkdirAll(filepath.Join(record.WorkDir, "out"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(record.WorkDir, "out", "report.csv"), []byte("a,b\n"), 0644))
+	na.collectOutputs(&taskInfo, record)
+	assert.Empty(t, taskInfo.Reason)
+	assert.Len(t, taskInfo.Artifacts, 1)
+	info := taskInfo.Artifacts[0]
+	assert.Equal(t, "report", info.Name)
+	assert.Equal(t, "default/j-1/report", info.Key)
+	assert.Equal(t, int64(4), info.Size)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "store", "default", "j-1", "report"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b\n", string(data))
+
+	journal.remove(record)
+	_, err = os.Stat(record.WorkDir)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// cgroup2SuperMagic is the file system type of a cgroup v2 hierarchy.
+const cgroup2SuperMagic = 0x63677270
+
+// cpuMaxPeriod is the period of cpu.max in microseconds.
+const cpuMaxPeriod = 100000
+
+// cgroupControllers are the controllers the groups of the tasks use.
+const cgroupControllers = "+cpu +memory +pids"
+
+// cgroupManager creates a cgroup v2 group under parent for every task run on
+// the host, which limits its processes and accounts what they used.
+type cgroupManager struct {
+	parent string
+}
+
+// newCgroupManager creates parent and enables the controllers for its
+// groups, parent must be in a cgroup v2 hierarchy.
+func newCgroupManager(parent string) (*cgroupManager, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(filepath.Dir(parent), &stat); err != nil {
+		return nil, err
+	}
+	if int64(stat.Type) != cgroup2SuperMagic {
+		return nil, fmt.Errorf("[%s] is not in a cgroup v2 hierarchy", parent)
+	}
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+	// The controllers of a group are those its parent enables.
+	for _, dir := range []string{filepath.Dir(parent), parent} {
+		if err := writeCgroupFile(dir, "cgroup.subtree_control", cgroupControllers); err != nil {
+			return nil, err
+		}
+	}
+	return &cgroupManager{parent: parent}, nil
+}
+
+// writeCgroupFile writes an interface file of the group, which the kernel
+// creates with the group.
+func writeCgroupFile(dir string, file string, value string) error {
+	f, err := os.OpenFile(filepath.Join(dir, file), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// cpuMax is the value of cpu.max allowing cpus CPUs.
+func cpuMax(cpus float64) string {
+	return fmt.Sprintf("%d %d", int64(cpus*cpuMaxPeriod), cpuMaxPeriod)
+}
+
+// create creates the group of the task of record and applies limits.
+func (m *cgroupManager) create(record *taskRecord, limits *models.ResourceLimits) (string, error) {
+	dir := filepath.Join(m.parent, record.Namespace+"_"+record.Name)
+	// A group left by an agent which stopped while starting the task is
+	// reused.
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	if limits == nil {
+		return dir, nil
+	}
+
+	var err error
+	if limits.CPUs > 0 {
+		err = writeCgroupFile(dir, "cpu.max", cpuMax(limits.CPUs))
+	}
+	if err == nil && limits.MemoryBytes > 0 {
+		err = writeCgroupFile(dir, "memory.max", strconv.FormatInt(limits.MemoryBytes, 10))
+	}
+	if err == nil && limits.Pids > 0 {
+		err = writeCgroupFile(dir, "pids.max", strconv.FormatInt(limits.Pids, 10))
+	}
+	if err != nil {
+		removeCgroup(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// readCgroupKey reads the value of key in a flat keyed file of the group,
+// such as cpu.stat.
+func readCgroupKey(dir string, file string, key string) (int64, bool) {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			value, err := strconv.ParseInt(fields[1], 10, 64)
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
+
+// cgroupUsage returns what the processes of the group used and whether the
+// kernel killed one of them for exceeding memory.max.
+func cgroupUsage(dir string) (*models.TaskUsage, bool) {
+	usage := &models.TaskUsage{}
+	// memory.peak is missing before Linux 5.19.
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "memory.peak")); err == nil {
+		usage.PeakMemoryBytes, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if usec, ok := readCgroupKey(dir, "cpu.stat", "usage_usec"); ok {
+		usage.CPUSeconds = float64(usec) / 1e6
+	}
+	oomKills, _ := readCgroupKey(dir, "memory.events", "oom_kill")
+	return usage, oomKills > 0
+}
+
+// removeCgroup kills the processes left in the group and removes it.
+func removeCgroup(dir string) {
+	// cgroup.kill is missing before Linux 5.14, the process group of the
+	// task was killed anyway.
+	writeCgroupFile(dir, "cgroup.kill", "1")
+
+	var err error
+	for retry := 0; retry < 10; retry++ {
+		if err = os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		// The killed processes leave the group asynchronously.
+		time.Sleep(100 * time.Millisecond)
+	}
+	logger.Error(nil, "removeCgroup [%s] error: %v", dir, err)
+}
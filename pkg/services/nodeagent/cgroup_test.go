@@ -0,0 +1,98 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestCpuMax(t *testing.T) {
+	assert.Equal(t, "50000 100000", cpuMax(0.5))
+	assert.Equal(t, "200000 100000", cpuMax(2))
+}
+
+// fakeCgroup creates a directory holding the interface files of a group.
+func fakeCgroup(t *testing.T, dir string, files map[string]string) {
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	for file, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	}
+}
+
+func TestCgroupCreate(t *testing.T) {
+	parent, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(parent)
+
+	dir := filepath.Join(parent, "default_t-1")
+	fakeCgroup(t, dir, map[string]string{"cpu.max": "max 100000", "memory.max": "max", "pids.max": "max"})
+
+	m := &cgroupManager{parent: parent}
+	record := &taskRecord{Namespace: "default", Name: "t-1"}
+	path, err := m.create(record, &models.ResourceLimits{CPUs: 1.5, MemoryBytes: 1 << 20})
+	assert.NoError(t, err)
+	assert.Equal(t, dir, path)
+
+	for file, expected := range map[string]string{"cpu.max": "150000 100000", "memory.max": "1048576", "pids.max": "max"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(data), file)
+	}
+
+	// The kernel refuses the files it does not know.
+	_, err = m.create(&taskRecord{Namespace: "default", Name: "t-2"}, &models.ResourceLimits{Pids: 10})
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(parent, "default_t-2"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCgroupUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fakeCgroup(t, dir, map[string]string{
+		"memory.peak":   "73400320\n",
+		"cpu.stat":      "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n",
+		"memory.events": "low 0\nhigh 0\nmax 12\noom 1\noom_kill 1\n",
+	})
+	usage, oomKilled := cgroupUsage(dir)
+	assert.Equal(t, &models.TaskUsage{PeakMemoryBytes: 73400320, CPUSeconds: 2.5}, usage)
+	assert.True(t, oomKilled)
+
+	fakeCgroup(t, dir, map[string]string{"memory.events": "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n"})
+	_, oomKilled = cgroupUsage(dir)
+	assert.False(t, oomKilled)
+}
+
+func TestCgroupScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	exitCodePath := filepath.Join(dir, "t-1.exit")
+	cmd := exec.Command("/bin/sh", "-c", cgroupScript, exitCodePath, dir, "sh", "-c", "exit 4")
+	err = cmd.Run()
+	assert.Error(t, err)
+
+	// The shell moved itself to the group before running the command.
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(cmd.Process.Pid), strings.TrimSpace(string(data)))
+
+	exitCode, ok := readExitCode(exitCodePath)
+	assert.True(t, ok)
+	assert.Equal(t, 4, exitCode)
+}
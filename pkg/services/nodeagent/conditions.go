@@ -0,0 +1,98 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+const (
+	memInfoFile = "/proc/meminfo"
+	// diskPressurePath is the file system the tasks write to.
+	diskPressurePath = "/"
+	// The node is under pressure when less than this part of its memory
+	// or disk is available.
+	pressureRatio = 0.1
+)
+
+// parseMemInfo returns MemTotal and MemAvailable of /proc/meminfo in kB.
+func parseMemInfo(data []byte) (uint64, uint64, error) {
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = value
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok || total == 0 {
+		return 0, 0, errors.New("no MemTotal in meminfo")
+	}
+	available, ok := values["MemAvailable"]
+	if !ok {
+		return 0, 0, errors.New("no MemAvailable in meminfo")
+	}
+	return total, available, nil
+}
+
+// pressureCondition tells if less than pressureRatio of total is available.
+func pressureCondition(conditionType string, resource string, total, available uint64, err error) models.NodeCondition {
+	condition := models.NodeCondition{Type: conditionType, Status: models.ConditionFalse}
+	switch {
+	case err != nil:
+		condition.Status = models.ConditionUnknown
+		condition.Message = err.Error()
+	case float64(available) < float64(total)*pressureRatio:
+		condition.Status = models.ConditionTrue
+		condition.Reason = "Insufficient" + resource
+		condition.Message = fmt.Sprintf("%d of %d kB available", available, total)
+	}
+	return condition
+}
+
+func memoryCondition() models.NodeCondition {
+	var total, available uint64
+	data, err := ioutil.ReadFile(memInfoFile)
+	if err == nil {
+		total, available, err = parseMemInfo(data)
+	}
+	return pressureCondition(models.NodeMemoryPressure, "Memory", total, available, err)
+}
+
+func diskCondition() models.NodeCondition {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(diskPressurePath, &stat)
+	total := stat.Blocks * uint64(stat.Bsize) / 1024
+	available := stat.Bavail * uint64(stat.Bsize) / 1024
+	return pressureCondition(models.NodeDiskPressure, "Disk", total, available, err)
+}
+
+// nodeConditions returns the conditions of the node, it is not ready once
+// the agent shuts down.
+func nodeConditions(shuttingDown bool) []models.NodeCondition {
+	ready := models.NodeCondition{Type: models.NodeReady, Status: models.ConditionTrue, Reason: "AgentReady"}
+	if shuttingDown {
+		ready.Status = models.ConditionFalse
+		ready.Reason = "AgentShuttingDown"
+		ready.Message = "Node agent is draining the node before it deregisters."
+	}
+	return []models.NodeCondition{ready, memoryCondition(), diskCondition()}
+}
@@ -0,0 +1,27 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestParseMemInfo(t *testing.T) {
+	total, available, err := parseMemInfo([]byte("MemTotal:        8000000 kB\nMemFree:          300000 kB\nMemAvailable:     500000 kB\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8000000), total)
+	assert.Equal(t, uint64(500000), available)
+
+	_, _, err = parseMemInfo([]byte("MemTotal:        8000000 kB\n"))
+	assert.Error(t, err)
+
+	assert.Equal(t, models.ConditionTrue, pressureCondition(models.NodeMemoryPressure, "Memory", total, available, nil).Status)
+	assert.Equal(t, models.ConditionFalse, pressureCondition(models.NodeMemoryPressure, "Memory", total, 4000000, nil).Status)
+	assert.Equal(t, models.ConditionUnknown, pressureCondition(models.NodeMemoryPressure, "Memory", 0, 0, err).Status)
+}
@@ -0,0 +1,346 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// dockerAPIVersion is the first Docker Engine API limiting the CPUs of a
+// container.
+const dockerAPIVersion = "/v1.25"
+
+// The labels of the containers of the tasks.
+const (
+	dockerLabelTask = "io.openpitrix.scheduler.task"
+	dockerLabelNode = "io.openpitrix.scheduler.node"
+)
+
+// dockerExecutor runs the tasks in containers through the Docker Engine API.
+// The containers outlive the agent, they are removed once their task ended
+// and its output was read.
+type dockerExecutor struct {
+	client *http.Client
+	url    string
+	nodeID string
+}
+
+// newDockerExecutor talks to the daemon at host, eg.
+// unix:///var/run/docker.sock or tcp://127.0.0.1:2375.
+func newDockerExecutor(host string, nodeID string) (*dockerExecutor, error) {
+	e := &dockerExecutor{nodeID: nodeID}
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		path := strings.TrimPrefix(host, "unix://")
+		e.client = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		}}
+		e.url = "http://docker"
+	case strings.HasPrefix(host, "tcp://"):
+		e.client = &http.Client{}
+		e.url = "http://" + strings.TrimPrefix(host, "tcp://")
+	default:
+		return nil, fmt.Errorf("unsupported docker host [%s]", host)
+	}
+	return e, nil
+}
+
+type dockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *dockerError) Error() string {
+	return fmt.Sprintf("docker returned %d: %s", e.StatusCode, e.Message)
+}
+
+func isDockerNotFound(err error) bool {
+	dockerErr, ok := err.(*dockerError)
+	return ok && dockerErr.StatusCode == http.StatusNotFound
+}
+
+// do sends a request to the daemon, the answer is decoded into out or
+// copied to it when it is a writer.
+func (e *dockerExecutor) do(ctx context.Context, method string, path string, query url.Values, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	u := e.url + dockerAPIVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	request, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		data, _ := ioutil.ReadAll(response.Body)
+		message := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(data, &message) != nil || message.Message == "" {
+			message.Message = strings.TrimSpace(string(data))
+		}
+		return &dockerError{StatusCode: response.StatusCode, Message: message.Message}
+	}
+
+	switch out := out.(type) {
+	case nil:
+		_, err = io.Copy(ioutil.Discard, response.Body)
+	case io.Writer:
+		_, err = io.Copy(out, response.Body)
+	default:
+		err = json.NewDecoder(response.Body).Decode(out)
+	}
+	return err
+}
+
+func (e *dockerExecutor) ping(ctx context.Context) error {
+	return e.do(ctx, "GET", "/_ping", nil, nil, nil)
+}
+
+// containerName names the container of the task, so that it is found even
+// when the agent stopped before it recorded the container ID.
+func containerName(record *taskRecord) string {
+	return "scheduler-" + record.Namespace + "-" + record.Name
+}
+
+type dockerHostConfig struct {
+	Binds    []string `json:"Binds,omitempty"`
+	Memory   int64    `json:"Memory,omitempty"`
+	NanoCpus int64    `json:"NanoCpus,omitempty"`
+}
+
+type dockerContainerConfig struct {
+	Image      string            `json:"Image"`
+	Cmd        []string          `json:"Cmd,omitempty"`
+	WorkingDir string            `json:"WorkingDir,omitempty"`
+	Labels     map[string]string `json:"Labels"`
+	HostConfig dockerHostConfig  `json:"HostConfig"`
+}
+
+// containerConfig describes the container of the task, the working
+// directory of a task passing artifacts is mounted at the same path.
+func (e *dockerExecutor) containerConfig(taskInfo models.TaskInfo, record *taskRecord) *dockerContainerConfig {
+	spec := taskInfo.Container
+	config := &dockerContainerConfig{
+		Image: spec.Image,
+		Cmd:   taskInfo.Cmd,
+		Labels: map[string]string{
+			dockerLabelTask: taskInfo.Namespace + "/" + taskInfo.Name,
+			dockerLabelNode: e.nodeID,
+		},
+		HostConfig: dockerHostConfig{
+			Memory:   spec.MemoryBytes,
+			NanoCpus: int64(spec.CPUs * 1e9),
+		},
+	}
+	for _, mount := range spec.Mounts {
+		bind := mount.Source + ":" + mount.Target
+		if mount.ReadOnly {
+			bind += ":ro"
+		}
+		config.HostConfig.Binds = append(config.HostConfig.Binds, bind)
+	}
+	if record.WorkDir != "" {
+		config.WorkingDir = record.WorkDir
+		config.HostConfig.Binds = append(config.HostConfig.Binds, record.WorkDir+":"+record.WorkDir)
+	}
+	return config
+}
+
+// imageTag splits an image reference, the tag defaults to latest.
+func imageTag(image string) (string, string) {
+	if strings.Contains(image, "@") {
+		return image, ""
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+// pull pulls the image, the daemon reports the errors within the progress
+// it streams.
+func (e *dockerExecutor) pull(ctx context.Context, image string) error {
+	logger.Info(nil, "Pull image [%s]", image)
+
+	name, tag := imageTag(image)
+	query := url.Values{"fromImage": {name}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	var progress bytes.Buffer
+	if err := e.do(ctx, "POST", "/images/create", query, nil, &progress); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(&progress)
+	for {
+		message := struct {
+			Error string `json:"error"`
+		}{}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return fmt.Errorf("pull image [%s]: %s", image, message.Error)
+		}
+	}
+}
+
+func (e *dockerExecutor) create(ctx context.Context, record *taskRecord, config *dockerContainerConfig) (string, error) {
+	created := struct {
+		Id string `json:"Id"`
+	}{}
+	err := e.do(ctx, "POST", "/containers/create", url.Values{"name": {containerName(record)}}, config, &created)
+	return created.Id, err
+}
+
+func (e *dockerExecutor) Start(taskInfo models.TaskInfo, record *taskRecord) error {
+	ctx := context.Background()
+	config := e.containerConfig(taskInfo, record)
+
+	id, err := e.create(ctx, record, config)
+	if isDockerNotFound(err) {
+		// The image is pulled on first use.
+		if err := e.pull(ctx, config.Image); err != nil {
+			return err
+		}
+		id, err = e.create(ctx, record, config)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := e.do(ctx, "POST", "/containers/"+id+"/start", nil, nil, nil); err != nil {
+		e.remove(id)
+		return err
+	}
+	record.ContainerID = id
+	return nil
+}
+
+func (e *dockerExecutor) Wait(ctx context.Context, record *taskRecord) (taskResult, bool) {
+	id := record.ContainerID
+
+	waitCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type waitResult struct {
+		StatusCode int `json:"StatusCode"`
+		err        error
+	}
+	done := make(chan waitResult, 1)
+	go func() {
+		var result waitResult
+		result.err = e.do(waitCtx, "POST", "/containers/"+id+"/wait", nil, nil, &result)
+		done <- result
+	}()
+
+	var result waitResult
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		logger.Info(nil, "Wait task [%s] killed", record.Name)
+		cancel()
+		e.remove(id)
+		return taskResult{}, false
+	}
+
+	if isDockerNotFound(result.err) {
+		return taskResult{ExitCode: -1, Output: "task container was removed while the node agent was down\n"}, true
+	}
+	if result.err != nil {
+		logger.Error(nil, "Wait task [%s] error: %v", record.Name, result.err)
+		e.remove(id)
+		return taskResult{ExitCode: -1, Output: result.err.Error() + "\n"}, true
+	}
+
+	output := e.logs(id)
+	e.remove(id)
+	return taskResult{ExitCode: result.StatusCode, Output: output}, true
+}
+
+// logs returns the tail of the output of the container.
+func (e *dockerExecutor) logs(id string) string {
+	var stream bytes.Buffer
+	err := e.do(context.Background(), "GET", "/containers/"+id+"/logs", url.Values{"stdout": {"1"}, "stderr": {"1"}}, nil, &stream)
+	if err != nil {
+		logger.Error(nil, "logs of container [%s] error: %v", id, err)
+		return ""
+	}
+
+	output := newTailBuffer(outputTailSize)
+	if err := demuxLogs(&stream, output); err != nil {
+		logger.Error(nil, "logs of container [%s] error: %v", id, err)
+	}
+	return output.String()
+}
+
+// demuxLogs copies the stdout and stderr frames the daemon multiplexes the
+// logs of a container without a TTY into: an 8 bytes header, whose last 4
+// bytes are the big endian size of the frame, then the frame.
+func demuxLogs(r io.Reader, w io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := binary.BigEndian.Uint32(header[4:])
+		if _, err := io.CopyN(w, r, int64(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// remove kills and removes the container.
+func (e *dockerExecutor) remove(ref string) {
+	err := e.do(context.Background(), "DELETE", "/containers/"+ref, url.Values{"force": {"1"}}, nil, nil)
+	if err != nil && !isDockerNotFound(err) {
+		logger.Error(nil, "remove container [%s] error: %v", ref, err)
+	}
+}
+
+func (e *dockerExecutor) Kill(record *taskRecord) {
+	ref := record.ContainerID
+	if ref == "" {
+		ref = containerName(record)
+	}
+	e.remove(ref)
+}
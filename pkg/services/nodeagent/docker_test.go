@@ -0,0 +1,154 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// fakeRuntime answers the Docker Engine API calls of the executor, its
+// image is missing until it is pulled.
+type fakeRuntime struct {
+	sync.Mutex
+	pulled  bool
+	created *dockerContainerConfig
+	removed bool
+}
+
+func logFrame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func (f *fakeRuntime) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, dockerAPIVersion)
+	switch {
+	case path == "/_ping":
+		w.Write([]byte("OK"))
+	case path == "/images/create":
+		f.pulled = true
+		w.Write([]byte(`{"status":"Pulling from library/busybox"}` + "\n" + `{"status":"Downloaded newer image"}` + "\n"))
+	case path == "/containers/create":
+		if !f.pulled {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such image: busybox:latest"}`))
+			return
+		}
+		f.created = &dockerContainerConfig{}
+		json.NewDecoder(r.Body).Decode(f.created)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"c1"}`))
+	case path == "/containers/c1/start":
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/containers/c1/wait":
+		w.Write([]byte(`{"StatusCode":3}`))
+	case path == "/containers/c1/logs":
+		w.Write(logFrame(1, "hello\n"))
+		w.Write(logFrame(2, "failed\n"))
+	case path == "/containers/c1" && r.Method == "DELETE":
+		f.removed = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"page not found"}`))
+	}
+}
+
+func TestDockerExecutor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	runtime := &fakeRuntime{}
+	server := httptest.NewUnstartedServer(runtime)
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	executor, err := newDockerExecutor("unix://"+socket, "n-1")
+	assert.NoError(t, err)
+	assert.NoError(t, executor.ping(context.Background()))
+
+	taskInfo := models.TaskInfo{
+		Namespace: "default",
+		Name:      "t-1",
+		Cmd:       []string{"sh", "-c", "exit 3"},
+		Container: &models.ContainerSpec{
+			Image:       "busybox",
+			Mounts:      []models.Mount{{Source: "/data", Target: "/data", ReadOnly: true}},
+			CPUs:        0.5,
+			MemoryBytes: 64 << 20,
+		},
+	}
+	record := &taskRecord{Namespace: taskInfo.Namespace, Name: taskInfo.Name, Executor: taskInfo.Executor()}
+	assert.Equal(t, models.ExecutorDocker, record.Executor)
+
+	assert.NoError(t, executor.Start(taskInfo, record))
+	assert.Equal(t, "c1", record.ContainerID)
+	assert.True(t, record.started())
+	assert.True(t, runtime.pulled)
+	assert.Equal(t, "busybox", runtime.created.Image)
+	assert.Equal(t, taskInfo.Cmd, runtime.created.Cmd)
+	assert.Equal(t, "default/t-1", runtime.created.Labels[dockerLabelTask])
+	assert.Equal(t, []string{"/data:/data:ro"}, runtime.created.HostConfig.Binds)
+	assert.Equal(t, int64(64<<20), runtime.created.HostConfig.Memory)
+	assert.Equal(t, int64(5e8), runtime.created.HostConfig.NanoCpus)
+
+	result, finished := executor.Wait(context.Background(), record)
+	assert.True(t, finished)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, "hello\nfailed\n", result.Output)
+	assert.True(t, runtime.removed)
+}
+
+func TestImageTag(t *testing.T) {
+	for image, expected := range map[string][2]string{
+		"busybox":                      {"busybox", "latest"},
+		"busybox:1.31":                 {"busybox", "1.31"},
+		"registry:5000/team/app":       {"registry:5000/team/app", "latest"},
+		"registry:5000/team/app:v2":    {"registry:5000/team/app", "v2"},
+		"busybox@sha256:0123456789abc": {"busybox@sha256:0123456789abc", ""},
+	} {
+		name, tag := imageTag(image)
+		assert.Equal(t, expected, [2]string{name, tag}, image)
+	}
+}
+
+func TestDemuxLogs(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(logFrame(1, "out\n"))
+	stream.Write(logFrame(2, "err\n"))
+
+	var output bytes.Buffer
+	assert.NoError(t, demuxLogs(&stream, &output))
+	assert.Equal(t, "out\nerr\n", output.String())
+
+	// A frame cut short is reported.
+	assert.Error(t, demuxLogs(bytes.NewReader(logFrame(1, "out\n")[:10]), &output))
+}
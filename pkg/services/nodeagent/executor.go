@@ -0,0 +1,232 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// Executor runs the commands of the tasks. What finds a task again is kept
+// in its record, so that a restarted agent waits for the tasks the previous
+// one started.
+type Executor interface {
+	// Start starts the command of the task and sets on record what finds
+	// it again.
+	Start(taskInfo models.TaskInfo, record *taskRecord) error
+	// Wait waits for the task of record to end and returns how it ended.
+	// When ctx is done first the task is killed and Wait returns false.
+	Wait(ctx context.Context, record *taskRecord) (taskResult, bool)
+	// Kill kills the task of record if it still runs.
+	Kill(record *taskRecord)
+}
+
+// taskResult is how a task ended.
+type taskResult struct {
+	ExitCode int
+	// Output is the tail of the output of the task.
+	Output string
+	// Reason tells why the task failed besides its exit code.
+	Reason string
+	Usage  *models.TaskUsage
+}
+
+// exitCodeScript runs the command given after the exit code file, it writes
+// the exit code there so that an agent restarted meanwhile still learns it.
+const exitCodeScript = `"$@"; code=$?; echo $code > "$0"; exit $code`
+
+// cgroupScript moves itself to the group given after the exit code file
+// before running exitCodeScript, so that the command starts in the group.
+const cgroupScript = `echo $$ > "$1/cgroup.procs" || { echo 125 > "$0"; exit 125; }; shift; ` + exitCodeScript
+
+// processExecutor runs the commands on the host, each in its own process
+// group writing to the output file of its record, and in its own cgroup
+// when the node has cgroup v2.
+type processExecutor struct {
+	sync.Mutex
+	// cmds holds the processes started by this agent, the other ones are
+	// polled.
+	cmds map[int]*exec.Cmd
+	// cgroups is nil when the tasks are not put in groups.
+	cgroups *cgroupManager
+}
+
+func newProcessExecutor(cgroups *cgroupManager) *processExecutor {
+	return &processExecutor{cmds: make(map[int]*exec.Cmd), cgroups: cgroups}
+}
+
+func (e *processExecutor) Start(taskInfo models.TaskInfo, record *taskRecord) error {
+	app := taskInfo.Cmd[0]
+	if _, err := exec.LookPath(app); err != nil {
+		return err
+	}
+
+	output, err := os.OpenFile(record.OutputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	args := []string{"-c", exitCodeScript, record.ExitCodePath}
+	if e.cgroups != nil {
+		dir, err := e.cgroups.create(record, taskInfo.Resources)
+		if err != nil {
+			return err
+		}
+		record.Cgroup = dir
+		args = []string{"-c", cgroupScript, record.ExitCodePath, dir}
+	} else if taskInfo.Resources != nil {
+		logger.Warn(nil, "Start task [%s] is not limited, the node has no cgroup v2", taskInfo.Name)
+	}
+
+	cmd := exec.Command("/bin/sh", append(append(args, app), taskInfo.Cmd[1:]...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Dir = record.WorkDir
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		if record.Cgroup != "" {
+			removeCgroup(record.Cgroup)
+			record.Cgroup = ""
+		}
+		return err
+	}
+	record.Pid = cmd.Process.Pid
+
+	e.Lock()
+	e.cmds[record.Pid] = cmd
+	e.Unlock()
+	return nil
+}
+
+func (e *processExecutor) Wait(ctx context.Context, record *taskRecord) (taskResult, bool) {
+	result, finished := e.wait(ctx, record)
+	if record.Cgroup != "" {
+		if finished {
+			var oomKilled bool
+			result.Usage, oomKilled = cgroupUsage(record.Cgroup)
+			if oomKilled {
+				result.Reason = models.TaskReasonOOMKilled
+			}
+		}
+		removeCgroup(record.Cgroup)
+	}
+	return result, finished
+}
+
+func (e *processExecutor) wait(ctx context.Context, record *taskRecord) (taskResult, bool) {
+	e.Lock()
+	cmd, ok := e.cmds[record.Pid]
+	delete(e.cmds, record.Pid)
+	e.Unlock()
+
+	if !ok {
+		return e.waitAdopted(ctx, record)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-waitErr:
+	case <-ctx.Done():
+		logger.Info(nil, "Wait task [%s] killed", record.Name)
+		syscall.Kill(-record.Pid, syscall.SIGKILL)
+		<-waitErr
+		return taskResult{}, false
+	}
+
+	if err != nil {
+		logger.Error(nil, "Wait task [%s] error: %v", record.Name, err)
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Exited() {
+				return taskResult{ExitCode: status.ExitStatus(), Output: readOutputTail(record.OutputPath)}, true
+			}
+		}
+		return taskResult{ExitCode: -1, Output: readOutputTail(record.OutputPath) + err.Error() + "\n"}, true
+	}
+	return taskResult{Output: readOutputTail(record.OutputPath)}, true
+}
+
+// waitAdopted polls the process of a task started by a previous agent,
+// which is not a child of this one.
+func (e *processExecutor) waitAdopted(ctx context.Context, record *taskRecord) (taskResult, bool) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for processGroupAlive(record.Pid) {
+		select {
+		case <-ctx.Done():
+			logger.Info(nil, "waitAdopted task [%s] killed", record.Name)
+			syscall.Kill(-record.Pid, syscall.SIGKILL)
+			return taskResult{}, false
+		case <-ticker.C:
+		}
+	}
+
+	output := readOutputTail(record.OutputPath)
+	exitCode, ok := readExitCode(record.ExitCodePath)
+	if !ok {
+		return taskResult{ExitCode: -1, Output: output + "task process was killed while the node agent was down\n"}, true
+	}
+	return taskResult{ExitCode: exitCode, Output: output}, true
+}
+
+func (e *processExecutor) Kill(record *taskRecord) {
+	if record.Pid != 0 && processGroupAlive(record.Pid) {
+		syscall.Kill(-record.Pid, syscall.SIGKILL)
+	}
+	if record.Cgroup != "" {
+		removeCgroup(record.Cgroup)
+	}
+}
+
+// processGroupAlive tells if a process of the group still runs.
+func processGroupAlive(pgid int) bool {
+	err := syscall.Kill(-pgid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// readExitCode reads the exit code the task wrote when it ended.
+func readExitCode(path string) (int, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// readOutputTail returns the last outputTailSize bytes of the output file.
+func readOutputTail(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > outputTailSize {
+		file.Seek(info.Size()-outputTailSize, io.SeekStart)
+	}
+	output := newTailBuffer(outputTailSize)
+	io.Copy(output, file)
+	return output.String()
+}
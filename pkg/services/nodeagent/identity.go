@@ -0,0 +1,110 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/constants"
+	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/models"
+	"openpitrix.io/scheduler/pkg/util/idutil"
+	"openpitrix.io/scheduler/pkg/version"
+)
+
+// kernelReleaseFile holds the release of the running Linux kernel.
+const kernelReleaseFile = "/proc/sys/kernel/osrelease"
+
+// loadNodeID returns the ID of the node, the tasks of the node are bound to
+// it. Unless overridden it is generated once and kept in idFile, so that it
+// survives restarts and does not depend on the host name.
+func loadNodeID(override string, idFile string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	return loadID(idFile, idutil.GetUuid36(constants.NodeIdPrefix))
+}
+
+// instanceFile is where the instance of the agent is kept, next to the file of
+// the node ID.
+func instanceFile(idFile string) string {
+	return idFile + ".instance"
+}
+
+// loadInstance returns the instance the agent registers the node with. It is
+// generated once and kept next to idFile, so that a restarted agent renews
+// the registration of its previous run instead of waiting for it to expire.
+func loadInstance(idFile string) (string, error) {
+	return loadID(instanceFile(idFile), idutil.GetUuid(""))
+}
+
+// loadID returns the ID kept in file, or writes generated into it when there
+// is none yet.
+func loadID(file string, generated string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(file, []byte(generated+"\n"), 0644); err != nil {
+		return "", err
+	}
+	logger.Info(nil, "Generated ID [%s] into [%s]", generated, file)
+	return generated, nil
+}
+
+// nodeCapabilities returns what the node can run: the executors it runs the
+// tasks with, then the extra capabilities.
+func nodeCapabilities(executors []string, extra string) []string {
+	capabilities := append([]string{}, executors...)
+	seen := make(map[string]bool)
+	for _, executor := range executors {
+		seen[executor] = true
+	}
+	for _, capability := range strings.Split(extra, ",") {
+		capability = strings.TrimSpace(capability)
+		if capability == "" || seen[capability] {
+			continue
+		}
+		seen[capability] = true
+		capabilities = append(capabilities, capability)
+	}
+	return capabilities
+}
+
+// nodeFacts describes the host the agent runs on, reported in every
+// heartbeat.
+func nodeFacts(hostName string, executors []string) models.NodeInfo {
+	nodeInfo := models.NodeInfo{
+		Hostname:     hostName,
+		AgentVersion: version.Version,
+		OS:           runtime.GOOS + "/" + runtime.GOARCH,
+		Capabilities: nodeCapabilities(executors, config.GetInstance().NodeAgent.Capabilities),
+	}
+
+	ip, err := idutil.IPv4()
+	if err != nil {
+		logger.Error(nil, "nodeFacts get ip error [%v]", err)
+	} else {
+		nodeInfo.IPs = []string{ip.String()}
+	}
+
+	if data, err := ioutil.ReadFile(kernelReleaseFile); err == nil {
+		nodeInfo.Kernel = strings.TrimSpace(string(data))
+	}
+	return nodeInfo
+}
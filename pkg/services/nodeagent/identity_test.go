@@ -0,0 +1,60 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadNodeID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	idFile := filepath.Join(dir, "lib", "node-id")
+
+	id, err := loadNodeID("", idFile)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "n-"))
+
+	// The generated ID is kept across restarts.
+	again, err := loadNodeID("", idFile)
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	override, err := loadNodeID("node-1", idFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "node-1", override)
+}
+
+func TestLoadInstance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	idFile := filepath.Join(dir, "lib", "node-id")
+
+	instance, err := loadInstance(idFile)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, instance)
+
+	// A restarted agent renews the registration of its previous run.
+	again, err := loadInstance(idFile)
+	assert.NoError(t, err)
+	assert.Equal(t, instance, again)
+
+	_, err = os.Stat(idFile + ".instance")
+	assert.NoError(t, err)
+}
+
+func TestNodeCapabilities(t *testing.T) {
+	assert.Equal(t, []string{"exec"}, nodeCapabilities([]string{"exec"}, ""))
+	assert.Equal(t, []string{"exec", "gpu"}, nodeCapabilities([]string{"exec"}, "gpu, exec,gpu,"))
+	assert.Equal(t, []string{"exec", "docker", "gpu"}, nodeCapabilities([]string{"exec", "docker"}, "docker,gpu"))
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// taskRecord is what the agent keeps on disk about a task it started, so
+// that a restarted agent finds the task again.
+type taskRecord struct {
+	Namespace string `json:"Namespace"`
+	Name      string `json:"Name"`
+	// Executor runs the task, empty in the records of the process
+	// executor written before there were other ones.
+	Executor string `json:"Executor,omitempty"`
+	// Pid is the process group of the task, ContainerID its container,
+	// zero until it started.
+	Pid         int       `json:"Pid,omitempty"`
+	ContainerID string    `json:"ContainerID,omitempty"`
+	StartTime   time.Time `json:"StartTime"`
+	// The task writes its output to OutputPath and its exit code to
+	// ExitCodePath, which outlive the agent.
+	OutputPath   string `json:"OutputPath"`
+	ExitCodePath string `json:"ExitCodePath"`
+	// Cgroup is the group of the processes of the task, empty when it has
+	// none.
+	Cgroup string `json:"Cgroup,omitempty"`
+	// WorkDir is the working directory of a task passing artifacts, which
+	// holds its inputs and outputs.
+	WorkDir string `json:"WorkDir,omitempty"`
+}
+
+// taskJournal keeps a record of every task the agent started and did not
+// report finished yet, one JSON file per task.
+type taskJournal struct {
+	dir string
+}
+
+func newTaskJournal(dir string) (*taskJournal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &taskJournal{dir: dir}, nil
+}
+
+func (j *taskJournal) base(namespace, name string) string {
+	return filepath.Join(j.dir, namespace+"_"+name)
+}
+
+func (j *taskJournal) newRecord(taskInfo models.TaskInfo) *taskRecord {
+	base := j.base(taskInfo.Namespace, taskInfo.Name)
+	record := &taskRecord{
+		Namespace:    taskInfo.Namespace,
+		Name:         taskInfo.Name,
+		Executor:     taskInfo.Executor(),
+		StartTime:    time.Now(),
+		OutputPath:   base + ".out",
+		ExitCodePath: base + ".exit",
+	}
+	if len(taskInfo.Inputs) > 0 || len(taskInfo.Outputs) > 0 {
+		record.WorkDir = base + ".work"
+	}
+	return record
+}
+
+// started tells if the task may have been started.
+func (r *taskRecord) started() bool {
+	return r.Pid != 0 || r.ContainerID != ""
+}
+
+// has tells if the task was started already.
+func (j *taskJournal) has(taskInfo models.TaskInfo) bool {
+	_, err := os.Stat(j.base(taskInfo.Namespace, taskInfo.Name) + ".json")
+	return err == nil
+}
+
+// save writes the record atomically, a crash leaves the old one or the new
+// one.
+func (j *taskJournal) save(record *taskRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	path := j.base(record.Namespace, record.Name) + ".json"
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// remove forgets the task together with its output and working directory.
+func (j *taskJournal) remove(record *taskRecord) {
+	os.Remove(j.base(record.Namespace, record.Name) + ".json")
+	os.Remove(record.OutputPath)
+	os.Remove(record.ExitCodePath)
+	if record.WorkDir != "" {
+		os.RemoveAll(record.WorkDir)
+	}
+}
+
+func (j *taskJournal) list() ([]*taskRecord, error) {
+	paths, err := filepath.Glob(filepath.Join(j.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*taskRecord
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		record := &taskRecord{}
+		if err := json.Unmarshal(data, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
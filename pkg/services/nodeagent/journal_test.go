@@ -0,0 +1,82 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+func TestTaskJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := newTaskJournal(dir)
+	assert.NoError(t, err)
+
+	taskInfo := models.TaskInfo{Namespace: "default", Name: "t-1"}
+	assert.False(t, journal.has(taskInfo))
+
+	record := journal.newRecord(taskInfo)
+	assert.NoError(t, journal.save(record))
+	record.Pid = 42
+	assert.NoError(t, journal.save(record))
+	assert.True(t, journal.has(taskInfo))
+
+	records, err := journal.list()
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 42, records[0].Pid)
+	assert.Equal(t, record.OutputPath, records[0].OutputPath)
+	assert.True(t, record.StartTime.Equal(records[0].StartTime))
+
+	journal.remove(record)
+	assert.False(t, journal.has(taskInfo))
+	records, err = journal.list()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestExitCodeScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := newTaskJournal(dir)
+	assert.NoError(t, err)
+	record := journal.newRecord(models.TaskInfo{Namespace: "default", Name: "t-1"})
+
+	_, ok := readExitCode(record.ExitCodePath)
+	assert.False(t, ok)
+
+	output, err := os.Create(record.OutputPath)
+	assert.NoError(t, err)
+	cmd := exec.Command("/bin/sh", "-c", exitCodeScript, record.ExitCodePath, "sh", "-c", "echo hello; exit 3")
+	cmd.Stdout = output
+	assert.Error(t, cmd.Run())
+	output.Close()
+	assert.Equal(t, 3, cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus())
+
+	exitCode, ok := readExitCode(record.ExitCodePath)
+	assert.True(t, ok)
+	assert.Equal(t, 3, exitCode)
+	assert.Equal(t, "hello\n", readOutputTail(record.OutputPath))
+
+	ioutil.WriteFile(record.OutputPath, []byte(strings.Repeat("a", outputTailSize)+"end"), 0644)
+	tail := readOutputTail(record.OutputPath)
+	assert.Len(t, tail, outputTailSize)
+	assert.True(t, strings.HasSuffix(tail, "end"))
+
+	assert.True(t, processGroupAlive(syscall.Getpgrp()))
+}
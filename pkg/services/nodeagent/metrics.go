@@ -0,0 +1,41 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"openpitrix.io/scheduler/pkg/metrics"
+)
+
+const subsystem = "nodeagent"
+
+var (
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "task_duration_seconds",
+		Help:      "Run time of the tasks by final status.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"status"})
+
+	runningTasks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "running_tasks",
+		Help:      "Tasks running on the node.",
+	})
+
+	lastHeartbeat = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "last_heartbeat_timestamp_seconds",
+		Help:      "Unix time of the last heartbeat accepted by the apiserver.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(taskDuration, runningTasks, lastHeartbeat)
+}
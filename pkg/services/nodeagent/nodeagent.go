@@ -5,36 +5,123 @@
 package nodeagent
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"syscall"
+	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
-	"openpitrix.io/scheduler/pkg/client/writer"
+	"openpitrix.io/scheduler/pkg/admin"
+	"openpitrix.io/scheduler/pkg/artifact"
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
 type NodeAgent struct {
+	client clientset.Interface
+	// NodeID names the node, HostName is only reported.
+	NodeID        string
 	HostName      string
 	aliveReporter *AliveReporter
 	taskWatcher   *TaskWatcher
+	journal       *taskJournal
+	// executors run the tasks, by name.
+	executors map[string]Executor
+	// artifacts is nil when no artifact store is configured.
+	artifacts artifact.Store
+	// tasks counts the running tasks, waited for on shutdown.
+	tasks sync.WaitGroup
+
+	// killCtx is cancelled once a drain timed out, the tasks still running
+	// are then killed and handed back. killRunning renews it.
+	killLock sync.Mutex
+	killCtx  context.Context
+	kill     context.CancelFunc
+
+	// nodeDrain is set while the operator drains the node.
+	drainLock sync.Mutex
+	nodeDrain *models.NodeDrain
 }
 
 func NewNodeAgent() *NodeAgent {
+	cfg := config.GetInstance()
+
+	nodeID, err := loadNodeID(cfg.NodeAgent.NodeID, cfg.NodeAgent.NodeIDFile)
+	if err != nil {
+		logger.Critical(nil, "NewNodeAgent load node ID error [%v]", err)
+		panic(err)
+	}
+
+	instance, err := loadInstance(cfg.NodeAgent.NodeIDFile)
+	if err != nil {
+		logger.Critical(nil, "NewNodeAgent load instance error [%v]", err)
+		panic(err)
+	}
+
+	journal, err := newTaskJournal(filepath.Join(cfg.NodeAgent.StateDir, "tasks"))
+	if err != nil {
+		logger.Critical(nil, "NewNodeAgent open task journal error [%v]", err)
+		panic(err)
+	}
+
 	host, err := os.Hostname()
 	if err != nil {
 		logger.Error(nil, "NewNodeAgent get host name error: %s", err)
 	}
 
+	// An empty parent leaves the tasks in the cgroup of the agent.
+	var cgroups *cgroupManager
+	if cfg.NodeAgent.CgroupParent != "" {
+		cgroups, err = newCgroupManager(cfg.NodeAgent.CgroupParent)
+		if err != nil {
+			logger.Warn(nil, "NewNodeAgent cgroup [%s] unavailable, the tasks are not limited [%v]", cfg.NodeAgent.CgroupParent, err)
+		}
+	}
+
+	executors := map[string]Executor{models.ExecutorProcess: newProcessExecutor(cgroups)}
+	if cfg.NodeAgent.DockerHost != "" {
+		docker, err := newDockerExecutor(cfg.NodeAgent.DockerHost, nodeID)
+		if err != nil {
+			logger.Critical(nil, "NewNodeAgent docker executor error [%v]", err)
+			panic(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = docker.ping(ctx)
+		cancel()
+		if err != nil {
+			logger.Error(nil, "NewNodeAgent docker [%s] unreachable, container tasks are not run [%v]", cfg.NodeAgent.DockerHost, err)
+		} else {
+			executors[models.ExecutorDocker] = docker
+		}
+	}
+
+	var artifacts artifact.Store
+	if cfg.Artifact.Store != "" {
+		artifacts, err = artifact.NewStore(cfg)
+		if err != nil {
+			logger.Critical(nil, "NewNodeAgent artifact store error [%v]", err)
+			panic(err)
+		}
+	}
+
+	client := clientset.NewForConfigOrDie(clientset.LoadConfig(cfg))
+
 	na := &NodeAgent{
+		client:        client,
+		NodeID:        nodeID,
 		HostName:      host,
-		aliveReporter: NewAliveReporter(),
-		taskWatcher:   NewTaskWatcher(host),
+		aliveReporter: NewAliveReporter(instance),
+		taskWatcher:   NewTaskWatcher(client, nodeID),
+		journal:       journal,
+		executors:     executors,
+		artifacts:     artifacts,
 	}
+	na.killCtx, na.kill = context.WithCancel(context.Background())
 	return na
 }
 
@@ -46,72 +133,442 @@ func Init() *NodeAgent {
 	return nodeAgent
 }
 
-func (na *NodeAgent) updateTask(taskInfo models.TaskInfo) {
-	value, err := json.Marshal(taskInfo)
-	if err != nil {
-		logger.Error(nil, "updateTask marshal task info error [%v]", err)
+// taskUpdateAttempts and taskUpdateBackoff bound how long the agent retries
+// to store the status of a task while the apiserver is unreachable.
+var (
+	taskUpdateAttempts = 5
+	taskUpdateBackoff  = 2 * time.Second
+)
+
+// updateOwnTask updates a task the node saw or claimed at
+// taskInfo.ResourceVersion. A task modified since then, eg. failed by the
+// node monitor and scheduled elsewhere, is no longer the node's to update.
+// It returns false when the update could not be sent, the task is then still
+// the node's.
+func (na *NodeAgent) updateOwnTask(taskInfo models.TaskInfo) bool {
+	backoff := taskUpdateBackoff
+	for attempt := 1; ; attempt++ {
+		err := na.client.Tasks(taskInfo.Namespace).UpdateIfUnchanged(context.Background(), &taskInfo)
+		if err == nil {
+			return true
+		}
+		if clientset.IsConflict(err) || clientset.IsNotFound(err) {
+			// A conflict may also be the update itself, stored though its
+			// answer was lost.
+			logger.Info(nil, "updateOwnTask [%s] was modified by another, dropping the update", taskInfo.Name)
+			return true
+		}
+		logger.Error(nil, "updateOwnTask [%s] attempt %d error [%v]", taskInfo.Name, attempt, err)
+		if attempt >= taskUpdateAttempts {
+			return false
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// releaseRecord removes the journal record of a task once its final status is
+// stored. Otherwise the record is kept, adoptTasks reports the task when the
+// agent starts again.
+func (na *NodeAgent) releaseRecord(record *taskRecord, stored bool) {
+	if !stored {
+		logger.Error(nil, "Task [%s] status not stored, keeping its record", record.Name)
 		return
 	}
+	na.journal.remove(record)
+}
 
-	info := models.APIInfo{
-		Info: string(value),
-		TTL:  0,
+// executorFor returns the executor of the task of record, nil when the
+// node does not support it.
+func (na *NodeAgent) executorFor(record *taskRecord) Executor {
+	name := record.Executor
+	if name == "" {
+		name = models.ExecutorProcess
 	}
+	return na.executors[name]
+}
 
-	value, err = json.Marshal(info)
-	if err != nil {
-		logger.Error(nil, "updateTask marshal info error [%v]", err)
+// executorNames returns the executors of the node, reported in its
+// capabilities.
+func (na *NodeAgent) executorNames() []string {
+	names := []string{models.ExecutorProcess}
+	for name := range na.executors {
+		if name != models.ExecutorProcess {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// runCmd starts the task with its executor, records it in the journal once
+// started and waits for it like Executor.Wait.
+func (na *NodeAgent) runCmd(ctx context.Context, taskInfo models.TaskInfo, record *taskRecord) (taskResult, bool) {
+	executor := na.executorFor(record)
+	if executor == nil {
+		return taskResult{ExitCode: -1, Output: fmt.Sprintf("node does not support the %s executor\n", record.Executor)}, true
+	}
+
+	if record.WorkDir != "" {
+		if err := na.fetchInputs(ctx, taskInfo, record); err != nil {
+			if ctx.Err() != nil {
+				return taskResult{}, false
+			}
+			logger.Error(nil, "runCmd task [%s] input artifacts error: %v", taskInfo.Name, err)
+			return taskResult{ExitCode: -1, Output: err.Error() + "\n", Reason: models.TaskReasonArtifactFailed}, true
+		}
+	}
+
+	if err := executor.Start(taskInfo, record); err != nil {
+		logger.Error(nil, "runCmd task [%s] error: %v", taskInfo.Name, err)
+		return taskResult{ExitCode: -1, Output: err.Error() + "\n"}, true
+	}
+	if err := na.journal.save(record); err != nil {
+		logger.Error(nil, "runCmd task [%s] save record error: %v", taskInfo.Name, err)
+	}
+	return executor.Wait(ctx, record)
+}
+
+func (na *NodeAgent) runContext() context.Context {
+	na.killLock.Lock()
+	defer na.killLock.Unlock()
+	return na.killCtx
+}
+
+// killRunning kills the running tasks, which are handed back.
+func (na *NodeAgent) killRunning() {
+	na.killLock.Lock()
+	defer na.killLock.Unlock()
+	na.kill()
+	na.killCtx, na.kill = context.WithCancel(context.Background())
+}
+
+func (na *NodeAgent) isDraining() bool {
+	na.drainLock.Lock()
+	defer na.drainLock.Unlock()
+	return na.nodeDrain != nil
+}
+
+// setNodeDrain follows the drain requested by the operator, the tasks still
+// running at its deadline are killed and handed back.
+func (na *NodeAgent) setNodeDrain(drain *models.NodeDrain) {
+	na.drainLock.Lock()
+	defer na.drainLock.Unlock()
+
+	if drain == nil {
+		if na.nodeDrain != nil {
+			logger.Info(nil, "Node [%s] drain ended", na.NodeID)
+		}
+		na.nodeDrain = nil
+		return
+	}
+	if na.nodeDrain != nil && na.nodeDrain.Deadline.Equal(drain.Deadline) {
 		return
 	}
 
-	cfg := config.GetInstance()
+	logger.Info(nil, "Node [%s] draining until [%s]", na.NodeID, drain.Deadline)
+	na.nodeDrain = drain
+	deadline := drain.Deadline
+	time.AfterFunc(time.Until(deadline), func() {
+		na.drainLock.Lock()
+		current := na.nodeDrain
+		na.drainLock.Unlock()
+
+		if current != nil && current.Deadline.Equal(deadline) {
+			logger.Info(nil, "Node [%s] drain deadline passed, killing the running tasks", na.NodeID)
+			na.killRunning()
+		}
+	})
+}
+
+// handBack returns a task the node did not run to the scheduler, it returns
+// whether the task was handed back.
+func (na *NodeAgent) handBack(taskInfo models.TaskInfo) bool {
+	logger.Info(nil, "Hand back task [%s]", taskInfo.Name)
+
+	taskInfo.Node = ""
+	taskInfo.Status = "Pending"
+	taskInfo.StartTime = time.Time{}
+	return na.updateOwnTask(taskInfo)
+}
 
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
+// claimResult is the outcome of claimTask.
+type claimResult int
 
-	writer.WriteAPIServer(url, "tasks", taskInfo.Name, string(value))
+const (
+	claimWon claimResult = iota
+	claimLost
+	// claimUnknown means the claim may have been stored, the task is left to
+	// adoptTasks.
+	claimUnknown
+)
+
+// claimedBy tells whether task is the claim of node made at startTime.
+func claimedBy(task *models.TaskInfo, node string, startTime time.Time) bool {
+	return task.Node == node && task.Status == "Running" && task.StartTime.Equal(startTime)
 }
 
-func (na *NodeAgent) runCmd(app string, args []string) {
-	cmd := exec.Command(app, args...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// claimTask moves the task from Scheduled to Running, unless it was modified
+// since the agent saw it: a repeated event, another agent or the scheduler
+// got there first. The task is run only once claimed.
+func (na *NodeAgent) claimTask(taskInfo *models.TaskInfo) claimResult {
+	claim := *taskInfo
+	claim.Status = "Running"
+	claim.StartTime = time.Now()
+
+	err := na.client.Tasks(taskInfo.Namespace).UpdateIfUnchanged(context.Background(), &claim)
+	if err == nil {
+		*taskInfo = claim
+		return claimWon
+	}
+	if clientset.IsNotFound(err) {
+		logger.Info(nil, "claimTask [%s] was deleted, dropping the task", taskInfo.Name)
+		return claimLost
+	}
 
-	cmd.Start()
-	cmd.Wait()
+	// The claim may have been stored though the answer was lost, the task
+	// tells whether it is ours.
+	logger.Info(nil, "claimTask [%s] error [%v], checking the task", taskInfo.Name, err)
+	task, err := na.client.Tasks(taskInfo.Namespace).Get(context.Background(), taskInfo.Name)
+	if clientset.IsNotFound(err) {
+		return claimLost
+	}
+	if err != nil {
+		logger.Error(nil, "claimTask [%s] get task error [%v]", taskInfo.Name, err)
+		return claimUnknown
+	}
+	if !claimedBy(task, na.NodeID, claim.StartTime) {
+		logger.Info(nil, "claimTask [%s] lost the claim, dropping the task", taskInfo.Name)
+		return claimLost
+	}
+	*taskInfo = *task
+	return claimWon
 }
 
-func (na *NodeAgent) runTask(taskInfo models.TaskInfo) {
+// runTask runs a task claimed and recorded in the journal.
+func (na *NodeAgent) runTask(taskInfo models.TaskInfo, record *taskRecord) {
+	defer na.tasks.Done()
+
 	//1.Start running task
-	taskInfo.Status = "Running"
-	taskInfo.StartTime = time.Now()
-	na.updateTask(taskInfo)
+	record.StartTime = taskInfo.StartTime
+
+	runningTasks.Inc()
+	defer runningTasks.Dec()
 
 	//2.Running task
 	logger.Debug(nil, "Run task %v", taskInfo.Cmd)
-	if len(taskInfo.Cmd) > 0 {
-		na.runCmd(taskInfo.Cmd[0], taskInfo.Cmd[1:])
+	if len(taskInfo.Cmd) > 0 || taskInfo.Container != nil {
+		result, finished := na.runCmd(na.runContext(), taskInfo, record)
+		if !finished {
+			na.releaseRecord(record, na.handBack(taskInfo))
+			return
+		}
+		setResult(&taskInfo, result)
+		na.collectOutputs(&taskInfo, record)
 	}
 
 	//3.Complete task
-	taskInfo.Status = "Completed"
+	na.releaseRecord(record, na.completeTask(taskInfo))
+}
+
+// resumeTask follows a task found in the journal on startup until it ends.
+func (na *NodeAgent) resumeTask(taskInfo models.TaskInfo, record *taskRecord) {
+	defer na.tasks.Done()
+
+	taskInfo.Status = "Running"
+	taskInfo.StartTime = record.StartTime
+
+	executor := na.executorFor(record)
+	if !record.started() || executor == nil {
+		// The agent stopped while starting the task, it may have run.
+		logger.Info(nil, "Task [%s] was being started when the node agent stopped", taskInfo.Name)
+		if executor != nil {
+			executor.Kill(record)
+		}
+		taskInfo.ExitCode = -1
+		taskInfo.Output = "node agent stopped while starting the task\n"
+		na.releaseRecord(record, na.completeTask(taskInfo))
+		return
+	}
+
+	runningTasks.Inc()
+	defer runningTasks.Dec()
+
+	result, finished := executor.Wait(na.runContext(), record)
+	if !finished {
+		na.releaseRecord(record, na.handBack(taskInfo))
+		return
+	}
+	setResult(&taskInfo, result)
+	na.collectOutputs(&taskInfo, record)
+	na.releaseRecord(record, na.completeTask(taskInfo))
+}
+
+// adoptTasks takes over the tasks a previous agent started, before any task
+// is taken: the ones still running are followed, the ones which ended are
+// reported.
+func (na *NodeAgent) adoptTasks() {
+	records, err := na.journal.list()
+	if err != nil {
+		logger.Error(nil, "adoptTasks list journal error [%v]", err)
+		return
+	}
+
+	for _, record := range records {
+		task, err := na.client.Tasks(record.Namespace).Get(context.Background(), record.Name)
+		if clientset.IsNotFound(err) {
+			logger.Info(nil, "adoptTasks task [%s] was deleted", record.Name)
+			if executor := na.executorFor(record); executor != nil {
+				executor.Kill(record)
+			}
+			na.journal.remove(record)
+			continue
+		}
+		if err != nil {
+			// The record stays, so that the task is not started again.
+			logger.Error(nil, "adoptTasks get task [%s] error [%v]", record.Name, err)
+			continue
+		}
+
+		if !record.started() && task.Status == "Scheduled" {
+			// The agent stopped before it claimed the task, it is taken
+			// again.
+			na.journal.remove(record)
+			continue
+		}
+
+		logger.Info(nil, "adoptTasks adopted task [%s] of process [%d] container [%s]", record.Name, record.Pid, record.ContainerID)
+		na.tasks.Add(1)
+		go na.resumeTask(*task, record)
+	}
+}
+
+// setResult records on the task how it ended.
+func setResult(taskInfo *models.TaskInfo, result taskResult) {
+	taskInfo.ExitCode = result.ExitCode
+	taskInfo.Output = result.Output
+	taskInfo.Reason = result.Reason
+	taskInfo.Usage = result.Usage
+}
+
+// completeTask reports the task finished according to its exit code, a task
+// with a failure reason failed whatever its exit code. It returns whether the
+// status was stored.
+func (na *NodeAgent) completeTask(taskInfo models.TaskInfo) bool {
+	if taskInfo.ExitCode == 0 && taskInfo.Reason == "" {
+		taskInfo.Status = "Completed"
+	} else {
+		taskInfo.Status = "Failed"
+	}
 	taskInfo.CompleteTime = time.Now()
-	na.updateTask(taskInfo)
+	if !na.updateOwnTask(taskInfo) {
+		return false
+	}
+	taskDuration.WithLabelValues(taskInfo.Status).Observe(taskInfo.CompleteTime.Sub(taskInfo.StartTime).Seconds())
+	return true
 }
 
-func (na *NodeAgent) runLoop() {
+func (na *NodeAgent) runLoop(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case taskInfo := <-na.taskWatcher.taskChan:
+			if na.isDraining() {
+				na.handBack(taskInfo)
+				continue
+			}
+			if na.journal.has(taskInfo) {
+				logger.Info(nil, "runLoop task [%s] already started", taskInfo.Name)
+				continue
+			}
 			logger.Debug(nil, "runTask %v", taskInfo)
 
-			go na.runTask(taskInfo)
+			// The task is recorded before it is claimed, it is never started
+			// twice.
+			record := na.journal.newRecord(taskInfo)
+			if err := na.journal.save(record); err != nil {
+				logger.Error(nil, "runLoop record task [%s] error [%v]", taskInfo.Name, err)
+				na.handBack(taskInfo)
+				continue
+			}
+			switch na.claimTask(&taskInfo) {
+			case claimLost:
+				na.journal.remove(record)
+				continue
+			case claimUnknown:
+				// The record keeps the task from being started here again.
+				logger.Error(nil, "runLoop task [%s] claim unknown, left to the next start", taskInfo.Name)
+				continue
+			}
+			na.tasks.Add(1)
+			go na.runTask(taskInfo, record)
 		}
 	}
 }
 
-func (na *NodeAgent) Run() {
-	go na.aliveReporter.HeartBeat()
-	go na.taskWatcher.Run()
-	na.runLoop()
+// drain stops taking tasks, hands back the ones not started and waits for
+// the running ones until the drain timeout, then kills and hands them back.
+func (na *NodeAgent) drain() {
+	na.taskWatcher.Stop()
+	for len(na.taskWatcher.taskChan) > 0 {
+		na.handBack(<-na.taskWatcher.taskChan)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		na.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(config.GetInstance().NodeAgent.DrainTimeout):
+		logger.Info(nil, "NodeAgent drain timeout, killing the running tasks")
+		na.killRunning()
+		<-done
+	}
+}
+
+// Run registers the node and runs the node agent until ctx is done, then
+// drains the node and deregisters it.
+func (na *NodeAgent) Run(ctx context.Context) {
+	adminServer := admin.NewServer(config.GetInstance().Admin.Port)
+	adminServer.AddReadyCheck("informers", func() error {
+		if !na.taskWatcher.HasSynced() {
+			return errors.New("tasks not synced yet")
+		}
+		return nil
+	})
+	adminServer.AddReadyCheck("heartbeat", na.aliveReporter.checkHeartbeat)
+	adminServer.Start()
+
+	// No task is taken before the node is registered.
+	if !na.aliveReporter.register(ctx) {
+		logger.Info(nil, "NodeAgent stopped before the node registered")
+		return
+	}
+
+	na.adoptTasks()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		na.aliveReporter.HeartBeat(heartbeatCtx)
+	}()
+	na.taskWatcher.Run()
+	na.runLoop(ctx)
+
+	logger.Info(nil, "NodeAgent shutting down")
+
+	// The node stays registered as not ready while it drains, then its
+	// entry is deleted.
+	na.aliveReporter.markNotReady()
+	na.drain()
+	stopHeartbeat()
+	<-heartbeatDone
+	na.aliveReporter.deregister()
+
+	logger.Info(nil, "NodeAgent stopped")
 }
@@ -0,0 +1,140 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openpitrix.io/scheduler/pkg/client/clientset"
+	"openpitrix.io/scheduler/pkg/models"
+)
+
+// taskServer stores the task t-1, the answers of its updates are lost once
+// the update is stored.
+type taskServer struct {
+	sync.Mutex
+	task     models.TaskInfo
+	revision int64
+	// getCode fails the reads of the task when set.
+	getCode int
+}
+
+func (s *taskServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch r.Method {
+	case "PUT":
+		var apiInfo models.APIInfo
+		json.NewDecoder(r.Body).Decode(&apiInfo)
+		json.Unmarshal([]byte(apiInfo.Info), &s.task)
+		s.revision++
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	case "GET":
+		if s.getCode != 0 {
+			w.WriteHeader(s.getCode)
+			return
+		}
+		value, _ := json.Marshal(s.task)
+		json.NewEncoder(w).Encode(models.Info{Key: "tasks/default/t-1", Value: value, ModRevision: s.revision})
+	}
+}
+
+func TestClaimTask(t *testing.T) {
+	server := &taskServer{revision: 3}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := clientset.NewForConfig(&clientset.Config{Host: httpServer.URL})
+	assert.NoError(t, err)
+	na := &NodeAgent{NodeID: "n-1", client: client}
+
+	// The claim is stored though its answer is lost.
+	taskInfo := models.TaskInfo{Namespace: "default", Name: "t-1", Node: "n-1", Status: "Scheduled", ResourceVersion: 3}
+	assert.Equal(t, claimWon, na.claimTask(&taskInfo))
+	assert.Equal(t, "Running", taskInfo.Status)
+	assert.Equal(t, int64(4), taskInfo.ResourceVersion)
+
+	// The task could not be read.
+	server.getCode = http.StatusServiceUnavailable
+	taskInfo = models.TaskInfo{Namespace: "default", Name: "t-1", Node: "n-1", Status: "Scheduled", ResourceVersion: 4}
+	assert.Equal(t, claimUnknown, na.claimTask(&taskInfo))
+	assert.Equal(t, "Scheduled", taskInfo.Status)
+
+	server.getCode = http.StatusNotFound
+	assert.Equal(t, claimLost, na.claimTask(&taskInfo))
+}
+
+func TestCompleteTask(t *testing.T) {
+	defer func(attempts int, backoff time.Duration) {
+		taskUpdateAttempts, taskUpdateBackoff = attempts, backoff
+	}(taskUpdateAttempts, taskUpdateBackoff)
+	taskUpdateAttempts, taskUpdateBackoff = 3, time.Millisecond
+
+	var mutex sync.Mutex
+	failures := 0
+	var stored models.TaskInfo
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var apiInfo models.APIInfo
+		json.NewDecoder(r.Body).Decode(&apiInfo)
+		json.Unmarshal([]byte(apiInfo.Info), &stored)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "nodeagent")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	journal, err := newTaskJournal(filepath.Join(dir, "tasks"))
+	assert.NoError(t, err)
+
+	client, err := clientset.NewForConfig(&clientset.Config{Host: server.URL})
+	assert.NoError(t, err)
+	na := &NodeAgent{NodeID: "n-1", client: client, journal: journal}
+
+	taskInfo := models.TaskInfo{Namespace: "default", Name: "t-1", Node: "n-1", Status: "Running", ResourceVersion: 4}
+	record := journal.newRecord(taskInfo)
+	assert.NoError(t, journal.save(record))
+
+	// The result is kept while the apiserver is unreachable.
+	failures = 3
+	na.releaseRecord(record, na.completeTask(taskInfo))
+	assert.True(t, journal.has(taskInfo))
+	assert.Empty(t, stored.Status)
+
+	failures = 2
+	na.releaseRecord(record, na.completeTask(taskInfo))
+	assert.False(t, journal.has(taskInfo))
+	assert.Equal(t, "Completed", stored.Status)
+}
+
+func TestClaimedBy(t *testing.T) {
+	startTime := time.Date(2019, 8, 1, 8, 0, 0, 0, time.UTC)
+	task := &models.TaskInfo{Node: "n-1", Status: "Running", StartTime: startTime}
+
+	assert.True(t, claimedBy(task, "n-1", startTime))
+	assert.False(t, claimedBy(task, "n-2", startTime))
+	// Claimed again by an earlier run of the agent.
+	assert.False(t, claimedBy(task, "n-1", startTime.Add(time.Second)))
+	task.Status = "Scheduled"
+	assert.False(t, claimedBy(task, "n-1", startTime))
+}
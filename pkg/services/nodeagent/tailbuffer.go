@@ -0,0 +1,41 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package nodeagent
+
+import (
+	"sync"
+)
+
+// outputTailSize bounds the task output kept in the task status.
+const outputTailSize = 64 * 1024
+
+// tailBuffer is a writer keeping the last size bytes written to it.
+type tailBuffer struct {
+	sync.Mutex
+	size int
+	buf  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.size {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.size:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.Lock()
+	defer t.Unlock()
+
+	return string(t.buf)
+}
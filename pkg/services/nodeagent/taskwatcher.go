@@ -8,43 +8,51 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/client/informer"
-	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
 type TaskWatcher struct {
-	HostName string
+	client   clientset.Interface
+	NodeID   string
+	informer *informer.Informer
 	taskChan chan models.TaskInfo
+	stopChan chan struct{}
 }
 
-func NewTaskWatcher(hostName string) *TaskWatcher {
+func NewTaskWatcher(client clientset.Interface, nodeID string) *TaskWatcher {
 	tw := &TaskWatcher{
-		HostName: hostName,
+		client:   client,
+		NodeID:   nodeID,
+		informer: client.Tasks("").Informer(fmt.Sprintf("Node=%s,Status=Scheduled", nodeID), 0),
 		taskChan: make(chan models.TaskInfo, 100),
+		stopChan: make(chan struct{}),
 	}
 
 	return tw
 }
 
-func (tw *TaskWatcher) runTask(value []byte) {
+func (tw *TaskWatcher) runTask(info models.Info) {
 	taskInfo := models.TaskInfo{}
 
-	err := json.Unmarshal(value, &taskInfo)
+	err := json.Unmarshal(info.Value, &taskInfo)
 	if err != nil {
 		logger.Error(nil, "Unmarshal TaskInfo error: %v", err)
 		return
 	}
+	// The task is claimed at the revision it was seen at.
+	taskInfo.ResourceVersion = info.ModRevision
 
-	tw.taskChan <- taskInfo
+	select {
+	case tw.taskChan <- taskInfo:
+	case <-tw.stopChan:
+	}
 }
 
 func (tw *TaskWatcher) watchTasks() {
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1/tasks/?watch=true&filter=Node=%s,Status=Scheduled", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort, tw.HostName)
-	taskInformer := informer.NewInformer(url)
+	taskInformer := tw.informer
 
 	taskInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -52,7 +60,7 @@ func (tw *TaskWatcher) watchTasks() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				tw.runTask(info.Value)
+				tw.runTask(info)
 			} else {
 				logger.Info(nil, "watchTasks data error")
 			}
@@ -71,3 +79,14 @@ func (tw *TaskWatcher) watchTasks() {
 func (tw *TaskWatcher) Run() {
 	tw.watchTasks()
 }
+
+// Stop stops taking tasks, the ones left in taskChan are not run.
+func (tw *TaskWatcher) Stop() {
+	close(tw.stopChan)
+	tw.informer.Stop()
+}
+
+// HasSynced tells if the first list of the tasks of the node has been handled.
+func (tw *TaskWatcher) HasSynced() bool {
+	return tw.informer.HasSynced()
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"openpitrix.io/scheduler/pkg/metrics"
+)
+
+const subsystem = "scheduler"
+
+var (
+	schedulingLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "scheduling_latency_seconds",
+		Help:      "Time from the creation of a task, Pending, to its assignment to a node, Scheduled.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 15),
+	})
+
+	nodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, subsystem, "nodes"),
+		"Registered nodes by pool.",
+		[]string{"pool"}, nil)
+
+	heartbeatAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, subsystem, "node_heartbeat_age_seconds"),
+		"Time since the last heartbeat of a node.",
+		[]string{"node"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(schedulingLatency)
+}
+
+// nodeCollector reads the node metrics from the nodes of the watcher when
+// they are scraped.
+type nodeCollector struct {
+	nodeStorage *NodeStorage
+}
+
+func (c nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodesDesc
+	ch <- heartbeatAgeDesc
+}
+
+func (c nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.nodeStorage.RLock()
+	defer c.nodeStorage.RUnlock()
+
+	pools := make(map[string]int)
+	for _, node := range c.nodeStorage.List {
+		pools[c.nodeStorage.Pools[node]]++
+		age := time.Since(c.nodeStorage.Seen[node]).Seconds()
+		ch <- prometheus.MustNewConstMetric(heartbeatAgeDesc, prometheus.GaugeValue, age, node)
+	}
+	for pool, count := range pools {
+		ch <- prometheus.MustNewConstMetric(nodesDesc, prometheus.GaugeValue, float64(count), pool)
+	}
+}
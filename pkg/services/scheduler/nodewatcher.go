@@ -5,13 +5,15 @@
 package scheduler
 
 import (
-	"fmt"
+	"encoding/json"
 	"math/rand"
 	"strings"
 	"sync"
+	"time"
 
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/client/informer"
-	"openpitrix.io/scheduler/pkg/config"
+	"openpitrix.io/scheduler/pkg/constants"
 	"openpitrix.io/scheduler/pkg/logger"
 	"openpitrix.io/scheduler/pkg/models"
 )
@@ -20,26 +22,87 @@ type NodeStorage struct {
 	sync.RWMutex
 	Map  map[string]int
 	List []string
+	// Pools holds the pool of every node.
+	Pools map[string]string
+	// Seen holds the time of the last heartbeat of every node.
+	Seen map[string]time.Time
+	// Unschedulable holds the cordoned nodes.
+	Unschedulable map[string]bool
+	// NotReady holds the nodes whose agent is shutting down or whose
+	// heartbeats stopped.
+	NotReady map[string]bool
+	// Capabilities holds the executors and features of every node.
+	Capabilities map[string][]string
 }
 
 type NodeWatcher struct {
+	client      clientset.Interface
+	informer    *informer.Informer
 	nodeStorage *NodeStorage
 }
 
-func NewNodeWatcher() *NodeWatcher {
+func NewNodeWatcher(client clientset.Interface) *NodeWatcher {
 	nw := &NodeWatcher{
-		nodeStorage: &NodeStorage{Map: make(map[string]int), List: []string{}},
+		client:      client,
+		informer:    client.Nodes().Informer("", 0),
+		nodeStorage: &NodeStorage{Map: make(map[string]int), List: []string{}, Pools: make(map[string]string), Seen: make(map[string]time.Time), Unschedulable: make(map[string]bool), NotReady: make(map[string]bool), Capabilities: make(map[string][]string)},
 	}
 
 	return nw
 }
 
-func (nw *NodeWatcher) addNode(node string) {
+// decodeNode decodes a node value, nodes without a pool are in the default
+// pool.
+func decodeNode(value []byte) models.NodeInfo {
+	nodeInfo := models.NodeInfo{}
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &nodeInfo); err != nil {
+			logger.Error(nil, "Unmarshal NodeInfo error: %v", err)
+		}
+	}
+	if nodeInfo.Pool == "" {
+		nodeInfo.Pool = constants.DefaultNodePool
+	}
+	return nodeInfo
+}
+
+// setNode records what the heartbeat or the operator changed, the caller
+// holds the lock.
+func (nw *NodeWatcher) setNode(node string, value []byte) {
+	nodeInfo := decodeNode(value)
+	nw.nodeStorage.Pools[node] = nodeInfo.Pool
+	// The apiserver stamps the heartbeats, the other updates keep the stamp.
+	if nodeInfo.Status != nil && !nodeInfo.Status.LastHeartbeatTime.IsZero() {
+		nw.nodeStorage.Seen[node] = nodeInfo.Status.LastHeartbeatTime
+	} else {
+		nw.nodeStorage.Seen[node] = time.Now()
+	}
+	nw.nodeStorage.Unschedulable[node] = nodeInfo.Unschedulable
+	nw.nodeStorage.NotReady[node] = !nodeInfo.IsReady()
+	nw.nodeStorage.Capabilities[node] = nodeInfo.Capabilities
+}
+
+// canRun tells if the node has the executor, the agents which do not report
+// their capabilities only run processes.
+func (nw *NodeWatcher) canRun(node string, executor string) bool {
+	capabilities := nw.nodeStorage.Capabilities[node]
+	if len(capabilities) == 0 {
+		return executor == models.ExecutorProcess
+	}
+	for _, capability := range capabilities {
+		if capability == executor {
+			return true
+		}
+	}
+	return false
+}
+
+func (nw *NodeWatcher) addNode(node string, value []byte) {
 	node = strings.TrimPrefix(node, "nodes/")
 	nw.nodeStorage.Lock()
-	if _, ok := nw.nodeStorage.Map[node]; ok {
-		logger.Error(nil, "addNode error: node already registered")
-	} else {
+	nw.setNode(node, value)
+	// A node listed again after a resync only has its value updated.
+	if _, ok := nw.nodeStorage.Map[node]; !ok {
 		nw.nodeStorage.List = append(nw.nodeStorage.List, node)
 		nw.nodeStorage.Map[node] = len(nw.nodeStorage.List) - 1
 	}
@@ -52,6 +115,15 @@ func (nw *NodeWatcher) deleteNode(node string) {
 	if index, ok := nw.nodeStorage.Map[node]; ok {
 		nw.nodeStorage.List = append(nw.nodeStorage.List[:index], nw.nodeStorage.List[index+1:]...)
 		delete(nw.nodeStorage.Map, node)
+		delete(nw.nodeStorage.Pools, node)
+		delete(nw.nodeStorage.Seen, node)
+		delete(nw.nodeStorage.Unschedulable, node)
+		delete(nw.nodeStorage.NotReady, node)
+		delete(nw.nodeStorage.Capabilities, node)
+		// The nodes after the deleted one moved down.
+		for i := index; i < len(nw.nodeStorage.List); i++ {
+			nw.nodeStorage.Map[nw.nodeStorage.List[i]] = i
+		}
 	} else {
 		logger.Error(nil, "deleteNode error: node not registered")
 	}
@@ -59,10 +131,7 @@ func (nw *NodeWatcher) deleteNode(node string) {
 }
 
 func (nw *NodeWatcher) watchNodes() {
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1/nodes/", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	nodeInformer := informer.NewInformer(url)
+	nodeInformer := nw.informer
 
 	nodeInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -70,7 +139,7 @@ func (nw *NodeWatcher) watchNodes() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				nw.addNode(info.Key)
+				nw.addNode(info.Key, info.Value)
 			} else {
 				logger.Info(nil, "watchNodes data error")
 			}
@@ -87,24 +156,54 @@ func (nw *NodeWatcher) watchNodes() {
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			logger.Info(nil, "watchNodes updated node: %v", newObj)
+
+			// The heartbeats carry the pool and the conditions, the
+			// operator may have cordoned the node.
+			info, ok := (newObj).(models.Info)
+			if ok {
+				nw.nodeStorage.Lock()
+				nw.setNode(strings.TrimPrefix(info.Key, "nodes/"), info.Value)
+				nw.nodeStorage.Unlock()
+			} else {
+				logger.Info(nil, "watchNodes data error")
+			}
 		},
 	})
 
 	nodeInformer.Start()
 }
 
-func (nw *NodeWatcher) SelectNode() string {
+// SelectNode picks a random ready and schedulable node among the pools the namespace
+// allows, which has the executor of the task.
+func (nw *NodeWatcher) SelectNode(nsInfo *models.NamespaceInfo, executor string) string {
 	nw.nodeStorage.Lock()
 	defer nw.nodeStorage.Unlock()
 
-	if len(nw.nodeStorage.List) == 0 {
-		logger.Info(nil, "SelectNode has no node to schedule")
+	var nodes []string
+	for _, node := range nw.nodeStorage.List {
+		if nsInfo.AllowsPool(nw.nodeStorage.Pools[node]) && !nw.nodeStorage.Unschedulable[node] && !nw.nodeStorage.NotReady[node] && nw.canRun(node, executor) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 {
+		logger.Info(nil, "SelectNode has no node to schedule in pools %v with executor [%s]", nsInfo.NodePools, executor)
 		return ""
 	}
 
-	return nw.nodeStorage.List[rand.Intn(len(nw.nodeStorage.List))]
+	return nodes[rand.Intn(len(nodes))]
 }
 
 func (nw *NodeWatcher) Run() {
 	nw.watchNodes()
 }
+
+// Stop stops watching the nodes.
+func (nw *NodeWatcher) Stop() {
+	nw.informer.Stop()
+}
+
+// HasSynced tells if the first list of the nodes has been handled.
+func (nw *NodeWatcher) HasSynced() bool {
+	return nw.informer.HasSynced()
+}
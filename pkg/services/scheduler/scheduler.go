@@ -5,25 +5,46 @@
 package scheduler
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
-	"openpitrix.io/scheduler/pkg/client/writer"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"openpitrix.io/scheduler/pkg/admin"
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
+	"openpitrix.io/scheduler/pkg/metrics"
 	"openpitrix.io/scheduler/pkg/models"
 )
 
 type Scheduler struct {
+	client      clientset.Interface
 	nodeWatcher *NodeWatcher
 	taskWatcher *TaskWatcher
 }
 
 func NewScheduler() *Scheduler {
+	client := clientset.NewForConfigOrDie(clientset.LoadConfig(config.GetInstance()))
+
 	sc := &Scheduler{
-		nodeWatcher: NewNodeWatcher(),
-		taskWatcher: NewTaskWatcher(),
+		client:      client,
+		nodeWatcher: NewNodeWatcher(client),
+		taskWatcher: NewTaskWatcher(client),
 	}
+
+	prometheus.MustRegister(nodeCollector{sc.nodeWatcher.nodeStorage})
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: subsystem,
+		Name:      "queue_depth",
+		Help:      "Pending tasks waiting to be scheduled.",
+	}, func() float64 { return float64(len(sc.taskWatcher.taskChan)) }))
+	adminServer := admin.NewServer(config.GetInstance().Admin.Port)
+	adminServer.AddReadyCheck("informers", sc.informersSynced)
+	adminServer.Start()
 	return sc
 }
 
@@ -33,33 +54,31 @@ func Init() *Scheduler {
 	return scheduler
 }
 
-func (sc *Scheduler) updateTask(taskInfo models.TaskInfo) {
-	value, err := json.Marshal(taskInfo)
-	if err != nil {
-		logger.Error(nil, "updateTask marshal task info error [%v]", err)
-		return
+// assignTask assigns the task to its node unless it was modified since it
+// was queued: the queue holds stale copies of the tasks, one of them may
+// already have been assigned and claimed.
+func (sc *Scheduler) assignTask(taskInfo models.TaskInfo) error {
+	err := sc.client.Tasks(taskInfo.Namespace).UpdateIfUnchanged(context.Background(), &taskInfo)
+	if clientset.IsConflict(err) || clientset.IsNotFound(err) {
+		logger.Info(nil, "assignTask [%s] was modified since it was queued, dropping it", taskInfo.Name)
+		return err
 	}
-
-	info := models.APIInfo{
-		Info: string(value),
-		TTL:  0,
+	if err != nil {
+		logger.Error(nil, "assignTask [%s] error [%v]", taskInfo.Name, err)
 	}
+	return err
+}
 
-	value, err = json.Marshal(info)
+func (sc *Scheduler) scheduleTask(taskInfo models.TaskInfo) {
+	nsInfo, err := sc.client.Namespaces().Get(context.Background(), taskInfo.Namespace)
 	if err != nil {
-		logger.Error(nil, "updateTask marshal info error [%v]", err)
+		// Retried on the next resync.
+		logger.Error(nil, "scheduleTask [%s] get namespace [%s] error [%v]", taskInfo.Name, taskInfo.Namespace, err)
 		return
 	}
 
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	writer.WriteAPIServer(url, "tasks", taskInfo.Name, string(value))
-}
-
-func (sc *Scheduler) scheduleTask(taskInfo models.TaskInfo) {
-	//Choose node randomly and assign task
-	nodeSelected := sc.nodeWatcher.SelectNode()
+	//Choose node randomly in the pools of the namespace among those able to run it and assign task
+	nodeSelected := sc.nodeWatcher.SelectNode(nsInfo, taskInfo.Executor())
 
 	if "" == nodeSelected {
 		logger.Info(nil, "Scheduler has no node to schedule")
@@ -69,12 +88,16 @@ func (sc *Scheduler) scheduleTask(taskInfo models.TaskInfo) {
 	taskInfo.Node = nodeSelected
 	taskInfo.Status = "Scheduled"
 
-	sc.updateTask(taskInfo)
+	if err := sc.assignTask(taskInfo); err == nil && !taskInfo.CreateTime.IsZero() {
+		schedulingLatency.Observe(time.Since(taskInfo.CreateTime).Seconds())
+	}
 }
 
-func (sc *Scheduler) scheduleLoop() {
+func (sc *Scheduler) scheduleLoop(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case taskInfo := <-sc.taskWatcher.taskChan:
 			logger.Debug(nil, "scheduleTask %v", taskInfo)
 
@@ -83,8 +106,41 @@ func (sc *Scheduler) scheduleLoop() {
 	}
 }
 
-func (sc *Scheduler) Run() {
-	go sc.nodeWatcher.Run()
-	go sc.taskWatcher.Run()
-	sc.scheduleLoop()
+// informersSynced is the readiness check of the scheduler.
+func (sc *Scheduler) informersSynced() error {
+	var pending []string
+	if !sc.nodeWatcher.HasSynced() {
+		pending = append(pending, "nodes")
+	}
+	if !sc.taskWatcher.HasSynced() {
+		pending = append(pending, "tasks")
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%s not synced yet", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Run runs the scheduler until ctx is done.
+func (sc *Scheduler) Run(ctx context.Context) {
+	sc.nodeWatcher.Run()
+	sc.taskWatcher.Run()
+
+	loopCtx, stopLoop := context.WithCancel(context.Background())
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		sc.scheduleLoop(loopCtx)
+	}()
+
+	<-ctx.Done()
+	logger.Info(nil, "Scheduler shutting down")
+
+	// The loop keeps draining the pending tasks until the informers stopped.
+	sc.taskWatcher.Stop()
+	sc.nodeWatcher.Stop()
+	stopLoop()
+	<-loopDone
+
+	logger.Info(nil, "Scheduler stopped")
 }
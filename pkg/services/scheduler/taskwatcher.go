@@ -6,8 +6,8 @@ package scheduler
 
 import (
 	"encoding/json"
-	"fmt"
 
+	"openpitrix.io/scheduler/pkg/client/clientset"
 	"openpitrix.io/scheduler/pkg/client/informer"
 	"openpitrix.io/scheduler/pkg/config"
 	"openpitrix.io/scheduler/pkg/logger"
@@ -15,34 +15,39 @@ import (
 )
 
 type TaskWatcher struct {
+	client   clientset.Interface
+	informer *informer.Informer
 	taskChan chan models.TaskInfo
 }
 
-func NewTaskWatcher() *TaskWatcher {
+func NewTaskWatcher(client clientset.Interface) *TaskWatcher {
+	cfg := config.GetInstance()
+
 	tw := &TaskWatcher{
+		client:   client,
+		informer: client.Tasks("").Informer("Node=,Status=Pending", cfg.Informer.ResyncPeriod),
 		taskChan: make(chan models.TaskInfo, 100),
 	}
 
 	return tw
 }
 
-func (tw *TaskWatcher) scheduleTask(value []byte) {
+func (tw *TaskWatcher) scheduleTask(info models.Info) {
 	taskInfo := models.TaskInfo{}
 
-	err := json.Unmarshal(value, &taskInfo)
+	err := json.Unmarshal(info.Value, &taskInfo)
 	if err != nil {
 		logger.Error(nil, "Unmarshal TaskInfo error: %v", err)
 		return
 	}
+	// The task is assigned at the revision it was seen at.
+	taskInfo.ResourceVersion = info.ModRevision
 
 	tw.taskChan <- taskInfo
 }
 
 func (tw *TaskWatcher) watchTasks() {
-	cfg := config.GetInstance()
-
-	url := fmt.Sprintf("http://%s:%s/api/v1alpha1/tasks/?watch=true&filter=Node=,Status=Pending", cfg.ApiServer.ApiHost, cfg.ApiServer.ApiPort)
-	taskInformer := informer.NewInformer(url)
+	taskInformer := tw.informer
 
 	taskInformer.AddEventHandler(informer.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -50,7 +55,7 @@ func (tw *TaskWatcher) watchTasks() {
 
 			info, ok := (obj).(models.Info)
 			if ok {
-				tw.scheduleTask(info.Value)
+				tw.scheduleTask(info)
 			} else {
 				logger.Info(nil, "watchTasks data error")
 			}
@@ -60,6 +65,14 @@ func (tw *TaskWatcher) watchTasks() {
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			logger.Info(nil, "watchTasks updated task: %v", newObj)
+
+			// Pending tasks come back on every resync until a node takes them.
+			info, ok := (newObj).(models.Info)
+			if ok {
+				tw.scheduleTask(info)
+			} else {
+				logger.Info(nil, "watchTasks data error")
+			}
 		},
 	})
 
@@ -69,3 +82,13 @@ func (tw *TaskWatcher) watchTasks() {
 func (tw *TaskWatcher) Run() {
 	tw.watchTasks()
 }
+
+// Stop stops watching the pending tasks.
+func (tw *TaskWatcher) Stop() {
+	tw.informer.Stop()
+}
+
+// HasSynced tells if the first list of the pending tasks has been handled.
+func (tw *TaskWatcher) HasSynced() bool {
+	return tw.informer.HasSynced()
+}
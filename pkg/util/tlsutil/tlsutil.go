@@ -0,0 +1,176 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"openpitrix.io/scheduler/pkg/logger"
+)
+
+// checkInterval is how often the files are checked for changes.
+const checkInterval = 10 * time.Second
+
+// Reloader holds a certificate and key pair and a CA bundle, it loads them
+// again when one of the files changes so that certificates can be rotated
+// without a restart. Any of the files may be empty.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.Mutex
+	checked  time.Time
+	modTimes map[string]time.Time
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+}
+
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("certificate and key must be set together")
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (r *Reloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		p, err := LoadCertPool(r.caFile)
+		if err != nil {
+			return err
+		}
+		pool = p
+	}
+
+	r.cert, r.pool, r.modTimes, r.checked = cert, pool, modTimes, time.Now()
+	return nil
+}
+
+// current returns the certificate and CA pool, reloaded when a file has
+// changed since the last check. A failed reload keeps the loaded ones.
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < checkInterval {
+		return r.cert, r.pool
+	}
+	r.checked = time.Now()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			logger.Error(nil, "Check certificate file [%s] error [%v]", file, err)
+			return r.cert, r.pool
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			if err := r.load(); err != nil {
+				logger.Error(nil, "Reload certificates error [%v]", err)
+			} else {
+				logger.Info(nil, "Reloaded certificates of [%s]", file)
+			}
+			break
+		}
+	}
+	return r.cert, r.pool
+}
+
+// ServerConfig serves the certificate of the reloader and verifies the client
+// certificates given against its CA. Clients without a certificate are
+// accepted, they authenticate some other way.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			if cert == nil {
+				return nil, fmt.Errorf("no server certificate")
+			}
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				config.ClientCAs = pool
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			return config, nil
+		},
+	}
+}
+
+// ClientConfig presents the certificate of the reloader and verifies the
+// server against its CA, or the system roots when there is no CA. The CA is
+// read once, only the client certificate is reloaded.
+func (r *Reloader) ClientConfig() *tls.Config {
+	_, pool := r.current()
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+	}
+	if r.certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		}
+	}
+	return config
+}
+
+// LoadCertPool reads the PEM certificates of a CA bundle.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate in [%s]", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 The OpenPitrix Authors. All rights reserved.
+// Use of this source code is governed by a Apache license
+// that can be found in the LICENSE file.
+
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue writes a certificate signed by the CA and its key to dir.
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"system:masters"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func (ca *testCA) write(t *testing.T, dir string) string {
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+	return caFile
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caFile := ca.write(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "client", 3)
+
+	serverReloader, err := NewReloader(serverCert, serverKey, caFile)
+	assert.NoError(t, err)
+	clientReloader, err := NewReloader(clientCert, clientKey, caFile)
+	assert.NoError(t, err)
+
+	var commonName string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commonName = ""
+		if len(r.TLS.VerifiedChains) > 0 {
+			commonName = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		}
+	}))
+	server.TLS = serverReloader.ServerConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientReloader.ClientConfig()}}
+	_, err = client.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "client", commonName)
+
+	// A client without a certificate is accepted but not verified.
+	anonymous, err := NewReloader("", "", caFile)
+	assert.NoError(t, err)
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: anonymous.ClientConfig()}}
+	_, err = client.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "", commonName)
+
+	// Rotate the server certificate.
+	_, _ = ca.issue(t, dir, "server", 4)
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(serverCert, future, future))
+	serverReloader.checked = time.Time{}
+	cert, _ := serverReloader.current()
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), parsed.SerialNumber.Int64())
+
+	_, err = NewReloader(serverCert, "", "")
+	assert.Error(t, err)
+}